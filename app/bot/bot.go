@@ -2,6 +2,7 @@ package bot
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -42,6 +43,8 @@ func Start(ctx context.Context) {
 		api.RegisterHandler(bot.HandlerTypeMessageText, cmdStart, bot.MatchTypeCommand, cmdStartHandle)
 		api.RegisterHandler(bot.HandlerTypeMessageText, cmdState, bot.MatchTypeCommand, cmdStateHandle)
 		api.RegisterHandler(bot.HandlerTypeMessageText, cmdOrder, bot.MatchTypeCommand, cmdOrderHandle)
+		api.RegisterHandler(bot.HandlerTypeMessageText, cmdUnmatched, bot.MatchTypeCommand, cmdUnmatchedHandle)
+		api.RegisterHandler(bot.HandlerTypeMessageText, cmdRate, bot.MatchTypeCommand, cmdRateHandle)
 
 		api.RegisterHandler(bot.HandlerTypeCallbackQueryData, cbOrderDetail, bot.MatchTypePrefix, cbOrderDetailAction)
 		api.RegisterHandler(bot.HandlerTypeCallbackQueryData, cbWallet, bot.MatchTypePrefix, cbWalletAction)
@@ -58,6 +61,9 @@ func Start(ctx context.Context) {
 		api.RegisterHandler(bot.HandlerTypeCallbackQueryData, cbOrderNotifyRetry, bot.MatchTypePrefix, dbOrderNotifyRetryAction)
 		api.RegisterHandler(bot.HandlerTypeCallbackQueryData, cbMarkOrderSucc, bot.MatchTypePrefix, dbMarkOrderSuccAction)
 		api.RegisterHandler(bot.HandlerTypeCallbackQueryData, cbOrderList, bot.MatchTypePrefix, cbOrderListAction)
+		api.RegisterHandler(bot.HandlerTypeCallbackQueryData, cbLatePaidAccept, bot.MatchTypePrefix, cbLatePaidAcceptAction)
+		api.RegisterHandler(bot.HandlerTypeCallbackQueryData, cbLatePaidIgnore, bot.MatchTypePrefix, cbLatePaidIgnoreAction)
+		api.RegisterHandler(bot.HandlerTypeCallbackQueryData, cbUnmatchedAttach, bot.MatchTypePrefix, cbUnmatchedAttachAction)
 	}
 
 	_, err = api.SetMyCommands(ctx, &bot.SetMyCommandsParams{
@@ -66,6 +72,8 @@ func Start(ctx context.Context) {
 			{Command: cmdStart, Description: "开始使用"},
 			{Command: cmdState, Description: "收款状态"},
 			{Command: cmdOrder, Description: "订单列表"},
+			{Command: cmdUnmatched, Description: "未匹配收款"},
+			{Command: cmdRate, Description: "汇率走势"},
 		},
 	})
 	if err != nil {
@@ -86,7 +94,12 @@ func Start(ctx context.Context) {
 	api.Start(ctx)
 }
 
-func SendMessage(p *bot.SendMessageParams) {
+func SendMessage(p *bot.SendMessageParams) error {
+	if api == nil {
+
+		return errors.New("机器人未初始化")
+	}
+
 	if p.ChatID == nil {
 		p.ChatID = conf.BotAdminID()
 	}
@@ -99,6 +112,8 @@ func SendMessage(p *bot.SendMessageParams) {
 
 		log.Warn("Bot Send Message Error:", err.Error())
 	}
+
+	return err
 }
 
 func DeleteMessage(ctx context.Context, b *bot.Bot, p *bot.DeleteMessageParams) {
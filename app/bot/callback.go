@@ -21,7 +21,6 @@ import (
 	"github.com/v03413/bepusdt/app/log"
 	"github.com/v03413/bepusdt/app/model"
 	"github.com/v03413/go-cache"
-	"gorm.io/gorm"
 )
 
 const cbWallet = "wallet"
@@ -39,6 +38,9 @@ const cbOrderList = "order_list"
 const cbMarkNotifySucc = "mark_notify_succ"
 const cbOrderNotifyRetry = "order_notify_retry"
 const cbMarkOrderSucc = "mark_order_succ"
+const cbLatePaidAccept = "late_paid_accept"
+const cbLatePaidIgnore = "late_paid_ignore"
+const cbUnmatchedAttach = "unmatched_attach"
 
 func getArg(ctx context.Context, i int) string {
 	args, ok := ctx.Value("args").([]string)
@@ -161,6 +163,10 @@ func cbAddressAction(ctx context.Context, b *bot.Bot, u *models.Update) {
 			text = getAptosWalletInfo(wa)
 		} else if help.IsValidSolanaAddress(wa.Address) {
 			text = getSolanaWalletInfo(wa)
+		} else if help.IsValidTonAddress(wa.Address) {
+			text = getTonWalletInfo(wa)
+		} else if help.IsValidBitcoinAddress(wa.Address) || help.IsValidLitecoinAddress(wa.Address) {
+			text = getUtxoWalletInfo(wa)
 		}
 
 		if len(text) == 0 {
@@ -300,7 +306,7 @@ func cbOrderDetailAction(ctx context.Context, b *bot.Bot, u *models.Update) {
 	// 确定回调状态标签
 	var notifyStateLabel string
 	switch {
-	case order.Status == model.OrderStatusWaiting:
+	case order.Status == model.OrderStatusWaiting, order.Status == model.OrderStatusLatePaid:
 		notifyStateLabel = order.GetStatusLabel()
 	case order.Status == model.OrderStatusExpired:
 		notifyStateLabel = "🈚️没有回调"
@@ -333,6 +339,13 @@ func cbOrderDetailAction(ctx context.Context, b *bot.Bot, u *models.Update) {
 		})
 	}
 
+	if order.Status == model.OrderStatusLatePaid {
+		markup.InlineKeyboard = append(markup.InlineKeyboard, []models.InlineKeyboardButton{
+			{Text: "✅确认收款", CallbackData: cbLatePaidAccept + "|" + order.TradeId},
+			{Text: "🙈忽略", CallbackData: cbLatePaidIgnore + "|" + order.TradeId},
+		})
+	}
+
 	if len(args) == 3 {
 		markup.InlineKeyboard = append(markup.InlineKeyboard, []models.InlineKeyboardButton{
 			{Text: "📦返回订单列表", CallbackData: fmt.Sprintf("%s|%s", cbOrderList, args[2])},
@@ -342,9 +355,10 @@ func cbOrderDetailAction(ctx context.Context, b *bot.Bot, u *models.Update) {
 	text := fmt.Sprintf("```\n"+
 		"⛵️系统订单：%s\n"+
 		"📌商户订单：%s\n"+
+		"🏪所属商户：%s\n"+
 		"📊交易汇率：%s(%s)\n"+
 		"💲交易数额：%s\n"+
-		"💰交易金额：%.2f CNY\n"+
+		"💰交易金额：%v %s\n"+
 		"💍交易类别：%s\n"+
 		"🌏商户网站：%s\n"+
 		"🔋收款状态：%s\n"+
@@ -353,12 +367,14 @@ func cbOrderDetailAction(ctx context.Context, b *bot.Bot, u *models.Update) {
 		"🕒创建时间：%s\n"+
 		"🕒失效时间：%s\n"+
 		"⚖️️确认时间：%s\n"+
+		"%s"+
 		"```",
 		order.TradeId,
 		order.OrderId,
+		model.GetMerchant(order.MerchantId).Name,
 		order.TradeRate, conf.GetUsdtRate(),
 		order.Amount,
-		order.Money,
+		order.Money, order.Currency,
 		strings.ToUpper(order.TradeType),
 		site.String(),
 		order.GetStatusLabel(),
@@ -366,7 +382,8 @@ func cbOrderDetailAction(ctx context.Context, b *bot.Bot, u *models.Update) {
 		help.MaskAddress(order.Address),
 		order.CreatedAt.Format(time.DateTime),
 		order.ExpiredAt.Format(time.DateTime),
-		order.ConfirmedAt.Format(time.DateTime))
+		order.ConfirmedAt.Format(time.DateTime),
+		orderEventsText(order.TradeId))
 
 	EditMessageText(ctx, b, &bot.EditMessageTextParams{
 		ChatID:      u.CallbackQuery.Message.Message.Chat.ID,
@@ -377,6 +394,27 @@ func cbOrderDetailAction(ctx context.Context, b *bot.Bot, u *models.Update) {
 	})
 }
 
+// orderEventsText 订单状态变更记录，仅展示最近几条
+func orderEventsText(tradeId string) string {
+	var events = model.GetOrderEvents(tradeId)
+	if len(events) == 0 {
+
+		return ""
+	}
+
+	if len(events) > 8 {
+		events = events[len(events)-8:]
+	}
+
+	var text = "📜状态记录：\n"
+	for _, e := range events {
+		text += fmt.Sprintf("%s %s→%s %s %s\n", e.CreatedAt.Format(time.DateTime),
+			model.OrderStatusName(e.FromStatus), model.OrderStatusName(e.ToStatus), e.Actor, e.Reason)
+	}
+
+	return text
+}
+
 func cbOrderListAction(ctx context.Context, b *bot.Bot, u *models.Update) {
 	page := cast.ToInt(getArg(ctx, 1))
 	buttons := buildOrderListWithNavigation(page)
@@ -404,10 +442,19 @@ func cbMarkNotifySuccAction(ctx context.Context, b *bot.Bot, u *models.Update) {
 func dbOrderNotifyRetryAction(ctx context.Context, b *bot.Bot, u *models.Update) {
 	var tradeId = getArg(ctx, 1)
 
-	model.DB.Model(&model.TradeOrders{}).Where("trade_id = ?", tradeId).UpdateColumn("notify_num", gorm.Expr("notify_num - ?", 1))
+	order, ok := model.GetTradeOrder(tradeId)
+	if !ok {
+
+		return
+	}
+
+	var text = fmt.Sprintf("🪧订单（`%s`）即将开始回调重试，稍后可再次查询。", tradeId)
+	if err := order.RetryNotify(); err != nil {
+		text = fmt.Sprintf("❌订单（`%s`）回调重试失败：%s", tradeId, bot.EscapeMarkdown(err.Error()))
+	}
 
 	SendMessage(&bot.SendMessageParams{
-		Text:      fmt.Sprintf("🪧订单（`%s`）即将开始回调重试，稍后可再次查询。", tradeId),
+		Text:      text,
 		ParseMode: models.ParseModeMarkdown,
 	})
 }
@@ -415,14 +462,86 @@ func dbOrderNotifyRetryAction(ctx context.Context, b *bot.Bot, u *models.Update)
 func dbMarkOrderSuccAction(ctx context.Context, b *bot.Bot, u *models.Update) {
 	var tradeId = getArg(ctx, 1)
 
-	model.DB.Model(&model.TradeOrders{}).Where("trade_id = ?", tradeId).UpdateColumn("status", model.OrderStatusSuccess)
+	order, ok := model.GetTradeOrder(tradeId)
+	if !ok {
+
+		return
+	}
+
+	var text = fmt.Sprintf("🪧订单（`%s`）已经标记为收款成功，稍后可再次查询。", tradeId)
+	if err := order.SetPaid(model.ActorBot, "手动标记已支付"); err != nil {
+		text = fmt.Sprintf("❌订单（`%s`）标记失败：%s", tradeId, bot.EscapeMarkdown(err.Error()))
+	}
 
 	SendMessage(&bot.SendMessageParams{
-		Text:      fmt.Sprintf("🪧订单（`%s`）已经标记为收款成功，稍后可再次查询。", tradeId),
+		Text:      text,
 		ParseMode: models.ParseModeMarkdown,
 	})
 }
 
+func cbLatePaidAcceptAction(ctx context.Context, b *bot.Bot, u *models.Update) {
+	var tradeId = getArg(ctx, 1)
+
+	order, ok := model.GetTradeOrder(tradeId)
+	if !ok {
+
+		return
+	}
+
+	var text = fmt.Sprintf("✅订单（`%s`）已确认收款，稍后将回调通知商户。", tradeId)
+	if err := order.AcceptLatePaid(model.ActorBot); err != nil {
+		text = fmt.Sprintf("❌订单（`%s`）确认收款失败：%s", tradeId, bot.EscapeMarkdown(err.Error()))
+	}
+
+	SendMessage(&bot.SendMessageParams{
+		Text:      text,
+		ParseMode: models.ParseModeMarkdown,
+	})
+}
+
+func cbLatePaidIgnoreAction(ctx context.Context, b *bot.Bot, u *models.Update) {
+	var tradeId = getArg(ctx, 1)
+
+	order, ok := model.GetTradeOrder(tradeId)
+	if !ok {
+
+		return
+	}
+
+	var text = fmt.Sprintf("🙈订单（`%s`）过期后支付已忽略，订单保持过期状态。", tradeId)
+	if err := order.IgnoreLatePaid(); err != nil {
+		text = fmt.Sprintf("❌订单（`%s`）忽略失败：%s", tradeId, bot.EscapeMarkdown(err.Error()))
+	}
+
+	SendMessage(&bot.SendMessageParams{
+		Text:      text,
+		ParseMode: models.ParseModeMarkdown,
+	})
+}
+
+func cbUnmatchedAttachAction(ctx context.Context, b *bot.Bot, u *models.Update) {
+	var id = getArg(ctx, 1)
+	var k = fmt.Sprintf("%s_%d_payment_id", cbUnmatchedAttach, u.CallbackQuery.Message.Message.Chat.ID)
+
+	p, ok := model.GetUnmatchedPayment(cast.ToInt64(id))
+	if !ok {
+
+		return
+	}
+
+	cache.Set(k, id, -1)
+
+	SendMessage(&bot.SendMessageParams{
+		Text:   fmt.Sprintf("🧐 收款 %s %s（%s）请回复%s", p.Amount, strings.ToUpper(p.TradeType), help.MaskHash(p.TxHash), unmatchedAttachText),
+		ChatID: u.CallbackQuery.Message.Message.Chat.ID,
+		ReplyMarkup: &models.ForceReply{
+			ForceReply:            true,
+			Selective:             true,
+			InputFieldPlaceholder: "",
+		},
+	})
+}
+
 func getTronWalletInfo(address string) string {
 	var client = http.Client{Timeout: time.Second * 5}
 	resp, err := client.Get("https://apilist.tronscanapi.com/api/accountv2?address=" + address)
@@ -484,6 +603,16 @@ func getSolanaWalletInfo(wa model.WalletAddress) string {
 	return fmt.Sprintf(">💲余额：%s\\(%s\\)\n>☘️地址：`%s`", help.Ec(solTokenBalanceOf(wa)), help.Ec(wa.TradeType), wa.Address)
 }
 
+func getTonWalletInfo(wa model.WalletAddress) string {
+
+	return fmt.Sprintf(">💲余额：%s\\(%s\\)\n>☘️地址：`%s`", help.Ec(tonTokenBalanceOf(wa)), help.Ec(wa.TradeType), wa.Address)
+}
+
+func getUtxoWalletInfo(wa model.WalletAddress) string {
+
+	return fmt.Sprintf(">💲余额：%s\\(%s\\)\n>☘️地址：`%s`", help.Ec(utxoBalanceOf(wa)), help.Ec(wa.TradeType), wa.Address)
+}
+
 func getEvmWalletInfo(wa model.WalletAddress) string {
 
 	return fmt.Sprintf(">💲余额：%s\\(%s\\)\n>☘️地址：`%s`", help.Ec(evmTokenBalanceOf(wa)), help.Ec(wa.TradeType), wa.Address)
@@ -492,9 +621,12 @@ func getEvmWalletInfo(wa model.WalletAddress) string {
 func solTokenBalanceOf(wa model.WalletAddress) string {
 	var jsonData = []byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"getTokenAccountsByOwner","params":["%s",{"mint": "%s"},{"commitment":"finalized","encoding":"jsonParsed"}]}`,
 		wa.Address, wa.GetTokenContract()))
+	if wa.GetTokenContract() == "" { // 原生币
+		jsonData = []byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"getBalance","params":["%s",{"commitment":"finalized"}]}`, wa.Address))
+	}
 
 	var client = &http.Client{Timeout: time.Second * 5}
-	resp, err := client.Post(conf.GetSolanaRpcEndpoint(), "application/json", bytes.NewBuffer(jsonData))
+	resp, err := client.Post(conf.GetRpcEndpoint(conf.Solana), "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
 		log.Warn("Error Post response:", err)
 
@@ -516,6 +648,12 @@ func solTokenBalanceOf(wa model.WalletAddress) string {
 	}
 
 	sum := new(big.Int)
+	if wa.GetTokenContract() == "" {
+		sum.SetInt64(gjson.GetBytes(body, "result.value").Int())
+
+		return decimal.NewFromBigInt(sum, wa.GetTokenDecimals()).String()
+	}
+
 	values := gjson.GetBytes(body, "result.value").Array()
 	for _, v := range values {
 		amountStr := v.Get("account.data.parsed.info.tokenAmount.amount").String()
@@ -533,7 +671,7 @@ func solTokenBalanceOf(wa model.WalletAddress) string {
 
 func aptTokenBalanceOf(wa model.WalletAddress) string {
 	var client = http.Client{Timeout: time.Second * 5}
-	resp, err := client.Get(fmt.Sprintf("%sv1/accounts/%s/balance/%s", conf.GetAptosRpcNode(), wa.Address, strings.ToLower(wa.GetTokenContract())))
+	resp, err := client.Get(fmt.Sprintf("%sv1/accounts/%s/balance/%s", conf.GetRpcEndpoint(conf.Aptos), wa.Address, strings.ToLower(wa.GetTokenContract())))
 	if err != nil {
 		log.Error("getAptosWalletInfo client.Get(url)", err)
 
@@ -559,9 +697,79 @@ func aptTokenBalanceOf(wa model.WalletAddress) string {
 	return decimal.NewFromBigInt(result, wa.GetTokenDecimals()).String()
 }
 
+func tonTokenBalanceOf(wa model.WalletAddress) string {
+	var client = http.Client{Timeout: time.Second * 5}
+	resp, err := client.Get(fmt.Sprintf("%sjetton/wallets?owner_address=%s&jetton_address=%s&limit=1", conf.GetRpcEndpoint(conf.Ton), wa.Address, wa.GetTokenContract()))
+	if err != nil {
+		log.Error("tonTokenBalanceOf client.Get(url)", err)
+
+		return "0.00"
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		log.Error("tonTokenBalanceOf resp.StatusCode != 200", resp.StatusCode, err)
+
+		return "0.00"
+	}
+
+	all, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Error("tonTokenBalanceOf io.ReadAll(resp.Body)", err)
+
+		return "0.00"
+	}
+
+	result, ok := new(big.Int).SetString(gjson.GetBytes(all, "jetton_wallets.0.balance").String(), 10)
+	if !ok {
+
+		return "0.00"
+	}
+
+	return decimal.NewFromBigInt(result, wa.GetTokenDecimals()).String()
+}
+
+func utxoBalanceOf(wa model.WalletAddress) string {
+	var net = conf.Bitcoin
+	if wa.TradeType == model.OrderTradeTypeLtcLitecoin {
+		net = conf.Litecoin
+	}
+
+	var client = http.Client{Timeout: time.Second * 5}
+	resp, err := client.Get(conf.GetRpcEndpoint(net) + "address/" + wa.Address)
+	if err != nil {
+		log.Error("utxoBalanceOf client.Get(url)", err)
+
+		return "0.00"
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		log.Error("utxoBalanceOf resp.StatusCode != 200", resp.StatusCode, err)
+
+		return "0.00"
+	}
+
+	all, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Error("utxoBalanceOf io.ReadAll(resp.Body)", err)
+
+		return "0.00"
+	}
+
+	var data = gjson.ParseBytes(all)
+	var sats = data.Get("chain_stats.funded_txo_sum").Int() - data.Get("chain_stats.spent_txo_sum").Int()
+
+	return decimal.New(sats, wa.GetTokenDecimals()).String()
+}
+
 func evmTokenBalanceOf(wa model.WalletAddress) string {
 	var jsonData = []byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"eth_call","params":[{"from":"0x0000000000000000000000000000000000000000","data":"0x70a08231000000000000000000000000%s","to":"%s"},"latest"]}`,
 		time.Now().Unix(), strings.ToLower(strings.Trim(wa.Address, "0x")), strings.ToLower(wa.GetTokenContract())))
+	if wa.GetTokenContract() == "" { // 原生币
+		jsonData = []byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"eth_getBalance","params":["%s","latest"]}`, time.Now().Unix(), strings.ToLower(wa.Address)))
+	}
+
 	var client = &http.Client{Timeout: time.Second * 5}
 	resp, err := client.Post(wa.GetEvmRpcEndpoint(), "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-telegram/bot"
@@ -19,10 +21,15 @@ const cmdGetId = "id"
 const cmdStart = "start"
 const cmdState = "state"
 const cmdOrder = "order"
+const cmdUnmatched = "unmatched"
+const cmdRate = "rate"
+
+const rateChartPoints = 24 // 汇率走势图展示的记录数
 
 const replayAddressText = "🚚 请发送需要添加的钱包地址，也可以用“钱包名称:钱包地址”这种格式来指定名称"
 const orderListText = "*现有订单列表，点击可查看详细信息，不同颜色对应着不同支付状态！*\n>🟢收款成功 🔴交易过期 🟡等待支付 ⚪️订单取消\n>🌟按钮内容 订单创建时间 订单号末八位 交易金额"
 const orderPageSize = 8
+const unmatchedAttachText = "需要关联的系统订单号"
 
 func cmdGetIdHandle(ctx context.Context, b *bot.Bot, u *models.Update) {
 
@@ -39,7 +46,7 @@ func cmdGetIdHandle(ctx context.Context, b *bot.Bot, u *models.Update) {
 func cmdStartHandle(ctx context.Context, b *bot.Bot, u *models.Update) {
 	var was []model.WalletAddress
 	var btn [][]models.InlineKeyboardButton
-	if model.DB.Find(&was).Error == nil {
+	if model.DB.Where("derive_path = ''").Find(&was).Error == nil {
 		for _, wa := range was {
 			var text string
 			var walletAddr string
@@ -81,7 +88,7 @@ func cmdStateHandle(ctx context.Context, b *bot.Bot, u *models.Update) {
 	var rows []model.TradeOrders
 	model.DB.Where("created_at > ?", time.Now().Format(time.DateOnly)).Find(&rows)
 	var succ uint64
-	var money float64
+	var money = make(map[string]float64)
 
 	var types []string
 	model.DB.Model(&model.WalletAddress{}).Distinct("trade_type").Where("status = ?", model.StatusEnable).Pluck("trade_type", &types)
@@ -98,7 +105,7 @@ func cmdStateHandle(ctx context.Context, b *bot.Bot, u *models.Update) {
 			continue
 		}
 		succ++
-		money += o.Money
+		money[o.Currency] += o.Money
 
 		// 只统计启用类型的金额
 		if _, exists := typeAmounts[o.TradeType]; exists {
@@ -111,7 +118,10 @@ func cmdStateHandle(ctx context.Context, b *bot.Bot, u *models.Update) {
 	text += fmt.Sprintf("🎁今日成功订单：%d\n", succ)
 	text += fmt.Sprintf("💎今日总数订单：%d\n", len(rows))
 	text += "💰今日收款汇总\n"
-	text += fmt.Sprintf(" - %.2f CNY\n", money)
+	for _, c := range sortedCurrencies(money) {
+		text += fmt.Sprintf(" - %.2f %s\n", money[c], c)
+	}
+	text += merchantStateText(rows)
 
 	// 动态显示启用类型的收款汇总
 	typeDisplayNames := map[string]string{
@@ -124,6 +134,7 @@ func cmdStateHandle(ctx context.Context, b *bot.Bot, u *models.Update) {
 		model.OrderTradeTypeUsdtSolana:   "USDT.Solana",
 		model.OrderTradeTypeUsdtPolygon:  "USDT.Polygon",
 		model.OrderTradeTypeUsdtArbitrum: "USDT.Arbitrum",
+		model.OrderTradeTypeUsdtTon:      "USDT.Ton",
 		model.OrderTradeTypeUsdcTrc20:    "USDC.Trc20",
 		model.OrderTradeTypeUsdcErc20:    "USDC.Erc20",
 		model.OrderTradeTypeUsdcBep20:    "USDC.Bep20",
@@ -133,11 +144,33 @@ func cmdStateHandle(ctx context.Context, b *bot.Bot, u *models.Update) {
 		model.OrderTradeTypeUsdcPolygon:  "USDC.Polygon",
 		model.OrderTradeTypeUsdcArbitrum: "USDC.Arbitrum",
 		model.OrderTradeTypeUsdcBase:     "USDC.Base",
+		model.OrderTradeTypeEthErc20:     "ETH.Erc20",
+		model.OrderTradeTypeEthArbitrum:  "ETH.Arbitrum",
+		model.OrderTradeTypeEthBase:      "ETH.Base",
+		model.OrderTradeTypeBnbBep20:     "BNB.Bep20",
+		model.OrderTradeTypePolPolygon:   "POL.Polygon",
+		model.OrderTradeTypeOkbXlayer:    "OKB.Xlayer",
+		model.OrderTradeTypeSolSolana:    "SOL.Solana",
+		model.OrderTradeTypeAptAptos:     "APT.Aptos",
+		model.OrderTradeTypeBtcBitcoin:   "BTC.Bitcoin",
+		model.OrderTradeTypeLtcLitecoin:  "LTC.Litecoin",
+	}
+
+	// 配置文件声明的通用 EVM 网络
+	for _, c := range conf.GetChains() {
+		for _, t := range c.Tokens {
+			typeDisplayNames[t.TradeType] = t.Symbol + "." + c.Name
+		}
 	}
 
 	for _, t := range types {
 		if displayName, exists := typeDisplayNames[t]; exists {
-			text += fmt.Sprintf(" - %.2f %s\n", typeAmounts[t], displayName)
+			var prec = 2
+			if token, _ := model.GetTokenType(t); token != model.TokenTypeUSDT && token != model.TokenTypeUSDC && token != model.TokenTypeTRX {
+				prec = 6 // 原生币单价较高，保留更多小数位
+			}
+
+			text += fmt.Sprintf(" - %.*f %s\n", prec, typeAmounts[t], displayName)
 		}
 	}
 
@@ -153,7 +186,18 @@ func cmdStateHandle(ctx context.Context, b *bot.Bot, u *models.Update) {
 		model.OrderTradeTypeUsdtPolygon:  conf.Polygon,
 		model.OrderTradeTypeUsdtArbitrum: conf.Arbitrum,
 		model.OrderTradeTypeUsdtErc20:    conf.Ethereum,
+		model.OrderTradeTypeUsdtTon:      conf.Ton,
 		model.OrderTradeTypeUsdcBase:     conf.Base,
+		model.OrderTradeTypeEthErc20:     conf.Ethereum,
+		model.OrderTradeTypeEthArbitrum:  conf.Arbitrum,
+		model.OrderTradeTypeEthBase:      conf.Base,
+		model.OrderTradeTypeBnbBep20:     conf.Bsc,
+		model.OrderTradeTypePolPolygon:   conf.Polygon,
+		model.OrderTradeTypeOkbXlayer:    conf.Xlayer,
+		model.OrderTradeTypeSolSolana:    conf.Solana,
+		model.OrderTradeTypeAptAptos:     conf.Aptos,
+		model.OrderTradeTypeBtcBitcoin:   conf.Bitcoin,
+		model.OrderTradeTypeLtcLitecoin:  conf.Litecoin,
 	}
 
 	blockchainNames := map[string]string{
@@ -166,6 +210,17 @@ func cmdStateHandle(ctx context.Context, b *bot.Bot, u *models.Update) {
 		conf.Arbitrum: "Arbitrum",
 		conf.Ethereum: "Ethereum",
 		conf.Base:     "Base",
+		conf.Ton:      "Ton",
+		conf.Bitcoin:  "Bitcoin",
+		conf.Litecoin: "Litecoin",
+	}
+
+	// 配置文件声明的通用 EVM 网络
+	for _, c := range conf.GetChains() {
+		blockchainNames[c.Network] = c.Name
+		for _, t := range c.Tokens {
+			blockchainMap[t.TradeType] = c.Network
+		}
 	}
 
 	// 收集需要显示的区块链
@@ -196,12 +251,20 @@ func cmdStateHandle(ctx context.Context, b *bot.Bot, u *models.Update) {
 	// 按排序后的顺序显示区块链数据
 	for _, blockchain := range blockchains {
 		text += fmt.Sprintf(" - %s %s\n", blockchainNames[blockchain], conf.GetBlockSuccRate(blockchain))
+		for _, ep := range conf.GetEndpointStats(blockchain) {
+			var state = "✅"
+			if ep.Ejected {
+				state = "⛔"
+			}
+
+			text += fmt.Sprintf("   %s %s %dms %d/%d\n", state, ep.Host, ep.Latency.Milliseconds(), ep.Total-ep.Fail, ep.Total)
+		}
 	}
 
 	text += "-----------------------\n"
-	text += fmt.Sprintf("🪧基准汇率(TRX)：%v\n", cast.ToString(rate.GetOkxTrxRawRate()))
-	text += fmt.Sprintf("🪧基准汇率(USDT)：%v\n", cast.ToString(rate.GetOkxUsdtRawRate()))
-	text += fmt.Sprintf("🪧基准汇率(USDC)：%v\n", cast.ToString(rate.GetOkxUsdcRawRate()))
+	text += fmt.Sprintf("🪧基准汇率(TRX)：%v\n", cast.ToString(rate.GetTrxRawRate()))
+	text += fmt.Sprintf("🪧基准汇率(USDT)：%v\n", cast.ToString(rate.GetUsdtRawRate()))
+	text += fmt.Sprintf("🪧基准汇率(USDC)：%v\n", cast.ToString(rate.GetUsdcRawRate()))
 	text += fmt.Sprintf("✅订单汇率(TRX)：%v\n", cast.ToString(rate.GetTrxCalcRate()))
 	text += fmt.Sprintf("✅订单汇率(USDT)：%v\n", cast.ToString(rate.GetUsdtCalcRate()))
 	text += fmt.Sprintf("✅订单汇率(USDC)：%v\n", cast.ToString(rate.GetUsdcCalcRate()))
@@ -209,7 +272,8 @@ func cmdStateHandle(ctx context.Context, b *bot.Bot, u *models.Update) {
 	text += "```\n"
 	text += ">基准汇率：来源于交易所的原始数据。\n"
 	text += ">订单汇率：订单创建过程中实际使用的汇率。\n"
-	text += ">扫块成功数据：如果该值过低，说明您的服务器与区块链网络连接不稳定，请尝试更换区块节点。"
+	text += ">扫块成功数据：如果该值过低，说明您的服务器与区块链网络连接不稳定，请尝试更换区块节点。\n"
+	text += ">节点数据：平均耗时 成功/总请求数，⛔表示节点连续失败已被暂时剔除，冷却后自动恢复。"
 
 	SendMessage(&bot.SendMessageParams{
 		ChatID:    u.Message.Chat.ID,
@@ -289,3 +353,134 @@ func buildPageNavigation(page, total, size int) [][]models.InlineKeyboardButton
 		nextBtn,
 	}}
 }
+
+func cmdUnmatchedHandle(ctx context.Context, b *bot.Bot, u *models.Update) {
+	rows, total := model.GetUnmatchedPayments(1, orderPageSize)
+	if total == 0 {
+		SendMessage(&bot.SendMessageParams{ChatID: u.Message.Chat.ID, Text: "🈚️暂无未匹配订单的收款"})
+
+		return
+	}
+
+	var btn [][]models.InlineKeyboardButton
+	for _, p := range rows {
+		btn = append(btn, []models.InlineKeyboardButton{
+			{Text: fmt.Sprintf("💲%s %s %s", p.Amount, p.TradeType, p.PaidAt.Format("01-02 15:04")), CallbackData: fmt.Sprintf("%s|%d", cbUnmatchedAttach, p.ID)},
+		})
+	}
+
+	SendMessage(&bot.SendMessageParams{
+		ChatID:      u.Message.Chat.ID,
+		Text:        fmt.Sprintf("*未匹配订单的收款共 %d 笔，点击可关联到订单*\n>🌟按钮内容 支付数额 交易类别 交易时间", total),
+		ParseMode:   models.ParseModeMarkdown,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: btn},
+	})
+}
+
+// merchantStateText 今日各商户成功订单及收款金额，未配置多商户时不显示
+func merchantStateText(rows []model.TradeOrders) string {
+	var merchants = model.GetMerchants()
+	if len(merchants) == 0 {
+
+		return ""
+	}
+
+	var succ = make(map[int64]int)
+	var money = make(map[int64]map[string]float64)
+	for _, o := range rows {
+		if o.Status == model.OrderStatusSuccess {
+			succ[o.MerchantId]++
+			if money[o.MerchantId] == nil {
+				money[o.MerchantId] = make(map[string]float64)
+			}
+
+			money[o.MerchantId][o.Currency] += o.Money
+		}
+	}
+
+	var text = "🏪今日商户收款\n"
+	for _, m := range append([]model.Merchant{model.DefaultMerchant()}, merchants...) {
+		var amounts = make([]string, 0)
+		for _, c := range sortedCurrencies(money[m.ID]) {
+			amounts = append(amounts, fmt.Sprintf("%.2f %s", money[m.ID][c], c))
+		}
+		if len(amounts) == 0 {
+			amounts = append(amounts, "0.00")
+		}
+
+		text += fmt.Sprintf(" - %s：%d 单 %s\n", m.Name, succ[m.ID], strings.Join(amounts, " / "))
+	}
+
+	return text
+}
+
+// sortedCurrencies 按法币代码排序，保证统计输出顺序稳定
+func sortedCurrencies(money map[string]float64) []string {
+	var result = make([]string, 0, len(money))
+	for c := range money {
+		result = append(result, c)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// cmdRateHandle 汇率走势，默认展示 USDT USDC TRX 及已启用法币，/rate ETH 可查看指定代币
+func cmdRateHandle(ctx context.Context, b *bot.Bot, u *models.Update) {
+	var pairs = [][2]string{{"USDT", rate.FiatCNY}, {"USDC", rate.FiatCNY}, {"TRX", rate.FiatCNY}}
+	if args := strings.Fields(u.Message.Text); len(args) > 1 {
+		pairs = [][2]string{{strings.ToUpper(args[1]), rate.FiatCNY}}
+	} else {
+		for _, fiat := range conf.GetCurrencies() {
+			pairs = append(pairs, [2]string{"USDT", fiat})
+		}
+	}
+
+	var text = "```\n"
+	for _, p := range pairs {
+		text += rateChartText(p[0], p[1])
+	}
+	text += "```\n"
+	text += fmt.Sprintf(">走势图为最近 %d 次被采纳的聚合汇率，⚠️表示汇率已过期或尚未获取成功。", rateChartPoints)
+
+	SendMessage(&bot.SendMessageParams{
+		ChatID:    u.Message.Chat.ID,
+		Text:      text,
+		ParseMode: models.ParseModeMarkdown,
+	})
+}
+
+func rateChartText(token, fiat string) string {
+	var rows = model.GetRecentRates(token, fiat, rateChartPoints)
+	if len(rows) == 0 {
+
+		return fmt.Sprintf("⚠️%s/%s：暂无汇率记录\n\n", token, fiat)
+	}
+
+	var low, high = rows[0].Rate, rows[0].Rate
+	for _, r := range rows {
+		low, high = math.Min(low, r.Rate), math.Max(high, r.Rate)
+	}
+
+	var bars = []rune("▁▂▃▄▅▆▇█")
+	var chart = make([]rune, 0, len(rows))
+	for _, r := range rows {
+		var idx = 0
+		if high > low {
+			idx = int((r.Rate-low)/(high-low)*float64(len(bars)-1) + 0.5)
+		}
+
+		chart = append(chart, bars[idx])
+	}
+
+	var state = "📈"
+	if rate.CheckFresh(token, fiat) != nil {
+		state = "⚠️"
+	}
+
+	var last = rows[len(rows)-1]
+
+	return fmt.Sprintf("%s%s/%s 最新 %v\n%s\n 最低 %v 最高 %v\n 更新 %s\n\n",
+		state, token, fiat, last.Rate, string(chart), low, high, last.CreatedAt.Format(time.DateTime))
+}
@@ -21,6 +21,8 @@ func defaultHandle(ctx context.Context, bot *bot.Bot, u *models.Update) {
 			if id, ok := isRenameMode(u); ok {
 				renameAddress(u, id)
 			}
+		} else if strings.HasSuffix(u.Message.ReplyToMessage.Text, unmatchedAttachText) {
+			attachUnmatchedPayment(u)
 		}
 
 		return
@@ -43,7 +45,8 @@ func addWalletAddress(u *models.Update) {
 		name = strings.TrimSpace(parts[0])
 		address = strings.TrimSpace(parts[1])
 	}
-	if !help.IsValidTronAddress(address) && !help.IsValidEvmAddress(address) && !help.IsValidSolanaAddress(address) && !help.IsValidAptosAddress(address) {
+	if !help.IsValidTronAddress(address) && !help.IsValidEvmAddress(address) && !help.IsValidSolanaAddress(address) && !help.IsValidAptosAddress(address) && !help.IsValidTonAddress(address) &&
+		!help.IsValidBitcoinAddress(address) && !help.IsValidLitecoinAddress(address) {
 		SendMessage(&bot.SendMessageParams{Text: "钱包地址不合法"})
 
 		return
@@ -144,3 +147,33 @@ func renameAddress(u *models.Update, id int64) {
 	// 推送最新状态
 	cmdStartHandle(context.Background(), api, u)
 }
+
+func attachUnmatchedPayment(u *models.Update) {
+	var k = fmt.Sprintf("%s_%d_payment_id", cbUnmatchedAttach, u.Message.Chat.ID)
+	v, ok := cache.Get(k)
+	if !ok {
+
+		return
+	}
+
+	p, ok := model.GetUnmatchedPayment(cast.ToInt64(v))
+	if !ok {
+		SendMessage(&bot.SendMessageParams{Text: "❌收款记录不存在"})
+
+		return
+	}
+
+	order, err := p.Attach(strings.TrimSpace(u.Message.Text), model.ActorBot)
+	if err != nil {
+		SendMessage(&bot.SendMessageParams{Text: "❌关联订单失败，" + err.Error()})
+
+		return
+	}
+
+	cache.Cache.Delete(k)
+
+	SendMessage(&bot.SendMessageParams{
+		Text:      fmt.Sprintf("✅收款已关联订单（`%s`），稍后将回调通知商户。", order.TradeId),
+		ParseMode: models.ParseModeMarkdown,
+	})
+}
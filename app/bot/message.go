@@ -13,17 +13,16 @@ import (
 	"github.com/v03413/bepusdt/app/model"
 )
 
-func SendTradeSuccMsg(order model.TradeOrders) {
+func SendTradeSuccMsg(order model.TradeOrders) error {
 	if order.Status != model.OrderStatusSuccess {
 
-		return
+		return nil
 	}
 
 	// 获取代币类型
 	tokenType, err := model.GetTokenType(order.TradeType)
 	if err != nil {
-		SendMessage(&bot.SendMessageParams{Text: "❌交易类型不支持：" + order.TradeType})
-		return
+		return SendMessage(&bot.SendMessageParams{Text: "❌交易类型不支持：" + order.TradeType})
 	}
 
 	tradeType := string(tokenType)
@@ -33,8 +32,10 @@ func SendTradeSuccMsg(order model.TradeOrders) {
 \-\-\-
 ` + "```" + `
 🚦商户订单：%v
-💰请求金额：%v CNY(%v)
+🏪所属商户：%v
+💰请求金额：%v ` + order.Currency + `(%v)
 💲支付数额：%v ` + order.TradeType + `
+💵实收数额：%v ` + order.TradeType + `
 💎交易哈希：%s
 ✅收款地址：%s
 ⏱️创建时间：%s
@@ -43,16 +44,18 @@ func SendTradeSuccMsg(order model.TradeOrders) {
 `
 	text = fmt.Sprintf(text,
 		order.OrderId,
+		model.GetMerchant(order.MerchantId).Name,
 		order.Money,
 		order.TradeRate,
 		order.Amount,
+		order.ActualAmount,
 		help.MaskHash(order.TradeHash),
 		help.MaskAddress(order.Address),
 		order.CreatedAt.Format(time.DateTime),
 		order.UpdatedAt.Format(time.DateTime),
 	)
 
-	SendMessage(&bot.SendMessageParams{
+	return SendMessage(&bot.SendMessageParams{
 		Text:      text,
 		ChatID:    conf.BotNotifyTarget(),
 		ParseMode: models.ParseModeMarkdown,
@@ -66,12 +69,12 @@ func SendTradeSuccMsg(order model.TradeOrders) {
 	})
 }
 
-func SendNotifyFailed(o model.TradeOrders, reason string) {
+func SendNotifyFailed(o model.TradeOrders, reason string) error {
 	// 获取代币类型
 	tokenType, err := model.GetTokenType(o.TradeType)
 	if err != nil {
-		SendMessage(&bot.SendMessageParams{Text: "❌交易类型不支持：" + o.TradeType})
-		return
+
+		return SendMessage(&bot.SendMessageParams{Text: "❌交易类型不支持：" + o.TradeType})
 	}
 
 	tradeType := string(tokenType)
@@ -82,7 +85,7 @@ func SendNotifyFailed(o model.TradeOrders, reason string) {
 `+"```"+`
 🚦商户订单：%v
 💲支付数额：%v
-💰请求金额：%v CNY(%v)
+💰请求金额：%v %s(%v)
 💍交易类别：%s
 ⚖️️确认时间：%s
 ⏰下次回调：%s
@@ -91,14 +94,14 @@ func SendNotifyFailed(o model.TradeOrders, reason string) {
 `,
 		help.Ec(o.OrderId),
 		o.Amount,
-		o.Money, o.TradeRate,
+		o.Money, o.Currency, o.TradeRate,
 		strings.ToUpper(o.TradeType),
 		o.ConfirmedAt.Format(time.DateTime),
-		help.CalcNextNotifyTime(o.ConfirmedAt, o.NotifyNum+1).Format(time.DateTime),
+		time.Now().Add(model.NotifyRetryDelay(o.NotifyNum)).Format(time.DateTime),
 		reason,
 	)
 
-	SendMessage(&bot.SendMessageParams{
+	return SendMessage(&bot.SendMessageParams{
 		Text:      text,
 		ChatID:    conf.BotNotifyTarget(),
 		ParseMode: models.ParseModeMarkdown,
@@ -113,6 +116,84 @@ func SendNotifyFailed(o model.TradeOrders, reason string) {
 	})
 }
 
+func SendOrderRollbackMsg(o model.TradeOrders, hash string, blockNum int64) error {
+	var text = fmt.Sprintf(`
+\#区块重组 \#订单回滚
+\-\-\-
+`+"```"+`
+🚦商户订单：%v
+💲支付数额：%v
+💍交易类别：%s
+💎交易哈希：%s
+🧱所在区块：%d
+⏱️回滚时间：%s
+`+"```"+`
+>交易已不在链上，订单已回滚为等待支付状态。
+`,
+		help.Ec(o.OrderId),
+		o.Amount,
+		strings.ToUpper(o.TradeType),
+		help.MaskHash(hash),
+		blockNum,
+		time.Now().Format(time.DateTime),
+	)
+
+	return SendMessage(&bot.SendMessageParams{
+		Text:      text,
+		ChatID:    conf.BotNotifyTarget(),
+		ParseMode: models.ParseModeMarkdown,
+		ReplyMarkup: &models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{
+				{
+					models.InlineKeyboardButton{Text: "📝查看收款详情", CallbackData: fmt.Sprintf("%s|%v", cbOrderDetail, o.TradeId)},
+				},
+			},
+		},
+	})
+}
+
+func SendLatePaidMsg(o model.TradeOrders) error {
+	var text = fmt.Sprintf(`
+\#过期后支付 \#订单交易
+\-\-\-
+`+"```"+`
+🚦商户订单：%v
+💲支付数额：%v
+💍交易类别：%s
+💎交易哈希：%s
+✅收款地址：%s
+🕒失效时间：%s
+️🎯️支付时间：%s
+`+"```"+`
+>订单过期后才收到支付，请核实交易后选择是否确认收款。
+`,
+		help.Ec(o.OrderId),
+		o.PaidAmount,
+		strings.ToUpper(o.TradeType),
+		help.MaskHash(o.TradeHash),
+		help.MaskAddress(o.Address),
+		o.ExpiredAt.Format(time.DateTime),
+		o.ConfirmedAt.Format(time.DateTime),
+	)
+
+	return SendMessage(&bot.SendMessageParams{
+		Text:      text,
+		ChatID:    conf.BotNotifyTarget(),
+		ParseMode: models.ParseModeMarkdown,
+		ReplyMarkup: &models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{
+				{
+					models.InlineKeyboardButton{Text: "📝查看交易明细", URL: o.GetDetailUrl()},
+				},
+				{
+					models.InlineKeyboardButton{Text: "✅确认收款", CallbackData: fmt.Sprintf("%s|%v", cbLatePaidAccept, o.TradeId)},
+					models.InlineKeyboardButton{Text: "🙈忽略", CallbackData: fmt.Sprintf("%s|%v", cbLatePaidIgnore, o.TradeId)},
+				},
+			},
+		},
+	})
+}
+
 func Welcome() string {
 	return `
 👋 欢迎使用 ` + conf.GetAppName() + `，一站式稳定币收款解决方案，支持 USDT / USDC，轻松集成，无需复杂配置。
@@ -0,0 +1,131 @@
+package conf
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Chain 配置文件 [[chains]] 声明的通用 EVM 网络，无需修改代码即可接入新的 EVM 网络及代币
+type Chain struct {
+	Network       string       `toml:"network"`       // 网络标识，如 optimism
+	Name          string       `toml:"name"`          // 显示名称，如 Optimism
+	Rpc           Endpoints    `toml:"rpc"`           // RPC节点
+	Confirmations int64        `toml:"confirmations"` // 交易确认所需区块数
+	Explorer      string       `toml:"explorer"`      // 交易详情地址模板，{hash} 替换为交易哈希
+	NativeCoin    string       `toml:"native_coin"`   // 网络原生币，收银台提示用
+	Tokens        []ChainToken `toml:"tokens"`
+}
+
+// ChainToken 通用 EVM 网络下的 ERC20 代币
+type ChainToken struct {
+	Symbol    string `toml:"symbol"`     // 代币符号，目前支持 USDT USDC，汇率及原子精度与之对应
+	Contract  string `toml:"contract"`   // 合约地址
+	Decimals  int32  `toml:"decimals"`   // 代币精度，如 6 18
+	TradeType string `toml:"trade_type"` // 交易类型，如 usdt.optimism
+}
+
+var evmContractReg = regexp.MustCompile(`^0x[0-9a-f]{40}$`)
+
+func GetChains() []Chain {
+
+	return cfg.Chains
+}
+
+func GetChain(network string) (Chain, bool) {
+	for _, c := range cfg.Chains {
+		if c.Network == network {
+
+			return c, true
+		}
+	}
+
+	return Chain{}, false
+}
+
+// GetChainToken 根据交易类型获取通用 EVM 网络及代币
+func GetChainToken(tradeType string) (Chain, ChainToken, bool) {
+	for _, c := range cfg.Chains {
+		for _, t := range c.Tokens {
+			if t.TradeType == tradeType {
+
+				return c, t, true
+			}
+		}
+	}
+
+	return Chain{}, ChainToken{}, false
+}
+
+func (c *Conf) checkChains() error {
+	var builtin = []string{Bsc, Tron, Aptos, Solana, Xlayer, Polygon, Arbitrum, Ethereum, Base, Ton, Bitcoin, Litecoin}
+	var networks = make(map[string]bool)
+	var tradeTypes = make(map[string]bool)
+	for i := range c.Chains {
+		var chain = &c.Chains[i]
+		chain.Network = strings.ToLower(strings.TrimSpace(chain.Network))
+		if chain.Network == "" || networks[chain.Network] || strings.Contains(chain.Network, ".") {
+
+			return fmt.Errorf("chains 网络标识 network 为空、重复或不合法：%s", chain.Network)
+		}
+
+		for _, v := range builtin {
+			if v == chain.Network {
+
+				return fmt.Errorf("chains 网络标识 %s 与内置网络冲突", chain.Network)
+			}
+		}
+
+		if len(chain.Rpc) == 0 {
+
+			return fmt.Errorf("chains %s 未配置 rpc 节点", chain.Network)
+		}
+
+		if chain.Name == "" {
+			chain.Name = chain.Network
+		}
+
+		if chain.Confirmations <= 0 {
+			chain.Confirmations = defaultChainConfirmations
+		}
+
+		if chain.NativeCoin == "" {
+			chain.NativeCoin = "ETH"
+		}
+
+		networks[chain.Network] = true
+		for j := range chain.Tokens {
+			var token = &chain.Tokens[j]
+			token.Symbol = strings.ToUpper(token.Symbol)
+			token.Contract = strings.ToLower(token.Contract)
+			token.TradeType = strings.ToLower(token.TradeType)
+			if token.Symbol != "USDT" && token.Symbol != "USDC" {
+
+				return fmt.Errorf("chains %s 代币 symbol 暂只支持 USDT USDC：%s", chain.Network, token.Symbol)
+			}
+
+			if !evmContractReg.MatchString(token.Contract) {
+
+				return fmt.Errorf("chains %s 代币合约地址不合法：%s", chain.Network, token.Contract)
+			}
+
+			if token.Decimals <= 0 {
+
+				return fmt.Errorf("chains %s 代币 %s 精度 decimals 不合法", chain.Network, token.Symbol)
+			}
+
+			if token.TradeType == "" {
+				token.TradeType = strings.ToLower(token.Symbol) + "." + chain.Network
+			}
+
+			if tradeTypes[token.TradeType] || len(strings.Split(token.TradeType, ".")) != 2 {
+
+				return fmt.Errorf("chains %s 交易类型 trade_type 重复或不合法：%s", chain.Network, token.TradeType)
+			}
+
+			tradeTypes[token.TradeType] = true
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package conf
+
+import (
+	"strings"
+	"testing"
+)
+
+const testBotConf = `
+[bot]
+token = "test"
+admin_id = 1
+`
+
+func TestLoadChains(t *testing.T) {
+	var cases = []struct {
+		name  string
+		chain string
+		err   string
+	}{
+		{"defaults filled", `
+[[chains]]
+network = " Optimism "
+rpc = "https://rpc1.example.com, https://rpc2.example.com"
+[[chains.tokens]]
+symbol = "usdt"
+contract = "0x94B008AA00579C1307B0EF2C499AD98A8CE58E58"
+decimals = 6`, ""},
+		{"builtin network", `
+[[chains]]
+network = "bsc"
+rpc = ["https://rpc.example.com"]`, "与内置网络冲突"},
+		{"duplicate network", `
+[[chains]]
+network = "optimism"
+rpc = ["https://rpc.example.com"]
+[[chains]]
+network = "optimism"
+rpc = ["https://rpc.example.com"]`, "为空、重复或不合法"},
+		{"missing rpc", `
+[[chains]]
+network = "optimism"`, "未配置 rpc 节点"},
+		{"unsupported symbol", `
+[[chains]]
+network = "optimism"
+rpc = ["https://rpc.example.com"]
+[[chains.tokens]]
+symbol = "DAI"
+contract = "0x94b008aa00579c1307b0ef2c499ad98a8ce58e58"
+decimals = 18`, "暂只支持 USDT USDC"},
+		{"invalid contract", `
+[[chains]]
+network = "optimism"
+rpc = ["https://rpc.example.com"]
+[[chains.tokens]]
+symbol = "USDT"
+contract = "0x94b008aa"
+decimals = 6`, "合约地址不合法"},
+		{"duplicate trade type", `
+[[chains]]
+network = "optimism"
+rpc = ["https://rpc.example.com"]
+[[chains.tokens]]
+symbol = "USDT"
+contract = "0x94b008aa00579c1307b0ef2c499ad98a8ce58e58"
+decimals = 6
+[[chains.tokens]]
+symbol = "USDT"
+contract = "0x01bff41798a0bcf287b996046ca68b395dbc1071"
+decimals = 6`, "重复或不合法"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := Load([]byte(testBotConf + c.chain))
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("err = %v, want %s", err, c.err)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			chain, ok := GetChain("optimism")
+			if !ok || chain.Name != "optimism" || chain.Confirmations != defaultChainConfirmations || chain.NativeCoin != "ETH" || len(chain.Rpc) != 2 {
+				t.Fatalf("unexpected chain %+v", chain)
+			}
+
+			_, token, ok := GetChainToken("usdt.optimism")
+			if !ok || token.Symbol != "USDT" || token.Contract != "0x94b008aa00579c1307b0ef2c499ad98a8ce58e58" {
+				t.Fatalf("unexpected token %+v", token)
+			}
+			if urls := GetRpcEndpoints("optimism"); len(urls) != 2 || urls[1] != "https://rpc2.example.com" {
+				t.Fatalf("unexpected rpc endpoints %v", urls)
+			}
+		})
+	}
+}
+
+// 配置校验失败时保留原有配置
+func TestLoadKeepsConfigOnError(t *testing.T) {
+	if err := Load([]byte(testBotConf + "[[chains]]\nnetwork = \"optimism\"\nrpc = [\"https://rpc.example.com\"]")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load([]byte("[[chains]]\nnetwork = \"base\"\nrpc = [\"https://rpc.example.com\"]")); err == nil {
+		t.Fatal("expected invalid config rejected")
+	}
+
+	if _, ok := GetChain("optimism"); !ok || BotAdminID() != 1 {
+		t.Fatal("config replaced by rejected data")
+	}
+}
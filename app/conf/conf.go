@@ -1,37 +1,56 @@
 package conf
 
 type Conf struct {
-	AppUri       string `toml:"app_uri"`
-	AuthToken    string `toml:"auth_token"`
-	Listen       string `toml:"listen"`
-	OutputLog    string `toml:"output_log"`
-	StaticPath   string `toml:"static_path"`
-	SqlitePath   string `toml:"sqlite_path"`
-	TronGrpcNode string `toml:"tron_grpc_node"`
-	AptosRpcNode string `toml:"aptos_rpc_node"`
-	WebhookUrl   string `toml:"webhook_url"`
+	AppUri       string    `toml:"app_uri"`
+	AuthToken    string    `toml:"auth_token"`
+	Listen       string    `toml:"listen"`
+	OutputLog    string    `toml:"output_log"`
+	StaticPath   string    `toml:"static_path"`
+	SqlitePath   string    `toml:"sqlite_path"`
+	TronGrpcNode Endpoints `toml:"tron_grpc_node"`
+	AptosRpcNode Endpoints `toml:"aptos_rpc_node"`
+	TonRpcNode   Endpoints `toml:"ton_rpc_node"`
+	WebhookUrl   string    `toml:"webhook_url"`
 	Pay          struct {
-		TrxAtom          float64  `toml:"trx_atom"`
-		TrxRate          string   `toml:"trx_rate"`
-		UsdtAtom         float64  `toml:"usdt_atom"`
-		UsdcAtom         float64  `toml:"usdc_atom"`
-		UsdtRate         string   `toml:"usdt_rate"`
-		UsdcRate         string   `toml:"usdc_rate"`
-		ExpireTime       int      `toml:"expire_time"`
-		WalletAddress    []string `toml:"wallet_address"`
-		TradeIsConfirmed bool     `toml:"trade_is_confirmed"`
-		PaymentAmountMin float64  `toml:"payment_amount_min"`
-		PaymentAmountMax float64  `toml:"payment_amount_max"`
+		TrxAtom          float64            `toml:"trx_atom"`
+		TrxRate          string             `toml:"trx_rate"`
+		CoinAtom         map[string]float64 `toml:"coin_atom"`
+		CoinRate         map[string]string  `toml:"coin_rate"`
+		UsdtAtom         float64            `toml:"usdt_atom"`
+		UsdcAtom         float64            `toml:"usdc_atom"`
+		UsdtRate         string             `toml:"usdt_rate"`
+		UsdcRate         string             `toml:"usdc_rate"`
+		ExpireTime       int                `toml:"expire_time"`
+		WalletAddress    []string           `toml:"wallet_address"`
+		TradeIsConfirmed bool               `toml:"trade_is_confirmed"`
+		PaymentAmountMin float64            `toml:"payment_amount_min"`
+		PaymentAmountMax float64            `toml:"payment_amount_max"`
+		PartialPayment   bool               `toml:"partial_payment"`
+		PartialTolerance float64            `toml:"partial_tolerance"`
+		OverpayTolerance float64            `toml:"overpay_tolerance"`
+		Currencies       []string           `toml:"currencies"`
+		QuoteExpireTime  int                `toml:"quote_expire_time"`
+		LatePaidWindow   int                `toml:"late_paid_window"`
 	} `toml:"pay"`
 	EvmRpc struct {
-		Bsc      string `toml:"bsc"`
-		Solana   string `toml:"solana"`
-		Xlayer   string `toml:"xlayer"`
-		Polygon  string `toml:"polygon"`
-		Arbitrum string `toml:"arbitrum"`
-		Ethereum string `toml:"ethereum"`
-		Base     string `toml:"base"`
+		Bsc      Endpoints `toml:"bsc"`
+		Solana   Endpoints `toml:"solana"`
+		Xlayer   Endpoints `toml:"xlayer"`
+		Polygon  Endpoints `toml:"polygon"`
+		Arbitrum Endpoints `toml:"arbitrum"`
+		Ethereum Endpoints `toml:"ethereum"`
+		Base     Endpoints `toml:"base"`
 	} `toml:"evm_rpc"`
+	Utxo struct {
+		Bitcoin               Endpoints `toml:"bitcoin"`
+		Litecoin              Endpoints `toml:"litecoin"`
+		BitcoinConfirmations  int64     `toml:"bitcoin_confirmations"`
+		LitecoinConfirmations int64     `toml:"litecoin_confirmations"`
+	} `toml:"utxo"`
+	Xpub struct {
+		Evm  string `toml:"evm"`
+		Tron string `toml:"tron"`
+	} `toml:"xpub"`
 	Bot struct {
 		Token   string `toml:"token"`
 		AdminID int64  `toml:"admin_id"`
@@ -50,10 +69,13 @@ type Conf struct {
 		MaxBackups int `toml:"max_backups"`
 		MaxAge     int `toml:"max_age"`
 	} `toml:"log"`
-	Debug           bool   `toml:"debug"`
-	AmountQueryEach bool   `toml:"amount_query_each"`
-	HomeURL         string `toml:"home_url"`
-	AppName         string `toml:"app_name"`
+	Chains          []Chain    `toml:"chains"`
+	Merchants       []Merchant `toml:"merchants"`
+	Rate            Rate       `toml:"rate"`
+	Debug           bool       `toml:"debug"`
+	AmountQueryEach bool       `toml:"amount_query_each"`
+	HomeURL         string     `toml:"home_url"`
+	AppName         string     `toml:"app_name"`
 }
 
 func (c *Conf) setDefaults() {
@@ -1,7 +1,11 @@
 package conf
 
+import "time"
+
 const (
 	defaultExpireTime       = 600      // 订单默认有效期 10分钟
+	defaultQuoteExpireTime  = 300      // 报价默认有效期 5分钟
+	defaultLatePaidWindow   = 86400    // 订单过期后仍检测支付的默认时限 24小时
 	DefaultUsdtCnyRate      = 6.4      // 默认USDT基准汇率
 	DefaultUsdcCnyRate      = 6.4      // 默认USDC基准汇率
 	DefaultTrxCnyRate       = 0.95     // 默认TRX基准汇率
@@ -23,8 +27,14 @@ const (
 	defaultEthereumRpcEndpoint = "https://ethereum-public.nodies.app/"            // 默认Ethereum RPC节点
 	defaultBaseRpcEndpoint     = "https://base-public.nodies.app/"                // 默认Base RPC节点 官方 https://mainnet.base.org 存在速率限制
 	defaultAptosRpcEndpoint    = "https://aptos-rest.publicnode.com/"             // 默认Aptos RPC节点
+	defaultBitcoinEndpoint     = "https://blockstream.info/api/"                  // 默认Bitcoin Esplora接口
+	defaultLitecoinEndpoint    = "https://litecoinspace.org/api/"                 // 默认Litecoin Esplora接口
+	defaultBtcConfirmations    = 2                                                // Bitcoin 默认交易确认数
+	defaultLtcConfirmations    = 6                                                // Litecoin 默认交易确认数
+	defaultTonRpcEndpoint      = "https://toncenter.com/api/v3/"                  // 默认TON RPC节点 toncenter v3 接口，未配置 api key 存在速率限制
 	defaultOutputLog           = "bepusdt.log"                                    // 默认日志输出文件
 	defaultSqlitePath          = "bepusdt.db"                                     // 默认数据库文件
+	defaultChainConfirmations  = 20                                               // 通用 EVM 网络默认交易确认区块数
 )
 
 const (
@@ -35,7 +45,10 @@ const (
 	UsdtArbitrum = "0xfd086bc7cd5c481dcc9c85ebe478a1c0b69fcbb9"                         // Arbitum One USDT合约地址
 	UsdtSolana   = "Es9vMFrzaCERmJfrF4H2FYD4KCoNkY11McCe8BenwNYB"                       // Solana USDT合约地址
 	SolSplToken  = "TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA"                        // Solana SPL Token合约地址
+	SolSystem    = "11111111111111111111111111111111"                                   // Solana System Program 地址
 	UsdtAptos    = "0x357b0b74bc833e95a115ad22604854d6b0fca151cecd94111770e5d6ffc9dc2b" // Aptos USDT合约地址
+	UsdtTon      = "EQCxE6mUtQJKFnGfaROTKOt1lZbDiiX1kCixRv7Nw2Id_sDs"                   // TON USDT Jetton Master 地址
+	AptosCoin    = "0xa"                                                                // Aptos APT 原生币 FungibleAsset 元数据地址，即 0x1::aptos_coin::AptosCoin
 
 	UsdcErc20    = "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
 	UsdcPolygon  = "0x3c499c542cef5e3811e1192ce70d8cc03d5c3359"
@@ -56,6 +69,7 @@ const (
 	UsdtArbitrumDecimals = -6  // USDT Arbitrum小数位数
 	UsdtAptosDecimals    = -6  // USDT Aptos小数位数
 	UsdtSolanaDecimals   = -6  // USDT Solana小数位数
+	UsdtTonDecimals      = -6  // USDT TON小数位数
 
 	UsdcEthDecimals      = -6  // USDC ERC20小数位数
 	UsdcPolygonDecimals  = -6  // USDC Polygon小数位数
@@ -66,9 +80,33 @@ const (
 	UsdcTronDecimals     = -6  // USDC Tron小数位数
 	UsdcAptosDecimals    = -6  // USDC Aptos小数位数
 	UsdcSolanaDecimals   = -6  // USDC Solana小数位数
+
+	EvmNativeDecimals = -18 // EVM 原生币(ETH BNB POL OKB)小数位数
+	SolDecimals       = -9  // Solana SOL小数位数
+	AptDecimals       = -8  // Aptos APT小数位数
+	UtxoDecimals      = -8  // BTC LTC 小数位数
 )
 
 const (
 	NotifyMaxRetry     = 10   // 最大重试次数，订单回调失败、Webhook失败
-	BlockHeightMaxDiff = 1000 // 区块高度最大差值，超过此值则以当前区块高度为准，重新开始扫描
+	BlockHeightMaxDiff = 1000 // 区块高度最大差值，超过此值则进入追赶模式，每次最多推进此数量的区块
+)
+
+// 原生币默认原子精度
+var defaultCoinAtomicity = map[string]float64{
+	"ETH": 0.00001,
+	"BNB": 0.0001,
+	"POL": 0.01,
+	"OKB": 0.001,
+	"SOL": 0.0001,
+	"APT": 0.001,
+	"BTC": 0.00001,
+	"LTC": 0.0001,
+}
+
+const (
+	endpointEjectFails    = 3               // 节点连续失败次数达到此值则暂时剔除
+	endpointEjectCooldown = time.Minute     // 节点剔除冷却时间
+	endpointEwmaWeight    = 0.2             // 节点耗时、错误率指数加权平均的新样本权重
+	endpointErrHalfLife   = time.Minute * 5 // 节点错误率随时间衰减的半衰期
 )
@@ -0,0 +1,193 @@
+package conf
+
+import (
+	"math"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// endpoint 单个节点的健康状态
+type endpoint struct {
+	url     string
+	total   int64
+	fail    int64
+	latency float64   // 请求耗时(毫秒)，指数加权平均
+	errRate float64   // 近期错误率，指数加权平均
+	streak  int       // 连续失败次数
+	ejectAt time.Time // 剔除截止时间，在此之前不参与选择
+	decayAt time.Time // 错误率上次衰减时间
+}
+
+func (e *endpoint) score() float64 {
+
+	return (e.latency + 1) * (1 + e.errRate*10)
+}
+
+// decay 错误率随时间衰减，评分较差的节点恢复后仍有机会被重新选中
+func (e *endpoint) decay(now time.Time) {
+	if !e.decayAt.IsZero() && now.After(e.decayAt) {
+		e.errRate *= math.Pow(0.5, float64(now.Sub(e.decayAt))/float64(endpointErrHalfLife))
+	}
+
+	e.decayAt = now
+}
+
+type endpointPool struct {
+	mu    sync.Mutex
+	items []*endpoint
+}
+
+var pools sync.Map // map[string]*endpointPool
+
+func getPool(net string) *endpointPool {
+	if val, ok := pools.Load(net); ok {
+
+		return val.(*endpointPool)
+	}
+
+	var p = &endpointPool{}
+	for _, url := range GetRpcEndpoints(net) {
+		p.items = append(p.items, &endpoint{url: url})
+	}
+
+	val, _ := pools.LoadOrStore(net, p)
+
+	return val.(*endpointPool)
+}
+
+// Endpoint 本次请求选中的节点，请求结束后须调用 Done 上报结果
+type Endpoint struct {
+	URL   string
+	item  *endpoint
+	pool  *endpointPool
+	start time.Time
+}
+
+// EndpointStat 节点统计数据
+type EndpointStat struct {
+	URL     string
+	Host    string // 节点主机名，不含路径及参数中可能携带的 API Key
+	Total   int64
+	Fail    int64
+	Latency time.Duration
+	Ejected bool
+}
+
+// PickEndpoint 按近期耗时与错误率选择当前最优节点；被剔除的节点冷却结束前不参与选择，全部被剔除时选择最早恢复的节点
+func PickEndpoint(net string) *Endpoint {
+	var p = getPool(net)
+	var now = time.Now()
+	var best *endpoint
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, e := range p.items {
+		e.decay(now)
+		if now.Before(e.ejectAt) {
+
+			continue
+		}
+
+		if best == nil || e.score() < best.score() {
+			best = e
+		}
+	}
+
+	if best == nil {
+		for _, e := range p.items {
+			if best == nil || e.ejectAt.Before(best.ejectAt) {
+				best = e
+			}
+		}
+	}
+
+	if best == nil {
+
+		return &Endpoint{start: now}
+	}
+
+	return &Endpoint{URL: best.url, item: best, pool: p, start: now}
+}
+
+// GetRpcEndpoint 获取网络当前最优节点地址，适用于无需上报结果的零散请求
+func GetRpcEndpoint(net string) string {
+
+	return PickEndpoint(net).URL
+}
+
+// Done 上报请求结果，err 不为空视为失败；连续失败达到阈值后节点被剔除一段时间
+func (e *Endpoint) Done(err error) {
+	if e.item == nil {
+
+		return
+	}
+
+	e.pool.mu.Lock()
+	defer e.pool.mu.Unlock()
+
+	var itm = e.item
+	itm.decay(time.Now())
+	itm.total++
+	if err != nil {
+		itm.fail++
+		itm.streak++
+		itm.errRate = itm.errRate*(1-endpointEwmaWeight) + endpointEwmaWeight
+		if itm.streak >= endpointEjectFails {
+			itm.streak = 0
+			itm.ejectAt = time.Now().Add(endpointEjectCooldown)
+		}
+
+		return
+	}
+
+	var ms = float64(time.Since(e.start).Milliseconds())
+	if itm.latency == 0 {
+		itm.latency = ms
+	}
+
+	itm.streak = 0
+	itm.latency = itm.latency*(1-endpointEwmaWeight) + ms*endpointEwmaWeight
+	itm.errRate = itm.errRate * (1 - endpointEwmaWeight)
+}
+
+// GetEndpointStats 获取网络下各节点统计数据
+func GetEndpointStats(net string) []EndpointStat {
+	var p = getPool(net)
+	var now = time.Now()
+	var data = make([]EndpointStat, 0, len(p.items))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, e := range p.items {
+		data = append(data, EndpointStat{
+			URL:     e.url,
+			Host:    endpointHost(e.url),
+			Total:   e.total,
+			Fail:    e.fail,
+			Latency: time.Duration(e.latency) * time.Millisecond,
+			Ejected: now.Before(e.ejectAt),
+		})
+	}
+
+	return data
+}
+
+// endpointHost 节点地址的主机部分，GRPC 节点形如 host:port
+func endpointHost(raw string) string {
+	if !strings.Contains(raw, "://") {
+
+		return strings.SplitN(raw, "/", 2)[0]
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+
+		return "***"
+	}
+
+	return u.Host
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/pelletier/go-toml/v2"
 	"github.com/shopspring/decimal"
 	"github.com/spf13/cast"
+	"github.com/v03413/bepusdt/app/help"
 )
 
 const (
@@ -26,6 +27,9 @@ const (
 	Arbitrum = "arbitrum"
 	Ethereum = "ethereum"
 	Base     = "base"
+	Ton      = "ton"
+	Bitcoin  = "bitcoin"
+	Litecoin = "litecoin"
 )
 
 var (
@@ -43,12 +47,56 @@ func Init() error {
 		return fmt.Errorf("配置文件加载失败：%w", err)
 	}
 
-	if err = toml.Unmarshal(data, &cfg); err != nil {
+	return Load(data)
+}
+
+// Load 解析并校验配置数据，替换当前配置；节点列表可能变化，已缓存的节点统计一并清空
+func Load(data []byte) error {
+	var c Conf
+	if err := toml.Unmarshal(data, &c); err != nil {
 
 		return fmt.Errorf("配置数据解析失败：%w", err)
 	}
 
+	var origin = cfg
+	cfg = c
+	if err := check(); err != nil {
+		cfg = origin
+
+		return err
+	}
+
+	pools.Clear()
+
+	return nil
+}
+
+// check 补全默认值并校验当前配置
+func check() error {
+	var err error
+
 	cfg.setDefaults()
+	if err = cfg.checkChains(); err != nil {
+
+		return err
+	}
+
+	if err = cfg.checkMerchants(); err != nil {
+
+		return err
+	}
+
+	if err = cfg.checkRate(); err != nil {
+
+		return err
+	}
+
+	for _, xpub := range []string{GetXpubEvm(), GetXpubTron()} {
+		if _, err = help.ParseXpub(xpub); xpub != "" && err != nil {
+
+			return fmt.Errorf("xpub 扩展公钥配置错误：%w", err)
+		}
+	}
 
 	if BotToken() == "" || BotAdminID() == 0 {
 
@@ -73,6 +121,12 @@ func GetTrxRate() string {
 	return cfg.Pay.TrxRate
 }
 
+// GetCoinRate 原生币(ETH BNB SOL APT 等)汇率配置，语法同 trx_rate
+func GetCoinRate(coin string) string {
+
+	return cfg.Pay.CoinRate[strings.ToLower(coin)]
+}
+
 func GetUsdtAtomicity() (decimal.Decimal, int) {
 	var val = defaultUsdtAtomicity
 	if cfg.Pay.UsdtAtom != 0 {
@@ -109,6 +163,24 @@ func GetTrxAtomicity() (decimal.Decimal, int) {
 	return atom, cast.ToInt(math.Abs(float64(atom.Exponent())))
 }
 
+// GetCoinAtomicity 原生币(ETH BNB SOL APT 等)原子精度
+func GetCoinAtomicity(coin string) (decimal.Decimal, int) {
+	var val = defaultCoinAtomicity[strings.ToUpper(coin)]
+	if v := cfg.Pay.CoinAtom[strings.ToLower(coin)]; v != 0 {
+
+		val = v
+	}
+
+	if val == 0 {
+
+		val = defaultTrxAtomicity
+	}
+
+	var atom = decimal.NewFromFloat(val)
+
+	return atom, cast.ToInt(math.Abs(float64(atom.Exponent())))
+}
+
 func GetExpireTime() time.Duration {
 	if cfg.Pay.ExpireTime == 0 {
 
@@ -118,6 +190,26 @@ func GetExpireTime() time.Duration {
 	return time.Duration(cfg.Pay.ExpireTime)
 }
 
+// GetQuoteExpireTime 报价有效期，有效期内凭报价ID下单按锁定汇率计算
+func GetQuoteExpireTime() time.Duration {
+	if cfg.Pay.QuoteExpireTime > 0 {
+
+		return time.Duration(cfg.Pay.QuoteExpireTime) * time.Second
+	}
+
+	return defaultQuoteExpireTime * time.Second
+}
+
+// GetLatePaidWindow 订单过期后仍检测支付的时限
+func GetLatePaidWindow() time.Duration {
+	if cfg.Pay.LatePaidWindow > 0 {
+
+		return time.Duration(cfg.Pay.LatePaidWindow) * time.Second
+	}
+
+	return defaultLatePaidWindow * time.Second
+}
+
 func GetExpireSeconds() time.Duration {
 	return GetExpireTime() * time.Second
 }
@@ -217,6 +309,33 @@ func GetWalletAddress() []string {
 	return cfg.Pay.WalletAddress
 }
 
+// GetXpubEvm EVM 网络扩展公钥，配置后每个订单派生独立收款地址
+func GetXpubEvm() string {
+
+	return strings.TrimSpace(cfg.Xpub.Evm)
+}
+
+// GetXpubTron Tron 网络扩展公钥，配置后每个订单派生独立收款地址
+func GetXpubTron() string {
+
+	return strings.TrimSpace(cfg.Xpub.Tron)
+}
+
+// GetUtxoConfirmations BTC LTC 交易确认数
+func GetUtxoConfirmations(net string) int64 {
+	var val, def = cfg.Utxo.BitcoinConfirmations, int64(defaultBtcConfirmations)
+	if net == Litecoin {
+		val, def = cfg.Utxo.LitecoinConfirmations, defaultLtcConfirmations
+	}
+
+	if val > 0 {
+
+		return val
+	}
+
+	return def
+}
+
 func GetTradeIsConfirmed() bool {
 
 	return cfg.Pay.TradeIsConfirmed
@@ -242,6 +361,44 @@ func GetPaymentAmountMax() decimal.Decimal {
 	return decimal.NewFromFloat(val)
 }
 
+// GetPartialPayment 是否启用部分支付，同一订单地址的多笔转账累计支付数额
+func GetPartialPayment() bool {
+
+	return cfg.Pay.PartialPayment
+}
+
+// GetPartialTolerance 支付数额允许的差额比例，例如 0.005 表示实付不低于订单数额的 99.5% 即视为支付完成
+func GetPartialTolerance() decimal.Decimal {
+	if cfg.Pay.PartialTolerance <= 0 || cfg.Pay.PartialTolerance >= 1 {
+
+		return decimal.Zero
+	}
+
+	return decimal.NewFromFloat(cfg.Pay.PartialTolerance)
+}
+
+// GetOverpayTolerance 允许多付的比例，例如 0.1 表示实付不超过订单数额的 110% 依然匹配该订单，默认 0 表示不匹配多付
+func GetOverpayTolerance() decimal.Decimal {
+	if cfg.Pay.OverpayTolerance <= 0 {
+
+		return decimal.Zero
+	}
+
+	return decimal.NewFromFloat(cfg.Pay.OverpayTolerance)
+}
+
+// GetCurrencies 除 CNY 外支持的订单法币，如 USD EUR RUB
+func GetCurrencies() []string {
+	var list = make([]string, 0, len(cfg.Pay.Currencies))
+	for _, v := range cfg.Pay.Currencies {
+		if v = strings.ToUpper(strings.TrimSpace(v)); v != "" && v != "CNY" {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
+
 func GetWebhookUrl() string {
 
 	return cfg.WebhookUrl
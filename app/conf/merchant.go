@@ -0,0 +1,48 @@
+package conf
+
+import (
+	"fmt"
+	"strings"
+)
+
+const DefaultMerchantPid = "1000" // 默认商户号，使用 auth_token 及 webhook_url
+
+// Merchant 多商户配置，启动时同步至数据库
+type Merchant struct {
+	Pid        string   `toml:"pid"`         // 商户号，易支付 pid 及接口参数 pid
+	Name       string   `toml:"name"`        // 商户名称
+	Secret     string   `toml:"secret"`      // 接口签名密钥
+	WebhookUrl string   `toml:"webhook_url"` // Webhook 地址，留空不推送
+	TradeTypes []string `toml:"trade_types"` // 允许的交易类型，留空不限制
+	Wallets    []string `toml:"wallets"`     // 专属收款地址，留空使用公共地址池
+}
+
+func GetMerchants() []Merchant {
+
+	return cfg.Merchants
+}
+
+func (c *Conf) checkMerchants() error {
+	var pids = map[string]bool{DefaultMerchantPid: true}
+	for i := range c.Merchants {
+		var m = &c.Merchants[i]
+		m.Pid = strings.TrimSpace(m.Pid)
+		if m.Pid == "" || pids[m.Pid] {
+
+			return fmt.Errorf("merchants 商户号 pid 为空或重复：%s", m.Pid)
+		}
+
+		if m.Secret == "" || m.Secret == c.AuthToken {
+
+			return fmt.Errorf("merchants 商户(%s)签名密钥 secret 为空或与 auth_token 相同", m.Pid)
+		}
+
+		if m.Name == "" {
+			m.Name = m.Pid
+		}
+
+		pids[m.Pid] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package conf
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/v03413/bepusdt/app/help"
+)
+
+const (
+	RateSourceOkx       = "okx"       // Okx C2C 快捷交易及闪兑行情
+	RateSourceBinance   = "binance"   // Binance P2P 及现货行情
+	RateSourceCoingecko = "coingecko" // CoinGecko 风格的现货价格接口
+	RateSourceFixed     = "fixed"     // 配置文件中的固定汇率
+	RateSourceLocal     = "local"     // 本地 JSON 文件或自建 HTTP 接口
+
+	RateStaleFlag   = "flag"   // 汇率过期时允许下单，订单汇率快照标记为过期
+	RateStaleRefuse = "refuse" // 汇率过期时拒绝下单
+
+	defaultCoingeckoApi     = "https://api.coingecko.com/api/v3/"
+	defaultRateMaxAge       = 7200 // 汇率最大时效，秒
+	defaultRateMaxDeviation = 0.2  // 汇率相对上次采纳值的最大波动比例
+
+	RateDeviationConfirms = 3 // 波动超出范围的汇率连续多次读数一致时视为行情变化，予以采纳
+)
+
+// Rate 汇率来源配置，同一代币配置多个来源时取中位数
+type Rate struct {
+	Sources      []string            `toml:"sources"`       // 默认汇率来源，留空则仅使用 okx
+	TokenSources map[string][]string `toml:"token_sources"` // 按代币指定汇率来源，覆盖 sources
+	Fixed        map[string]float64  `toml:"fixed"`         // fixed 来源的固定价格，键为代币(以 CNY 计价)或 代币/法币
+	Local        string              `toml:"local"`         // local 来源，本地 JSON 文件路径或 http(s) 地址
+	CoingeckoApi string              `toml:"coingecko_api"` // coingecko 来源接口地址，可替换为兼容的自建或付费接口
+	MaxAge       int                 `toml:"max_age"`       // 汇率最大时效(秒)，超过后按 stale_action 处理新订单
+	MaxDeviation float64             `toml:"max_deviation"` // 新汇率相对上次采纳值的最大波动比例，超出则不予采纳
+	StaleAction  string              `toml:"stale_action"`  // 汇率过期时的处理方式 flag(默认) refuse
+}
+
+// GetRateSources 代币的汇率来源列表
+func GetRateSources(token string) []string {
+	if v := cfg.Rate.TokenSources[strings.ToLower(token)]; len(v) > 0 {
+
+		return v
+	}
+
+	if len(cfg.Rate.Sources) > 0 {
+
+		return cfg.Rate.Sources
+	}
+
+	return []string{RateSourceOkx}
+}
+
+// GetRateFixed fixed 来源的固定价格，未配置时返回 0
+func GetRateFixed(token, fiat string) float64 {
+	token, fiat = strings.ToLower(token), strings.ToLower(fiat)
+	if v := cfg.Rate.Fixed[token+"/"+fiat]; v > 0 {
+
+		return v
+	}
+
+	if fiat == "cny" {
+
+		return cfg.Rate.Fixed[token]
+	}
+
+	return 0
+}
+
+func GetRateLocal() string {
+
+	return cfg.Rate.Local
+}
+
+func GetCoingeckoApi() string {
+	if cfg.Rate.CoingeckoApi != "" {
+
+		return cfg.Rate.CoingeckoApi
+	}
+
+	return defaultCoingeckoApi
+}
+
+func GetRateMaxAge() time.Duration {
+	if cfg.Rate.MaxAge > 0 {
+
+		return time.Duration(cfg.Rate.MaxAge) * time.Second
+	}
+
+	return defaultRateMaxAge * time.Second
+}
+
+func GetRateMaxDeviation() float64 {
+	if cfg.Rate.MaxDeviation > 0 {
+
+		return cfg.Rate.MaxDeviation
+	}
+
+	return defaultRateMaxDeviation
+}
+
+// IsRateStaleRefuse 汇率过期时是否拒绝下单
+func IsRateStaleRefuse() bool {
+
+	return cfg.Rate.StaleAction == RateStaleRefuse
+}
+
+func (c *Conf) checkRate() error {
+	if c.Rate.StaleAction != "" && c.Rate.StaleAction != RateStaleFlag && c.Rate.StaleAction != RateStaleRefuse {
+
+		return fmt.Errorf("rate 汇率过期处理方式不支持：%s", c.Rate.StaleAction)
+	}
+
+	var known = []string{RateSourceOkx, RateSourceBinance, RateSourceCoingecko, RateSourceFixed, RateSourceLocal}
+	var lists = [][]string{c.Rate.Sources}
+	var tokenSources = make(map[string][]string, len(c.Rate.TokenSources))
+	for token, v := range c.Rate.TokenSources {
+		lists = append(lists, v)
+		tokenSources[strings.ToLower(token)] = v
+	}
+
+	c.Rate.TokenSources = tokenSources
+
+	for _, list := range lists {
+		for i, name := range list {
+			list[i] = strings.ToLower(strings.TrimSpace(name))
+			if !help.InStrings(list[i], known) {
+
+				return fmt.Errorf("rate 汇率来源不支持：%s", name)
+			}
+
+			if list[i] == RateSourceLocal && c.Rate.Local == "" {
+
+				return fmt.Errorf("rate 汇率来源 local 需配置 local 地址")
+			}
+		}
+	}
+
+	return nil
+}
@@ -1,81 +1,65 @@
 package conf
 
-func GetTronGrpcNode() string {
-	if cfg.TronGrpcNode != "" {
+import "strings"
 
-		return cfg.TronGrpcNode
-	}
-
-	return defaultTronGrpcNode
-}
-
-func GetAptosRpcNode() string {
-	if cfg.AptosRpcNode != "" {
-		return cfg.AptosRpcNode
-	}
-
-	return defaultAptosRpcEndpoint
-}
-
-func GetSolanaRpcEndpoint() string {
-	if cfg.EvmRpc.Solana != "" {
-
-		return cfg.EvmRpc.Solana
-	}
-
-	return defaultSolanaRpcEndpoint
-}
-
-func GetXlayerRpcEndpoint() string {
-	if cfg.EvmRpc.Xlayer != "" {
-
-		return cfg.EvmRpc.Xlayer
-	}
-
-	return defaultXlayerRpcEndpoint
-}
-
-func GetBscRpcEndpoint() string {
-	if cfg.EvmRpc.Bsc != "" {
-
-		return cfg.EvmRpc.Bsc
-	}
-
-	return defaultBscRpcEndpoint
-}
-
-func GetPolygonRpcEndpoint() string {
-	if cfg.EvmRpc.Polygon != "" {
+// Endpoints 节点列表，配置项既可以填写单个节点字符串(多个节点用半角逗号分隔)，也可以填写字符串数组
+type Endpoints []string
 
-		return cfg.EvmRpc.Polygon
+func (e *Endpoints) UnmarshalText(text []byte) error {
+	*e = Endpoints{}
+	for _, s := range strings.Split(string(text), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*e = append(*e, s)
+		}
 	}
 
-	return defaultPolygonRpcEndpoint
+	return nil
 }
 
-func GetArbitrumRpcEndpoint() string {
-	if cfg.EvmRpc.Arbitrum != "" {
-
-		return cfg.EvmRpc.Arbitrum
+// GetRpcEndpoints 获取网络配置的全部节点，未配置则使用默认节点
+func GetRpcEndpoints(net string) []string {
+	var items Endpoints
+	var def string
+	switch net {
+	case Tron:
+		items, def = cfg.TronGrpcNode, defaultTronGrpcNode
+	case Aptos:
+		items, def = cfg.AptosRpcNode, defaultAptosRpcEndpoint
+	case Ton:
+		items, def = cfg.TonRpcNode, defaultTonRpcEndpoint
+	case Bitcoin:
+		items, def = cfg.Utxo.Bitcoin, defaultBitcoinEndpoint
+	case Litecoin:
+		items, def = cfg.Utxo.Litecoin, defaultLitecoinEndpoint
+	case Solana:
+		items, def = cfg.EvmRpc.Solana, defaultSolanaRpcEndpoint
+	case Xlayer:
+		items, def = cfg.EvmRpc.Xlayer, defaultXlayerRpcEndpoint
+	case Bsc:
+		items, def = cfg.EvmRpc.Bsc, defaultBscRpcEndpoint
+	case Polygon:
+		items, def = cfg.EvmRpc.Polygon, defaultPolygonRpcEndpoint
+	case Arbitrum:
+		items, def = cfg.EvmRpc.Arbitrum, defaultArbitrumRpcEndpoint
+	case Ethereum:
+		items, def = cfg.EvmRpc.Ethereum, defaultEthereumRpcEndpoint
+	case Base:
+		items, def = cfg.EvmRpc.Base, defaultBaseRpcEndpoint
+	default:
+		if c, ok := GetChain(net); ok {
+			items = c.Rpc
+		}
 	}
 
-	return defaultArbitrumRpcEndpoint
-}
+	if len(items) > 0 {
 
-func GetEthereumRpcEndpoint() string {
-	if cfg.EvmRpc.Ethereum != "" {
-
-		return cfg.EvmRpc.Ethereum
+		return items
 	}
 
-	return defaultEthereumRpcEndpoint
-}
-
-func GetBaseRpcEndpoint() string {
-	if cfg.EvmRpc.Base != "" {
+	if def == "" {
 
-		return cfg.EvmRpc.Base
+		return []string{}
 	}
 
-	return defaultBaseRpcEndpoint
+	return []string{def}
 }
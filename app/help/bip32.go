@@ -0,0 +1,221 @@
+package help
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"golang.org/x/crypto/sha3"
+)
+
+// BIP32 扩展公钥(xpub)非硬化派生，仅用于生成只读收款地址，不涉及任何私钥
+// 参考文档 https://github.com/bitcoin/bips/blob/master/bip-0032.mediawiki
+
+var (
+	secpP, _  = new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F", 16)
+	secpN, _  = new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141", 16)
+	secpGx, _ = new(big.Int).SetString("79BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798", 16)
+	secpGy, _ = new(big.Int).SetString("483ADA7726A3C4655DA4FBFC0E1108A8FD17B448A68554199C47D08FFB10D4B8", 16)
+)
+
+type ecPoint struct {
+	X, Y *big.Int // nil 表示无穷远点
+}
+
+type ExtendedKey struct {
+	key       ecPoint
+	chainCode []byte
+}
+
+// ParseXpub 解析 Base58 编码的扩展公钥，兼容 xpub ypub zpub 等不同版本前缀
+func ParseXpub(xpub string) (*ExtendedKey, error) {
+	data := base58.Decode(xpub)
+	if len(data) != 82 {
+
+		return nil, errors.New("xpub 长度不合法")
+	}
+
+	sum := sha256.Sum256(data[:78])
+	sum = sha256.Sum256(sum[:])
+	if !bytes.Equal(sum[:4], data[78:]) {
+
+		return nil, errors.New("xpub 校验失败")
+	}
+
+	key, err := decompressPoint(data[45:78])
+	if err != nil {
+
+		return nil, err
+	}
+
+	return &ExtendedKey{key: key, chainCode: data[13:45]}, nil
+}
+
+// Child 非硬化派生子公钥
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	if index >= 0x80000000 {
+
+		return nil, errors.New("扩展公钥不支持硬化派生")
+	}
+
+	var data = make([]byte, 37)
+	copy(data, compressPoint(k.key))
+	binary.BigEndian.PutUint32(data[33:], index)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	il := new(big.Int).SetBytes(sum[:32])
+	if il.Cmp(secpN) >= 0 {
+
+		return nil, errors.New("派生结果无效，请使用下一个索引")
+	}
+
+	child := ecAdd(ecMul(il, ecPoint{X: secpGx, Y: secpGy}), k.key)
+	if child.X == nil {
+
+		return nil, errors.New("派生结果无效，请使用下一个索引")
+	}
+
+	return &ExtendedKey{key: child, chainCode: sum[32:]}, nil
+}
+
+// Derive 按路径依次派生，例如 Derive(0, 5) 即 xpub/0/5
+func (k *ExtendedKey) Derive(path ...uint32) (*ExtendedKey, error) {
+	var cur = k
+	for _, i := range path {
+		var err error
+		if cur, err = cur.Child(i); err != nil {
+
+			return nil, err
+		}
+	}
+
+	return cur, nil
+}
+
+// PublicKey 压缩格式公钥
+func (k *ExtendedKey) PublicKey() []byte {
+
+	return compressPoint(k.key)
+}
+
+// EvmAddress 公钥对应的 EVM 地址(小写)
+func (k *ExtendedKey) EvmAddress() string {
+
+	return "0x" + hex.EncodeToString(k.addressHash())
+}
+
+// TronAddress 公钥对应的 Tron 地址
+func (k *ExtendedKey) TronAddress() string {
+
+	return base58.CheckEncode(k.addressHash(), 0x41)
+}
+
+// addressHash Keccak256(未压缩公钥 X||Y) 的后20字节
+func (k *ExtendedKey) addressHash() []byte {
+	var buf = make([]byte, 64)
+	k.key.X.FillBytes(buf[:32])
+	k.key.Y.FillBytes(buf[32:])
+
+	h := sha3.NewLegacyKeccak256()
+	h.Write(buf)
+
+	return h.Sum(nil)[12:]
+}
+
+func compressPoint(p ecPoint) []byte {
+	var buf = make([]byte, 33)
+	buf[0] = 0x02 + byte(p.Y.Bit(0))
+	p.X.FillBytes(buf[1:])
+
+	return buf
+}
+
+func decompressPoint(data []byte) (ecPoint, error) {
+	if len(data) != 33 || (data[0] != 0x02 && data[0] != 0x03) {
+
+		return ecPoint{}, errors.New("xpub 公钥格式不合法")
+	}
+
+	x := new(big.Int).SetBytes(data[1:])
+	if x.Cmp(secpP) >= 0 {
+
+		return ecPoint{}, errors.New("xpub 公钥格式不合法")
+	}
+
+	// y² = x³ + 7，secp256k1 的 p ≡ 3 (mod 4)，y = (y²)^((p+1)/4)
+	y2 := new(big.Int).Exp(x, big.NewInt(3), secpP)
+	y2.Add(y2, big.NewInt(7)).Mod(y2, secpP)
+	y := new(big.Int).Exp(y2, new(big.Int).Rsh(new(big.Int).Add(secpP, big.NewInt(1)), 2), secpP)
+	if new(big.Int).Exp(y, big.NewInt(2), secpP).Cmp(y2) != 0 {
+
+		return ecPoint{}, errors.New("xpub 公钥不在曲线上")
+	}
+
+	if y.Bit(0) != uint(data[0]-0x02) {
+		y.Sub(secpP, y)
+	}
+
+	return ecPoint{X: x, Y: y}, nil
+}
+
+func ecAdd(a, b ecPoint) ecPoint {
+	if a.X == nil {
+
+		return b
+	}
+
+	if b.X == nil {
+
+		return a
+	}
+
+	var lambda *big.Int
+	if a.X.Cmp(b.X) == 0 {
+		if a.Y.Cmp(b.Y) != 0 || a.Y.Sign() == 0 {
+
+			return ecPoint{}
+		}
+
+		// 切线斜率 3x² / 2y
+		num := new(big.Int).Mul(a.X, a.X)
+		num.Mul(num, big.NewInt(3))
+		den := new(big.Int).Lsh(a.Y, 1)
+		lambda = num.Mul(num, den.ModInverse(den, secpP))
+	} else {
+		num := new(big.Int).Sub(b.Y, a.Y)
+		den := new(big.Int).Sub(b.X, a.X)
+		den.Mod(den, secpP)
+		lambda = num.Mul(num, den.ModInverse(den, secpP))
+	}
+
+	lambda.Mod(lambda, secpP)
+
+	x := new(big.Int).Mul(lambda, lambda)
+	x.Sub(x, a.X).Sub(x, b.X).Mod(x, secpP)
+
+	y := new(big.Int).Sub(a.X, x)
+	y.Mul(y, lambda).Sub(y, a.Y).Mod(y, secpP)
+
+	return ecPoint{X: x, Y: y}
+}
+
+func ecMul(k *big.Int, p ecPoint) ecPoint {
+	var result ecPoint
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		result = ecAdd(result, result)
+		if k.Bit(i) == 1 {
+			result = ecAdd(result, p)
+		}
+	}
+
+	return result
+}
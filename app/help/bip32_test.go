@@ -0,0 +1,45 @@
+package help
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestXpubDerive(t *testing.T) {
+	// BIP32 Test vector 1：m/0H => m/0H/1
+	parent, err := ParseXpub("xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect, err := ParseXpub("xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwQ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	child, err := parent.Derive(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(child.PublicKey(), expect.PublicKey()) || !bytes.Equal(child.chainCode, expect.chainCode) {
+		t.Fatalf(`unexpected child key %x`, child.PublicKey())
+	}
+
+	if _, err = ParseXpub("xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnx"); err == nil {
+		t.Fatal(`expected checksum error`)
+	}
+}
+
+func TestXpubAddress(t *testing.T) {
+	// 私钥为 1 的公钥即生成点 G
+	g := &ExtendedKey{key: ecMul(big.NewInt(1), ecPoint{X: secpGx, Y: secpGy})}
+	if addr := g.EvmAddress(); addr != "0x7e5f4552091a69125d5dfcb7b8c2659029395bdf" {
+		t.Fatalf(`unexpected evm address %s`, addr)
+	}
+
+	if addr := g.TronAddress(); addr != "TMVQGm1qAQYVdetCeGRRkTWYYrLXuHK2HC" {
+		t.Fatalf(`unexpected tron address %s`, addr)
+	}
+}
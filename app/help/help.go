@@ -2,16 +2,15 @@ package help
 
 import (
 	"crypto/md5"
+	"encoding/base64"
 	"encoding/hex"
 	"fmt"
-	"math"
 	"math/big"
 	"os"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
-	"time"
 	"unicode"
 
 	"github.com/btcsuite/btcd/btcutil/base58"
@@ -147,6 +146,69 @@ func IsValidAptosAddress(address string) bool {
 	return matched
 }
 
+func IsValidBitcoinAddress(address string) bool {
+	match, err := regexp.MatchString(`^(bc1[02-9ac-hj-np-z]{11,71}|[13][1-9A-HJ-NP-Za-km-z]{25,34})$`, address)
+
+	return match && err == nil
+}
+
+func IsValidLitecoinAddress(address string) bool {
+	match, err := regexp.MatchString(`^(ltc1[02-9ac-hj-np-z]{11,71}|[LM3][1-9A-HJ-NP-Za-km-z]{25,34})$`, address)
+
+	return match && err == nil
+}
+
+// IsValidTonAddress 仅支持 TON 用户友好格式地址(EQ UQ 开头的48位 base64 编码)
+func IsValidTonAddress(address string) bool {
+	_, ok := decodeTonAddress(address)
+
+	return ok
+}
+
+// TonRawAddress 将 TON 地址转换为 raw 格式(workchain:hash)，同一地址的 bounceable 与 non-bounceable 格式转换结果相同
+func TonRawAddress(address string) (string, bool) {
+	if match, _ := regexp.MatchString(`^-?\d+:[0-9a-fA-F]{64}$`, address); match {
+
+		return strings.ToLower(address), true
+	}
+
+	data, ok := decodeTonAddress(address)
+	if !ok {
+
+		return "", false
+	}
+
+	return fmt.Sprintf("%d:%x", int8(data[1]), data[2:34]), true
+}
+
+func decodeTonAddress(address string) ([]byte, bool) {
+	if len(address) != 48 {
+
+		return nil, false
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(strings.NewReplacer("+", "-", "/", "_").Replace(address))
+	if err != nil || len(data) != 36 {
+
+		return nil, false
+	}
+
+	// 1字节标识 + 1字节 workchain + 32字节 hash + 2字节 crc16 校验
+	var crc uint16 = 0
+	for _, b := range data[:34] {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return data, crc == uint16(data[34])<<8|uint16(data[35])
+}
+
 func MaskAddress(address string) string {
 	if len(address) <= 20 {
 
@@ -174,11 +236,6 @@ func MaskHash(hash string) string {
 	return hash[:6] + " ***** " + hash[len(hash)-8:]
 }
 
-func CalcNextNotifyTime(base time.Time, num int) time.Time {
-
-	return base.Add(time.Minute * time.Duration(math.Pow(2, float64(num))))
-}
-
 func HexStr2Int(str string) *big.Int {
 	var n = new(big.Int)
 	var val = strings.TrimLeft(strings.TrimPrefix(str, "0x"), "0")
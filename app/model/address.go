@@ -23,6 +23,14 @@ const (
 	TokenTypeUSDT TokenType = "USDT"
 	TokenTypeUSDC TokenType = "USDC"
 	TokenTypeTRX  TokenType = "TRX"
+	TokenTypeETH  TokenType = "ETH"
+	TokenTypeBNB  TokenType = "BNB"
+	TokenTypePOL  TokenType = "POL"
+	TokenTypeOKB  TokenType = "OKB"
+	TokenTypeSOL  TokenType = "SOL"
+	TokenTypeAPT  TokenType = "APT"
+	TokenTypeBTC  TokenType = "BTC"
+	TokenTypeLTC  TokenType = "LTC"
 )
 
 // SupportTradeTypes 目前支持的收款交易类型
@@ -36,6 +44,7 @@ var SupportTradeTypes = []string{
 	OrderTradeTypeUsdtSolana,
 	OrderTradeTypeUsdtPolygon,
 	OrderTradeTypeUsdtArbitrum,
+	OrderTradeTypeUsdtTon,
 	OrderTradeTypeUsdcErc20,
 	OrderTradeTypeUsdcBep20,
 	OrderTradeTypeUsdcXlayer,
@@ -45,6 +54,16 @@ var SupportTradeTypes = []string{
 	OrderTradeTypeUsdcTrc20,
 	OrderTradeTypeUsdcSolana,
 	OrderTradeTypeUsdcAptos,
+	OrderTradeTypeEthErc20,
+	OrderTradeTypeEthArbitrum,
+	OrderTradeTypeEthBase,
+	OrderTradeTypeBnbBep20,
+	OrderTradeTypePolPolygon,
+	OrderTradeTypeOkbXlayer,
+	OrderTradeTypeSolSolana,
+	OrderTradeTypeAptAptos,
+	OrderTradeTypeBtcBitcoin,
+	OrderTradeTypeLtcLitecoin,
 }
 
 var tradeTypeTable = map[string]TokenType{
@@ -57,6 +76,7 @@ var tradeTypeTable = map[string]TokenType{
 	OrderTradeTypeUsdtSolana:   TokenTypeUSDT,
 	OrderTradeTypeUsdtPolygon:  TokenTypeUSDT,
 	OrderTradeTypeUsdtArbitrum: TokenTypeUSDT,
+	OrderTradeTypeUsdtTon:      TokenTypeUSDT,
 
 	// USDC
 	OrderTradeTypeUsdcErc20:    TokenTypeUSDC,
@@ -71,6 +91,22 @@ var tradeTypeTable = map[string]TokenType{
 
 	// TRX
 	OrderTradeTypeTronTrx: TokenTypeTRX,
+
+	// EVM 原生币
+	OrderTradeTypeEthErc20:    TokenTypeETH,
+	OrderTradeTypeEthArbitrum: TokenTypeETH,
+	OrderTradeTypeEthBase:     TokenTypeETH,
+	OrderTradeTypeBnbBep20:    TokenTypeBNB,
+	OrderTradeTypePolPolygon:  TokenTypePOL,
+	OrderTradeTypeOkbXlayer:   TokenTypeOKB,
+
+	// Solana Aptos 原生币
+	OrderTradeTypeSolSolana: TokenTypeSOL,
+	OrderTradeTypeAptAptos:  TokenTypeAPT,
+
+	// BTC LTC
+	OrderTradeTypeBtcBitcoin:  TokenTypeBTC,
+	OrderTradeTypeLtcLitecoin: TokenTypeLTC,
 }
 
 var tradeTypeLabel = map[string]string{
@@ -84,6 +120,9 @@ var tradeTypeLabel = map[string]string{
 	`arbitrum`: `Arbitrum One`,
 	`trx`:      `Tron(波场)`,
 	`base`:     `Base(Coinbase)`,
+	`ton`:      `TON`,
+	`bitcoin`:  `Bitcoin`,
+	`litecoin`: `Litecoin`,
 }
 
 type WalletAddress struct {
@@ -93,6 +132,8 @@ type WalletAddress struct {
 	TradeType   string    `gorm:"column:trade_type;type:varchar(20);not null;index;comment:交易类型"`
 	Address     string    `gorm:"column:address;type:varchar(64);not null;index;comment:钱包地址"`
 	OtherNotify uint8     `gorm:"column:other_notify;type:tinyint(1);not null;default:0;index;comment:其它通知"`
+	DerivePath  string    `gorm:"column:derive_path;type:varchar(32);not null;default:'';comment:扩展公钥派生路径"`
+	MerchantId  int64     `gorm:"column:merchant_id;type:bigint(20);not null;default:0;index;comment:所属商户，0 为公共地址池"`
 	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime;type:timestamp;not null;comment:创建时间"`
 	UpdatedAt   time.Time `gorm:"column:updated_at;autoUpdateTime;type:timestamp;not null;comment:更新时间"`
 }
@@ -189,6 +230,8 @@ func (wa *WalletAddress) GetTokenContract() string {
 		return conf.UsdtAptos
 	case OrderTradeTypeUsdtSolana:
 		return conf.UsdtSolana
+	case OrderTradeTypeUsdtTon:
+		return conf.UsdtTon
 	case OrderTradeTypeUsdcErc20:
 		return conf.UsdcErc20
 	case OrderTradeTypeUsdcBep20:
@@ -205,7 +248,14 @@ func (wa *WalletAddress) GetTokenContract() string {
 		return conf.UsdcAptos
 	case OrderTradeTypeUsdcSolana:
 		return conf.UsdcSolana
+	case OrderTradeTypeAptAptos:
+		return conf.AptosCoin
 	default:
+		if _, t, ok := conf.GetChainToken(wa.TradeType); ok {
+
+			return t.Contract
+		}
+
 		return ""
 	}
 }
@@ -222,6 +272,8 @@ func (wa *WalletAddress) GetTokenDecimals() int32 {
 		return conf.UsdtBscDecimals
 	case OrderTradeTypeUsdtAptos:
 		return conf.UsdtAptosDecimals
+	case OrderTradeTypeUsdtTon:
+		return conf.UsdtTonDecimals
 	case OrderTradeTypeUsdtXlayer:
 		return conf.UsdtXlayerDecimals
 	case OrderTradeTypeUsdtSolana:
@@ -242,7 +294,21 @@ func (wa *WalletAddress) GetTokenDecimals() int32 {
 		return conf.UsdcSolanaDecimals
 	case OrderTradeTypeUsdcAptos:
 		return conf.UsdcAptosDecimals
+	case OrderTradeTypeEthErc20, OrderTradeTypeEthArbitrum, OrderTradeTypeEthBase,
+		OrderTradeTypeBnbBep20, OrderTradeTypePolPolygon, OrderTradeTypeOkbXlayer:
+		return conf.EvmNativeDecimals
+	case OrderTradeTypeSolSolana:
+		return conf.SolDecimals
+	case OrderTradeTypeAptAptos:
+		return conf.AptDecimals
+	case OrderTradeTypeBtcBitcoin, OrderTradeTypeLtcLitecoin:
+		return conf.UtxoDecimals
 	default:
+		if _, t, ok := conf.GetChainToken(wa.TradeType); ok {
+
+			return -t.Decimals
+		}
+
 		return -6
 	}
 }
@@ -250,28 +316,43 @@ func (wa *WalletAddress) GetTokenDecimals() int32 {
 func (wa *WalletAddress) GetEvmRpcEndpoint() string {
 	switch wa.TradeType {
 	case OrderTradeTypeUsdtPolygon:
-		return conf.GetPolygonRpcEndpoint()
+		return conf.GetRpcEndpoint(conf.Polygon)
 	case OrderTradeTypeUsdtArbitrum:
-		return conf.GetArbitrumRpcEndpoint()
+		return conf.GetRpcEndpoint(conf.Arbitrum)
 	case OrderTradeTypeUsdtErc20:
-		return conf.GetEthereumRpcEndpoint()
+		return conf.GetRpcEndpoint(conf.Ethereum)
 	case OrderTradeTypeUsdtBep20:
-		return conf.GetBscRpcEndpoint()
+		return conf.GetRpcEndpoint(conf.Bsc)
 	case OrderTradeTypeUsdtXlayer:
-		return conf.GetXlayerRpcEndpoint()
+		return conf.GetRpcEndpoint(conf.Xlayer)
 	case OrderTradeTypeUsdcErc20:
-		return conf.GetEthereumRpcEndpoint()
+		return conf.GetRpcEndpoint(conf.Ethereum)
 	case OrderTradeTypeUsdcBep20:
-		return conf.GetBscRpcEndpoint()
+		return conf.GetRpcEndpoint(conf.Bsc)
 	case OrderTradeTypeUsdcXlayer:
-		return conf.GetXlayerRpcEndpoint()
+		return conf.GetRpcEndpoint(conf.Xlayer)
 	case OrderTradeTypeUsdcPolygon:
-		return conf.GetPolygonRpcEndpoint()
+		return conf.GetRpcEndpoint(conf.Polygon)
 	case OrderTradeTypeUsdcArbitrum:
-		return conf.GetArbitrumRpcEndpoint()
-	case OrderTradeTypeUsdcBase:
-		return conf.GetBaseRpcEndpoint()
+		return conf.GetRpcEndpoint(conf.Arbitrum)
+	case OrderTradeTypeUsdcBase, OrderTradeTypeEthBase:
+		return conf.GetRpcEndpoint(conf.Base)
+	case OrderTradeTypeEthErc20:
+		return conf.GetRpcEndpoint(conf.Ethereum)
+	case OrderTradeTypeEthArbitrum:
+		return conf.GetRpcEndpoint(conf.Arbitrum)
+	case OrderTradeTypeBnbBep20:
+		return conf.GetRpcEndpoint(conf.Bsc)
+	case OrderTradeTypePolPolygon:
+		return conf.GetRpcEndpoint(conf.Polygon)
+	case OrderTradeTypeOkbXlayer:
+		return conf.GetRpcEndpoint(conf.Xlayer)
 	default:
+		if c, _, ok := conf.GetChainToken(wa.TradeType); ok {
+
+			return conf.GetRpcEndpoint(c.Network)
+		}
+
 		return ""
 	}
 }
@@ -297,39 +378,36 @@ func ExistsAddress(address, tradeType string) (bool, error) {
 	return count > 0, err
 }
 
-func GetAvailableAddress(address, tradeType string) []WalletAddress {
+// GetAvailableAddress 商户可用的收款地址，优先使用商户专属地址，没有时使用公共地址池
+func GetAvailableAddress(address, tradeType string, merchantId int64) []WalletAddress {
 	var rows []WalletAddress
-	var db = DB.Where("trade_type = ?", tradeType)
-	if address != "" {
-
-		db = db.Where("address = ?", address)
-	} else {
+	if address == "" {
+		DB.Where("trade_type = ? and status = ? and merchant_id = ?", tradeType, StatusEnable, merchantId).Find(&rows)
+		if len(rows) == 0 && merchantId != 0 {
+			DB.Where("trade_type = ? and status = ? and merchant_id = 0", tradeType, StatusEnable).Find(&rows)
+		}
 
-		db = db.Where("status = ?", StatusEnable)
+		return rows
 	}
 
-	db.Find(&rows)
+	DB.Where("trade_type = ? and address = ?", tradeType, address).Find(&rows)
+	if len(rows) == 0 {
+		// 商户自行指定的新地址归属该商户，避免被其它商户的订单使用
+		var wa = WalletAddress{TradeType: tradeType, Address: address, Status: StatusEnable, OtherNotify: OtherNotifyDisable, MerchantId: merchantId}
 
-	if address != "" {
-		if len(rows) == 0 {
-			var wa = WalletAddress{TradeType: tradeType, Address: address, Status: StatusEnable, OtherNotify: OtherNotifyDisable}
+		DB.Create(&wa)
 
-			DB.Create(&wa)
-
-			return []WalletAddress{wa}
-		}
+		return []WalletAddress{wa}
+	}
 
-		// 过滤掉禁用的钱包地址
-		filteredRows := make([]WalletAddress, 0, len(rows))
-		for _, row := range rows {
-			if row.Status == StatusEnable {
-				filteredRows = append(filteredRows, row)
-			}
+	// 过滤掉禁用及其它商户专属的钱包地址
+	filteredRows := make([]WalletAddress, 0, len(rows))
+	for _, row := range rows {
+		if row.Status == StatusEnable && (row.MerchantId == 0 || row.MerchantId == merchantId) {
+			filteredRows = append(filteredRows, row)
 		}
-		return filteredRows
 	}
-
-	return rows
+	return filteredRows
 }
 
 func GetAvailableTradeType() (map[string][]string, error) {
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+const blockHashKeepNum = 2000 // 每个网络保留的区块哈希数量
+
+// BlockHash 已扫描的区块哈希，用于检测区块重组
+type BlockHash struct {
+	ID         int64     `gorm:"primary_key;AUTO_INCREMENT;comment:id"`
+	Network    string    `gorm:"column:network;type:varchar(20);not null;uniqueIndex:idx_block_hash_network_number;comment:区块网络"`
+	Number     int64     `gorm:"column:number;type:bigint(20);not null;uniqueIndex:idx_block_hash_network_number;comment:区块高度"`
+	Hash       string    `gorm:"column:hash;type:varchar(66);not null;comment:区块哈希"`
+	ParentHash string    `gorm:"column:parent_hash;type:varchar(66);not null;comment:父区块哈希"`
+	CreatedAt  time.Time `gorm:"autoCreateTime;type:timestamp;not null;comment:创建时间"`
+}
+
+func (b *BlockHash) TableName() string {
+
+	return "block_hash"
+}
+
+func GetBlockHashes(network string, from, to int64) map[int64]string {
+	var rows []BlockHash
+	var data = make(map[int64]string)
+
+	DB.Where("network = ? and number >= ? and number <= ?", network, from, to).Find(&rows)
+	for _, row := range rows {
+		data[row.Number] = row.Hash
+	}
+
+	return data
+}
+
+func SaveBlockHashes(network string, rows []BlockHash) error {
+	if len(rows) == 0 {
+
+		return nil
+	}
+
+	var top int64
+	for _, row := range rows {
+		top = max(top, row.Number)
+	}
+
+	err := DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "network"}, {Name: "number"}},
+		DoUpdates: clause.AssignmentColumns([]string{"hash", "parent_hash"}),
+	}).Create(&rows).Error
+	if err != nil {
+
+		return err
+	}
+
+	return DB.Where("network = ? and number < ?", network, top-blockHashKeepNum).Delete(&BlockHash{}).Error
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"strings"
+
+	"github.com/v03413/bepusdt/app/conf"
+	"github.com/v03413/bepusdt/app/help"
+)
+
+// registerChains 将配置文件 [[chains]] 声明的通用 EVM 网络代币注册为可用的交易类型
+func registerChains() {
+	for _, c := range conf.GetChains() {
+		tradeTypeLabel[c.Network] = c.Name
+		for _, t := range c.Tokens {
+			tradeTypeTable[t.TradeType] = TokenType(t.Symbol)
+			tradeTypeLabel[strings.SplitN(t.TradeType, ".", 2)[1]] = c.Name
+			if !help.InStrings(t.TradeType, SupportTradeTypes) {
+				SupportTradeTypes = append(SupportTradeTypes, t.TradeType)
+			}
+		}
+	}
+}
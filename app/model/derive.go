@@ -0,0 +1,111 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/spf13/cast"
+	"github.com/v03413/bepusdt/app/conf"
+	"github.com/v03413/bepusdt/app/help"
+)
+
+const (
+	xpubIndexEvm  = "xpub_index_evm"  // EVM 扩展公钥下一个派生索引
+	xpubIndexTron = "xpub_index_tron" // Tron 扩展公钥下一个派生索引
+)
+
+var deriveMutex sync.Mutex
+
+// getXpub 交易类型对应的扩展公钥及派生索引键，未配置则返回空
+func getXpub(tradeType string) (string, string) {
+	if help.InStrings(tradeType, []string{OrderTradeTypeTronTrx, OrderTradeTypeUsdtTrc20, OrderTradeTypeUsdcTrc20}) {
+
+		return conf.GetXpubTron(), xpubIndexTron
+	}
+
+	var wa = WalletAddress{TradeType: tradeType}
+	if wa.GetEvmRpcEndpoint() != "" {
+
+		return conf.GetXpubEvm(), xpubIndexEvm
+	}
+
+	return "", ""
+}
+
+// IsDeriveEnabled 交易类型是否启用了按订单派生收款地址
+func IsDeriveEnabled(tradeType string) bool {
+	xpub, _ := getXpub(tradeType)
+
+	return xpub != ""
+}
+
+// DeriveTradeAmount 派生新的收款地址，订单按地址匹配，支付数额无需递增
+func DeriveTradeAmount(rate, money float64, tradeType string) (WalletAddress, string, error) {
+	payAmount, err := calcPayAmount(rate, money, tradeType)
+	if err != nil {
+
+		return WalletAddress{}, "", err
+	}
+
+	wa, err := DeriveWalletAddress(tradeType)
+	if err != nil {
+
+		return WalletAddress{}, "", err
+	}
+
+	return wa, payAmount.String(), nil
+}
+
+// DeriveWalletAddress 从扩展公钥派生下一个收款地址(路径 xpub/0/index)，并登记为收款钱包
+func DeriveWalletAddress(tradeType string) (WalletAddress, error) {
+	deriveMutex.Lock()
+	defer deriveMutex.Unlock()
+
+	xpub, key := getXpub(tradeType)
+	ext, err := help.ParseXpub(xpub)
+	if err != nil {
+
+		return WalletAddress{}, err
+	}
+
+	for index := cast.ToUint32(GetK(key)); index < 0x80000000; index++ {
+		child, err := ext.Derive(0, index)
+		if err != nil { // 极小概率派生结果无效，按 BIP32 规范跳过该索引
+
+			continue
+		}
+
+		// 先推进索引，即使后续登记失败也不会重复使用同一地址
+		SetK(key, cast.ToString(index+1))
+
+		var address = child.EvmAddress()
+		if key == xpubIndexTron {
+			address = child.TronAddress()
+		}
+
+		var wa = WalletAddress{TradeType: tradeType, Address: address, Status: StatusEnable, OtherNotify: OtherNotifyDisable, DerivePath: fmt.Sprintf("0/%d", index)}
+		if err = DB.Create(&wa).Error; err != nil {
+
+			return WalletAddress{}, err
+		}
+
+		return wa, nil
+	}
+
+	return WalletAddress{}, errors.New("扩展公钥可派生地址已耗尽")
+}
+
+// GetDerivedWaitingOrders 使用派生地址收款的等待支付订单 [地址+交易类型] => 订单
+func GetDerivedWaitingOrders() map[string]TradeOrders {
+	var orders []TradeOrders
+	var data = make(map[string]TradeOrders)
+
+	DB.Where("status = ? and address in (?)", OrderStatusWaiting,
+		DB.Model(&WalletAddress{}).Select("address").Where("derive_path <> ''")).Find(&orders)
+	for _, o := range orders {
+		data[o.Address+o.TradeType] = o
+	}
+
+	return data
+}
@@ -0,0 +1,158 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ActorScanner  = "scanner"  // 区块扫描及定时任务
+	ActorBot      = "bot"      // 机器人管理员操作
+	ActorApi      = "api"      // 商户接口调用
+	ActorCheckout = "checkout" // 收银台用户操作
+)
+
+// ErrOrderConflict 订单在读取后已被其它流程更新，本次变更未生效
+var ErrOrderConflict = errors.New("订单已被其它流程更新")
+
+// orderTransitions 订单状态允许的变更，0 表示新建订单
+var orderTransitions = map[int][]int{
+	0:                     {OrderStatusWaiting},
+	OrderStatusWaiting:    {OrderStatusWaiting, OrderStatusConfirming, OrderStatusPartial, OrderStatusExpired, OrderStatusCanceled, OrderStatusLatePaid, OrderStatusSuccess},
+	OrderStatusPartial:    {OrderStatusPartial, OrderStatusConfirming, OrderStatusExpired, OrderStatusSuccess},
+	OrderStatusConfirming: {OrderStatusSuccess, OrderStatusFailed, OrderStatusWaiting, OrderStatusPartial},
+	OrderStatusExpired:    {OrderStatusConfirming, OrderStatusLatePaid, OrderStatusSuccess},
+	OrderStatusLatePaid:   {OrderStatusSuccess, OrderStatusExpired},
+}
+
+var orderStatusNames = map[int]string{
+	0:                     "new",
+	OrderStatusWaiting:    "waiting",
+	OrderStatusSuccess:    "success",
+	OrderStatusExpired:    "expired",
+	OrderStatusCanceled:   "canceled",
+	OrderStatusConfirming: "confirming",
+	OrderStatusFailed:     "failed",
+	OrderStatusPartial:    "partially_paid",
+	OrderStatusLatePaid:   "late_paid",
+}
+
+// OrderEvent 订单状态变更记录
+type OrderEvent struct {
+	ID         int64     `gorm:"primary_key;AUTO_INCREMENT;comment:id" json:"-"`
+	TradeId    string    `gorm:"column:trade_id;type:varchar(128);not null;index;comment:本地ID" json:"-"`
+	FromStatus int       `gorm:"column:from_status;type:tinyint(1);not null;default:0;comment:变更前状态" json:"from"`
+	ToStatus   int       `gorm:"column:to_status;type:tinyint(1);not null;comment:变更后状态" json:"to"`
+	Actor      string    `gorm:"column:actor;type:varchar(20);not null;default:'';comment:操作来源" json:"actor"`
+	Reason     string    `gorm:"column:reason;type:varchar(255);not null;default:'';comment:变更原因" json:"reason"`
+	TxHash     string    `gorm:"column:tx_hash;type:varchar(130);not null;default:'';comment:交易哈希" json:"tx_hash"`
+	CreatedAt  time.Time `gorm:"autoCreateTime;type:timestamp;not null;comment:创建时间" json:"created_at"`
+}
+
+func (e *OrderEvent) TableName() string {
+
+	return "order_event"
+}
+
+func OrderStatusName(status int) string {
+	if name, ok := orderStatusNames[status]; ok {
+
+		return name
+	}
+
+	return fmt.Sprintf("unknown(%d)", status)
+}
+
+func canTransition(from, to int) bool {
+	for _, v := range orderTransitions[from] {
+		if v == to {
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// Transition 校验并变更订单状态，订单数据、状态变更记录及需要产生的通知在同一事务中保存
+func (o *TradeOrders) Transition(to int, actor, reason string, effects ...Effect) error {
+
+	return o.transitionWith(to, actor, reason, nil, effects...)
+}
+
+// transitionWith 同 Transition，with 不为空时在同一事务中执行，返回错误则整体回滚
+func (o *TradeOrders) transitionWith(to int, actor, reason string, with func(tx *gorm.DB) error, effects ...Effect) error {
+	var from = o.Status
+	if !canTransition(from, to) {
+
+		return fmt.Errorf("订单状态不允许从 %s 变更为 %s", OrderStatusName(from), OrderStatusName(to))
+	}
+
+	var hash string
+	if o.TradeHash != o.TradeId {
+		hash = o.TradeHash
+	}
+
+	var version = o.Version
+	o.Status = to
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := o.compareAndSave(tx, from, version); err != nil {
+
+			return err
+		}
+
+		if err := tx.Create(&OrderEvent{TradeId: o.TradeId, FromStatus: from, ToStatus: to, Actor: actor, Reason: reason, TxHash: hash}).Error; err != nil {
+
+			return err
+		}
+
+		if with != nil {
+			if err := with(tx); err != nil {
+
+				return err
+			}
+		}
+
+		return o.saveOutbox(tx, effects)
+	})
+	if err != nil {
+		o.Status = from
+		o.Version = version
+	}
+
+	return err
+}
+
+// compareAndSave 仅当数据库中订单仍为读取时的状态及版本才写入，避免旧副本覆盖其它流程的更新
+func (o *TradeOrders) compareAndSave(tx *gorm.DB, status, version int) error {
+	o.Version = version + 1
+	if o.Id == 0 {
+
+		return tx.Create(o).Error
+	}
+
+	// 回调字段由回调流程单独更新，不随状态变更覆盖
+	var res = tx.Model(o).Where("status = ? and version = ?", status, version).Select("*").Omit("id", "created_at", "notify_num", "notify_state").Updates(o)
+	if res.Error != nil {
+
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+
+		return ErrOrderConflict
+	}
+
+	return nil
+}
+
+// GetOrderEvents 订单状态变更记录，按时间先后排序
+func GetOrderEvents(tradeId string) []OrderEvent {
+	var rows = make([]OrderEvent, 0)
+
+	DB.Where("trade_id = ?", tradeId).Order("id asc").Find(&rows)
+
+	return rows
+}
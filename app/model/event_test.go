@@ -0,0 +1,137 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	var cases = []struct {
+		from, to int
+		want     bool
+	}{
+		{0, OrderStatusWaiting, true},
+		{0, OrderStatusSuccess, false},
+		{OrderStatusWaiting, OrderStatusConfirming, true},
+		{OrderStatusWaiting, OrderStatusPartial, true},
+		{OrderStatusPartial, OrderStatusWaiting, false},
+		{OrderStatusConfirming, OrderStatusSuccess, true},
+		{OrderStatusConfirming, OrderStatusExpired, false},
+		{OrderStatusExpired, OrderStatusLatePaid, true},
+		{OrderStatusExpired, OrderStatusWaiting, false},
+		{OrderStatusLatePaid, OrderStatusSuccess, true},
+		{OrderStatusSuccess, OrderStatusWaiting, false},
+		{OrderStatusCanceled, OrderStatusSuccess, false},
+		{OrderStatusFailed, OrderStatusConfirming, false},
+	}
+
+	for _, c := range cases {
+		if got := canTransition(c.from, c.to); got != c.want {
+			t.Errorf("canTransition(%s, %s) = %v, want %v", OrderStatusName(c.from), OrderStatusName(c.to), got, c.want)
+		}
+	}
+}
+
+func TestTransition(t *testing.T) {
+	var cases = []struct {
+		name    string
+		from    int
+		to      int
+		effects []Effect
+		err     bool
+	}{
+		{"waiting to confirming", OrderStatusWaiting, OrderStatusConfirming, nil, false},
+		{"confirming to success with effects", OrderStatusConfirming, OrderStatusSuccess, paidEffects(), false},
+		{"expired to late paid", OrderStatusExpired, OrderStatusLatePaid, []Effect{BotEffect(BotMsgLatePaid, nil)}, false},
+		{"success is final", OrderStatusSuccess, OrderStatusWaiting, paidEffects(), true},
+		{"canceled is final", OrderStatusCanceled, OrderStatusConfirming, nil, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setupTestDB(t)
+
+			var o = createTestOrder(t, TradeOrders{TradeId: "t1", Amount: "10", Status: c.from})
+			var version = o.Version
+
+			err := o.Transition(c.to, ActorScanner, "test", c.effects...)
+			if c.err {
+				if err == nil {
+					t.Fatal("expected transition rejected")
+				}
+				if o.Status != c.from || o.Version != version {
+					t.Fatalf("order not restored, status %s version %d", OrderStatusName(o.Status), o.Version)
+				}
+				if saved, _ := GetTradeOrder("t1"); saved.Status != c.from {
+					t.Fatalf("unexpected saved status %s", OrderStatusName(saved.Status))
+				}
+				if n := len(GetOrderEvents("t1")); n != 0 {
+					t.Fatalf("unexpected %d events", n)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			saved, _ := GetTradeOrder("t1")
+			if saved.Status != c.to || saved.Version != version+1 {
+				t.Fatalf("unexpected saved status %s version %d", OrderStatusName(saved.Status), saved.Version)
+			}
+
+			var events = GetOrderEvents("t1")
+			if len(events) != 1 || events[0].FromStatus != c.from || events[0].ToStatus != c.to || events[0].Actor != ActorScanner {
+				t.Fatalf("unexpected events %+v", events)
+			}
+
+			var outbox int64
+			DB.Model(&Outbox{}).Where("trade_id = ?", "t1").Count(&outbox)
+			if int(outbox) != len(c.effects) {
+				t.Fatalf("outbox rows = %d, want %d", outbox, len(c.effects))
+			}
+		})
+	}
+}
+
+func TestTransitionConflict(t *testing.T) {
+	var cases = []struct {
+		name  string
+		first int
+		stale int
+	}{
+		{"concurrent confirm", OrderStatusConfirming, OrderStatusConfirming},
+		{"expire after payment", OrderStatusConfirming, OrderStatusExpired},
+		{"cancel after partial payment", OrderStatusPartial, OrderStatusCanceled},
+		{"same status newer version", OrderStatusWaiting, OrderStatusConfirming},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setupTestDB(t)
+
+			var o = createTestOrder(t, TradeOrders{TradeId: "t1", Amount: "10", Status: OrderStatusWaiting})
+			var stale = o
+
+			if err := o.Transition(c.first, ActorScanner, "first"); err != nil {
+				t.Fatal(err)
+			}
+
+			// 旧副本的状态与版本已过时，写入不能覆盖其它流程的更新
+			if err := stale.Transition(c.stale, ActorBot, "stale"); err != ErrOrderConflict {
+				t.Fatalf("err = %v, want ErrOrderConflict", err)
+			}
+			if stale.Status != OrderStatusWaiting || stale.Version != o.Version-1 {
+				t.Fatalf("stale copy not restored, status %s version %d", OrderStatusName(stale.Status), stale.Version)
+			}
+
+			saved, _ := GetTradeOrder("t1")
+			if saved.Status != c.first || saved.Version != o.Version {
+				t.Fatalf("unexpected saved status %s version %d", OrderStatusName(saved.Status), saved.Version)
+			}
+			if n := len(GetOrderEvents("t1")); n != 1 {
+				t.Fatalf("unexpected %d events", n)
+			}
+		})
+	}
+}
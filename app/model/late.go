@@ -0,0 +1,91 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/v03413/bepusdt/app/conf"
+)
+
+// LateOrders 最近过期且尚未关联交易的订单 [地址+数额+交易类型] => 订单，按过期先后倒序，每批交易只查询一次
+type LateOrders map[string][]TradeOrders
+
+// GetLateOrders 失效时间晚于 since 减去检测时限的过期订单，since 为本批交易中最早的交易时间
+func GetLateOrders(since time.Time) LateOrders {
+	var orders []TradeOrders
+	var data = make(LateOrders)
+
+	DB.Where("status = ? and trade_hash = trade_id and expired_at > ?", OrderStatusExpired, since.Add(-conf.GetLatePaidWindow())).
+		Order("id desc").Find(&orders)
+	for _, o := range orders {
+		var key = o.Address + o.Amount + o.TradeType
+		data[key] = append(data[key], o)
+	}
+
+	return data
+}
+
+// MatchExpired 追赶扫描历史区块时，订单可能已被标记过期，查找交易时间仍处于有效期内的订单，匹配后从缓存移除
+func (l LateOrders) MatchExpired(address, amount, tradeType string, at time.Time) (TradeOrders, bool) {
+	var key = address + amount + tradeType
+	for i, o := range l[key] {
+		if !o.CreatedAt.Before(at) || !o.ExpiredAt.After(at) {
+
+			continue
+		}
+
+		l[key] = append(l[key][:i:i], l[key][i+1:]...)
+
+		return o, true
+	}
+
+	return TradeOrders{}, false
+}
+
+// Match 查找交易时间晚于失效时间、且在检测时限内的最近过期订单，匹配后从缓存移除，避免同一批次重复匹配
+func (l LateOrders) Match(address, amount, tradeType string, at time.Time) (TradeOrders, bool) {
+	var key = address + amount + tradeType
+	for i, o := range l[key] {
+		if o.ExpiredAt.After(at) || !o.ExpiredAt.After(at.Add(-conf.GetLatePaidWindow())) {
+
+			continue
+		}
+
+		l[key] = append(l[key][:i:i], l[key][i+1:]...)
+
+		return o, true
+	}
+
+	return TradeOrders{}, false
+}
+
+// MarkLatePaid 订单过期后才收到支付，等待管理员确认是否收款
+func (o *TradeOrders) MarkLatePaid(blockNum int64, from, hash string, at time.Time) error {
+	o.ActualAmount = o.PaidAmount
+	o.FromAddress = from
+	o.ConfirmedAt = at
+	o.TradeHash = hash
+	o.RefBlockNum = blockNum
+
+	return o.Transition(OrderStatusLatePaid, ActorScanner, "订单过期后收到支付", WebhookEffect(WebhookEventOrderLatePaid), BotEffect(BotMsgLatePaid, nil))
+}
+
+// AcceptLatePaid 确认过期后收到的支付，订单标记为收款成功并按正常流程通知
+func (o *TradeOrders) AcceptLatePaid(actor string) error {
+	if o.Status != OrderStatusLatePaid {
+
+		return errors.New("订单不是过期后支付状态")
+	}
+
+	return o.SetPaid(actor, "确认过期后支付")
+}
+
+// IgnoreLatePaid 忽略过期后收到的支付，订单恢复为过期状态，交易哈希保留以免重复提醒
+func (o *TradeOrders) IgnoreLatePaid() error {
+	if o.Status != OrderStatusLatePaid {
+
+		return errors.New("订单不是过期后支付状态")
+	}
+
+	return o.Transition(OrderStatusExpired, ActorBot, "忽略过期后支付")
+}
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLateOrdersMatch(t *testing.T) {
+	var now = time.Now()
+	var cases = []struct {
+		name   string
+		order  TradeOrders
+		paidAt time.Time
+		match  bool
+	}{
+		{"paid after expiration", TradeOrders{ExpiredAt: now.Add(-time.Hour)}, now, true},
+		{"paid before expiration", TradeOrders{ExpiredAt: now.Add(-time.Hour)}, now.Add(-2 * time.Hour), false},
+		{"paid beyond late window", TradeOrders{ExpiredAt: now.Add(-25 * time.Hour)}, now, false},
+		{"payment already linked", TradeOrders{ExpiredAt: now.Add(-time.Hour), TradeHash: "hash"}, now, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setupTestDB(t)
+
+			var o = c.order
+			o.TradeId, o.Amount, o.Address, o.Status = "t1", "10", "TAddr", OrderStatusExpired
+			createTestOrder(t, o)
+
+			var late = GetLateOrders(c.paidAt)
+			_, ok := late.Match("TAddr", "10", OrderTradeTypeUsdtTrc20, c.paidAt)
+			if ok != c.match {
+				t.Fatalf("matched = %v, want %v", ok, c.match)
+			}
+
+			// 同一批次内已匹配的订单不再重复匹配
+			if _, ok = late.Match("TAddr", "10", OrderTradeTypeUsdtTrc20, c.paidAt); ok {
+				t.Fatal("order matched twice in one batch")
+			}
+		})
+	}
+}
+
+func TestLateOrdersMatchExpired(t *testing.T) {
+	var now = time.Now()
+	var cases = []struct {
+		name   string
+		order  TradeOrders
+		paidAt time.Time
+		match  bool
+	}{
+		{"paid within original window", TradeOrders{ExpiredAt: now.Add(-time.Minute)}, now.Add(-5 * time.Minute), true},
+		{"paid after expiration", TradeOrders{ExpiredAt: now.Add(-time.Hour)}, now, false},
+		{"paid before creation", TradeOrders{ExpiredAt: now.Add(-time.Minute)}, now.Add(-time.Hour), false},
+		{"payment already linked", TradeOrders{ExpiredAt: now.Add(-time.Minute), TradeHash: "hash"}, now.Add(-5 * time.Minute), false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setupTestDB(t)
+
+			var o = c.order
+			o.TradeId, o.Amount, o.Address, o.Status = "t1", "10", "TAddr", OrderStatusExpired
+			o.CreatedAt = now.Add(-20 * time.Minute)
+			createTestOrder(t, o)
+
+			var late = GetLateOrders(c.paidAt)
+			_, ok := late.MatchExpired("TAddr", "10", OrderTradeTypeUsdtTrc20, c.paidAt)
+			if ok != c.match {
+				t.Fatalf("matched = %v, want %v", ok, c.match)
+			}
+
+			// 同一批次内已匹配的订单不再重复匹配
+			if _, ok = late.MatchExpired("TAddr", "10", OrderTradeTypeUsdtTrc20, c.paidAt); ok {
+				t.Fatal("order matched twice in one batch")
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/v03413/bepusdt/app/help"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	LedgerTypeTransfer   = "transfer"   // 代币或原生币转账
+	LedgerTypeDelegate   = "delegate"   // Tron 资源代理
+	LedgerTypeUndelegate = "undelegate" // Tron 资源回收
+)
+
+// Ledger 收款钱包相关的全部链上流水，用于财务对账
+type Ledger struct {
+	ID          int64     `gorm:"primary_key;AUTO_INCREMENT;comment:id" json:"id"`
+	Network     string    `gorm:"column:network;type:varchar(20);not null;index;comment:区块网络" json:"network"`
+	Type        string    `gorm:"column:type;type:varchar(20);not null;default:'transfer';comment:流水类型" json:"type"`
+	TxHash      string    `gorm:"column:tx_hash;type:varchar(130);not null;uniqueIndex:idx_ledger_uniq;comment:交易哈希" json:"tx_hash"`
+	LogIndex    int64     `gorm:"column:log_index;type:bigint(20);not null;default:0;uniqueIndex:idx_ledger_uniq;comment:日志序号" json:"log_index"`
+	FromAddress string    `gorm:"column:from_address;type:varchar(66);not null;default:'';uniqueIndex:idx_ledger_uniq;index;comment:发送地址" json:"from_address"`
+	RecvAddress string    `gorm:"column:recv_address;type:varchar(66);not null;default:'';uniqueIndex:idx_ledger_uniq;index;comment:接收地址" json:"recv_address"`
+	Token       string    `gorm:"column:token;type:varchar(20);not null;uniqueIndex:idx_ledger_uniq;comment:交易类型或资源类型" json:"token"`
+	Amount      string    `gorm:"column:amount;type:decimal(30,8);not null;default:0;comment:数额" json:"amount"`
+	BlockNum    int64     `gorm:"column:block_num;type:bigint(20);not null;default:0;comment:所在区块" json:"block_num"`
+	Timestamp   time.Time `gorm:"column:timestamp;type:timestamp;not null;index;comment:交易时间" json:"timestamp"`
+	TradeId     string    `gorm:"column:trade_id;type:varchar(128);not null;default:'';index;comment:关联订单" json:"trade_id"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;type:timestamp;not null;comment:创建时间" json:"created_at"`
+}
+
+func (l *Ledger) TableName() string {
+
+	return "ledger"
+}
+
+type LedgerQuery struct {
+	Address string
+	Network string
+	Token   string
+	Start   time.Time
+	End     time.Time
+	Page    int
+	Size    int
+}
+
+// SaveLedgers 写入流水，重复扫描的交易直接忽略
+func SaveLedgers(rows []Ledger) error {
+	if len(rows) == 0 {
+
+		return nil
+	}
+
+	return DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+}
+
+// LinkLedger 流水关联订单
+func LinkLedger(hash, recv, tradeId string) {
+	DB.Model(&Ledger{}).Where("tx_hash = ? and recv_address = ?", hash, recv).Update("trade_id", tradeId)
+}
+
+// QueryLedgers 按地址、网络、交易类型及时间筛选流水，按交易时间倒序分页
+func QueryLedgers(q LedgerQuery) ([]Ledger, int64) {
+	var rows = make([]Ledger, 0)
+	var total int64
+
+	var db = DB.Model(&Ledger{})
+	if address := strings.TrimSpace(q.Address); address != "" {
+		// EVM 地址统一以小写保存
+		if help.IsValidEvmAddress(address) {
+			address = strings.ToLower(address)
+		}
+
+		db = db.Where("from_address = ? or recv_address = ?", address, address)
+	}
+	if q.Network != "" {
+		db = db.Where("network = ?", q.Network)
+	}
+	if q.Token != "" {
+		db = db.Where("token = ?", q.Token)
+	}
+	if !q.Start.IsZero() {
+		db = db.Where("timestamp >= ?", q.Start)
+	}
+	if !q.End.IsZero() {
+		db = db.Where("timestamp < ?", q.End)
+	}
+
+	db.Count(&total)
+	db.Order("timestamp desc, id desc").Offset((q.Page - 1) * q.Size).Limit(q.Size).Find(&rows)
+
+	return rows, total
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestQueryLedgers(t *testing.T) {
+	setupTestDB(t)
+
+	var now = time.Now()
+	var rows = []Ledger{
+		{Network: "ethereum", TxHash: "0x01", FromAddress: "0xfrom", RecvAddress: "0xabcdef0123456789abcdef0123456789abcdef01", Token: OrderTradeTypeUsdtErc20, Amount: "10", Timestamp: now.Add(-time.Hour)},
+		{Network: "ethereum", TxHash: "0x02", FromAddress: "0xabcdef0123456789abcdef0123456789abcdef01", RecvAddress: "0xother", Token: OrderTradeTypeUsdtErc20, Amount: "5", Timestamp: now.Add(-time.Minute)},
+		{Network: "bsc", TxHash: "0x03", FromAddress: "0xfrom", RecvAddress: "0xabcdef0123456789abcdef0123456789abcdef01", Token: OrderTradeTypeUsdtBep20, Amount: "1", Timestamp: now},
+		{Network: "tron", TxHash: "t04", FromAddress: "TFrom", RecvAddress: "TRecvAddr", Token: OrderTradeTypeUsdtTrc20, Amount: "2", Timestamp: now},
+	}
+	if err := SaveLedgers(rows); err != nil {
+		t.Fatal(err)
+	}
+
+	var cases = []struct {
+		name   string
+		query  LedgerQuery
+		hashes []string
+		total  int64
+	}{
+		{"evm address case insensitive", LedgerQuery{Address: "0xABCDEF0123456789ABCDEF0123456789ABCDEF01"}, []string{"0x03", "0x02", "0x01"}, 3},
+		{"address with network", LedgerQuery{Address: " 0xAbCdEf0123456789aBcDeF0123456789AbCdEf01 ", Network: "ethereum"}, []string{"0x02", "0x01"}, 2},
+		{"tron address kept as is", LedgerQuery{Address: "TRecvAddr"}, []string{"t04"}, 1},
+		{"tron address case sensitive", LedgerQuery{Address: "trecvaddr"}, []string{}, 0},
+		{"time range", LedgerQuery{Start: now.Add(-time.Hour * 2), End: now.Add(-time.Second)}, []string{"0x02", "0x01"}, 2},
+		{"second page", LedgerQuery{Page: 2, Size: 3}, []string{"0x01"}, 4},
+	}
+
+	for _, c := range cases {
+		if c.query.Page == 0 {
+			c.query.Page, c.query.Size = 1, 20
+		}
+
+		list, total := QueryLedgers(c.query)
+		var hashes = make([]string, 0, len(list))
+		for _, l := range list {
+			hashes = append(hashes, l.TxHash)
+		}
+
+		if total != c.total || fmt.Sprint(hashes) != fmt.Sprint(c.hashes) {
+			t.Errorf("%s: got %v total %d, want %v total %d", c.name, hashes, total, c.hashes, c.total)
+		}
+	}
+}
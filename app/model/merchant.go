@@ -0,0 +1,127 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/v03413/bepusdt/app/conf"
+	"github.com/v03413/bepusdt/app/help"
+)
+
+// Merchant 接入商户，拥有独立的签名密钥、易支付商户号、Webhook 地址、交易类型及收款地址池
+type Merchant struct {
+	ID         int64     `gorm:"primary_key;AUTO_INCREMENT;comment:id"`
+	Pid        string    `gorm:"column:pid;type:varchar(32);not null;uniqueIndex;comment:商户号"`
+	Name       string    `gorm:"column:name;type:varchar(64);not null;default:'';comment:商户名称"`
+	Secret     string    `gorm:"column:secret;type:varchar(128);not null;comment:签名密钥"`
+	WebhookUrl string    `gorm:"column:webhook_url;type:varchar(255);not null;default:'';comment:Webhook地址"`
+	TradeTypes string    `gorm:"column:trade_types;type:varchar(1024);not null;default:'';comment:允许的交易类型，逗号分隔，留空不限制"`
+	Status     uint8     `gorm:"column:status;type:tinyint(1);not null;default:1;comment:商户状态"`
+	CreatedAt  time.Time `gorm:"autoCreateTime;type:timestamp;not null;comment:创建时间"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime;type:timestamp;not null;comment:更新时间"`
+}
+
+func (m *Merchant) TableName() string {
+
+	return "merchant"
+}
+
+// DefaultMerchant 默认商户，未携带商户号的请求及历史订单均归属于此
+func DefaultMerchant() Merchant {
+
+	return Merchant{Pid: conf.DefaultMerchantPid, Name: "默认商户", Secret: conf.GetAuthToken(), WebhookUrl: conf.GetWebhookUrl(), Status: StatusEnable}
+}
+
+func (m Merchant) IsDefault() bool {
+
+	return m.ID == 0
+}
+
+func (m Merchant) AllowTradeType(tradeType string) bool {
+	if m.TradeTypes == "" {
+
+		return true
+	}
+
+	return help.InStrings(tradeType, strings.Split(m.TradeTypes, ","))
+}
+
+// GetMerchantByPid 根据请求携带的商户号查找启用的商户，商户号为空时使用默认商户
+func GetMerchantByPid(pid string) (Merchant, bool) {
+	if pid == "" || pid == conf.DefaultMerchantPid {
+
+		return DefaultMerchant(), true
+	}
+
+	var m Merchant
+	var res = DB.Where("pid = ? and status = ?", pid, StatusEnable).Limit(1).Find(&m)
+
+	return m, res.RowsAffected > 0
+}
+
+// GetMerchant 订单所属商户，商户已被移除时仍返回其记录用于回调签名
+func GetMerchant(id int64) Merchant {
+	if id == 0 {
+
+		return DefaultMerchant()
+	}
+
+	var m Merchant
+	if DB.Where("id = ?", id).Limit(1).Find(&m).RowsAffected == 0 {
+
+		return DefaultMerchant()
+	}
+
+	return m
+}
+
+// GetMerchants 所有启用的商户，不含默认商户
+func GetMerchants() []Merchant {
+	var rows = make([]Merchant, 0)
+
+	DB.Where("status = ?", StatusEnable).Order("id asc").Find(&rows)
+
+	return rows
+}
+
+// syncMerchants 启动时将配置文件中的商户同步至数据库，配置中已移除的商户标记为禁用
+func syncMerchants() {
+	var pids = []string{conf.DefaultMerchantPid}
+	for _, c := range conf.GetMerchants() {
+		var m Merchant
+		DB.Where("pid = ?", c.Pid).Limit(1).Find(&m)
+
+		m.Pid = c.Pid
+		m.Name = c.Name
+		m.Secret = c.Secret
+		m.WebhookUrl = c.WebhookUrl
+		m.TradeTypes = strings.Join(c.TradeTypes, ",")
+		m.Status = StatusEnable
+		if err := DB.Save(&m).Error; err != nil {
+			fmt.Println("❌商户同步失败：", c.Pid, err)
+
+			continue
+		}
+
+		var wallets = make([]string, 0, len(c.Wallets))
+		for _, address := range c.Wallets {
+			if help.IsValidEvmAddress(address) {
+
+				address = strings.ToLower(address)
+			}
+
+			wallets = append(wallets, address)
+		}
+
+		DB.Model(&WalletAddress{}).Where("merchant_id = ?", m.ID).Update("merchant_id", 0)
+		if len(wallets) > 0 {
+			DB.Model(&WalletAddress{}).Where("address in (?) and derive_path = ''", wallets).Update("merchant_id", m.ID)
+		}
+
+		pids = append(pids, m.Pid)
+	}
+
+	DB.Model(&Merchant{}).Where("pid not in (?)", pids).Update("status", StatusDisable)
+	DB.Model(&WalletAddress{}).Where("merchant_id in (?)", DB.Model(&Merchant{}).Select("id").Where("status = ?", StatusDisable)).Update("merchant_id", 0)
+}
@@ -0,0 +1,152 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/v03413/bepusdt/app/conf"
+)
+
+// createTestMerchant 状态字段零值不会写入，需单独更新
+func createTestMerchant(t *testing.T, pid, secret string, status uint8) Merchant {
+	t.Helper()
+
+	var m = Merchant{Pid: pid, Secret: secret}
+	if err := DB.Create(&m).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	DB.Model(&m).Update("status", status)
+
+	return m
+}
+
+func TestMerchantAllowTradeType(t *testing.T) {
+	var cases = []struct {
+		types     string
+		tradeType string
+		want      bool
+	}{
+		{"", OrderTradeTypeUsdtTrc20, true},
+		{OrderTradeTypeUsdtTrc20, OrderTradeTypeUsdtTrc20, true},
+		{OrderTradeTypeUsdtTrc20 + "," + OrderTradeTypeUsdtPolygon, OrderTradeTypeUsdtPolygon, true},
+		{OrderTradeTypeUsdtTrc20, OrderTradeTypeUsdtPolygon, false},
+	}
+
+	for _, c := range cases {
+		var m = Merchant{TradeTypes: c.types}
+		if got := m.AllowTradeType(c.tradeType); got != c.want {
+			t.Errorf("AllowTradeType(%q) with %q = %v, want %v", c.tradeType, c.types, got, c.want)
+		}
+	}
+}
+
+func TestGetMerchantByPid(t *testing.T) {
+	setupTestDB(t)
+	createTestMerchant(t, "1001", "s1", StatusEnable)
+	createTestMerchant(t, "1002", "s2", StatusDisable)
+
+	var cases = []struct {
+		pid    string
+		ok     bool
+		secret string
+	}{
+		{"", true, conf.GetAuthToken()},
+		{conf.DefaultMerchantPid, true, conf.GetAuthToken()},
+		{"1001", true, "s1"},
+		{"1002", false, ""},
+		{"1003", false, ""},
+	}
+
+	for _, c := range cases {
+		m, ok := GetMerchantByPid(c.pid)
+		if ok != c.ok || m.Secret != c.secret {
+			t.Errorf("GetMerchantByPid(%q) = %q %v, want %q %v", c.pid, m.Secret, ok, c.secret, c.ok)
+		}
+	}
+}
+
+func TestGetMerchant(t *testing.T) {
+	setupTestDB(t)
+
+	var enabled = createTestMerchant(t, "1001", "s1", StatusEnable)
+	var disabled = createTestMerchant(t, "1002", "s2", StatusDisable)
+
+	var cases = []struct {
+		name   string
+		id     int64
+		secret string
+	}{
+		{"default merchant", 0, conf.GetAuthToken()},
+		{"enabled merchant", enabled.ID, "s1"},
+		{"disabled merchant still signs callbacks", disabled.ID, "s2"},
+		{"missing merchant falls back to default", 999, conf.GetAuthToken()},
+	}
+
+	for _, c := range cases {
+		if m := GetMerchant(c.id); m.Secret != c.secret {
+			t.Errorf("%s: secret = %q, want %q", c.name, m.Secret, c.secret)
+		}
+	}
+}
+
+func TestGetAvailableAddressByMerchant(t *testing.T) {
+	var cases = []struct {
+		name       string
+		merchantId int64
+		wallets    []WalletAddress
+		address    string
+		want       []string
+	}{
+		{"merchant wallets preferred", 1, []WalletAddress{{Address: "TPublic"}, {Address: "TOwn", MerchantId: 1}}, "", []string{"TOwn"}},
+		{"fallback to public pool", 1, []WalletAddress{{Address: "TPublic"}, {Address: "TOther", MerchantId: 2}}, "", []string{"TPublic"}},
+		{"default merchant uses public pool only", 0, []WalletAddress{{Address: "TPublic"}, {Address: "TOther", MerchantId: 2}}, "", []string{"TPublic"}},
+		{"other merchant wallet rejected", 1, []WalletAddress{{Address: "TOther", MerchantId: 2}}, "TOther", []string{}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setupTestDB(t)
+			for _, w := range c.wallets {
+				w.TradeType, w.Status = OrderTradeTypeUsdtTrc20, StatusEnable
+				DB.Create(&w)
+			}
+
+			var rows = GetAvailableAddress(c.address, OrderTradeTypeUsdtTrc20, c.merchantId)
+			if len(rows) != len(c.want) {
+				t.Fatalf("got %d wallets, want %v", len(rows), c.want)
+			}
+			for i, row := range rows {
+				if row.Address != c.want[i] {
+					t.Fatalf("wallet %d = %s, want %s", i, row.Address, c.want[i])
+				}
+			}
+		})
+	}
+}
+
+// 商户指定的新地址归属该商户，其它商户无法指定或自动分配到该地址
+func TestGetAvailableAddressExplicit(t *testing.T) {
+	setupTestDB(t)
+
+	if rows := GetAvailableAddress("TNew", OrderTradeTypeUsdtTrc20, 1); len(rows) != 1 || rows[0].MerchantId != 1 {
+		t.Fatalf("explicit address not bound to merchant: %+v", rows)
+	}
+
+	var cases = []struct {
+		name       string
+		merchantId int64
+		address    string
+		want       int
+	}{
+		{"owner reuses address", 1, "TNew", 1},
+		{"other merchant supplies address", 2, "TNew", 0},
+		{"other merchant auto selection", 2, "", 0},
+		{"default merchant auto selection", 0, "", 0},
+	}
+
+	for _, c := range cases {
+		if rows := GetAvailableAddress(c.address, OrderTradeTypeUsdtTrc20, c.merchantId); len(rows) != c.want {
+			t.Errorf("%s: got %d wallets, want %d", c.name, len(rows), c.want)
+		}
+	}
+}
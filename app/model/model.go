@@ -22,24 +22,15 @@ var DB *gorm.DB
 var err error
 
 func Init() error {
+	registerChains()
+
 	if len(conf.GetConfig().MySQL.DSN) > 0 {
 		if err := initMysql(); err != nil {
 			return err
 		}
 	} else {
-		var path = conf.GetSqlitePath()
-		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
-
-			return fmt.Errorf("创建数据库目录失败：%w", err)
-		}
-
-		DB, err = gorm.Open(sqlite.Open(path), gormConfig())
-		if err != nil {
-
-			return fmt.Errorf("数据库初始化失败：%w", err)
-		}
-		if conf.GetDebug() {
-			DB = DB.Debug()
+		if err := InitSqlite(conf.GetSqlitePath()); err != nil {
+			return err
 		}
 	}
 
@@ -49,13 +40,33 @@ func Init() error {
 	}
 
 	addStartWalletAddress()
+	syncMerchants()
+
+	return nil
+}
+
+// InitSqlite 打开指定路径的 sqlite 数据库，目录不存在时自动创建
+func InitSqlite(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+
+		return fmt.Errorf("创建数据库目录失败：%w", err)
+	}
+
+	DB, err = gorm.Open(sqlite.Open(path), gormConfig())
+	if err != nil {
+
+		return fmt.Errorf("数据库初始化失败：%w", err)
+	}
+	if conf.GetDebug() {
+		DB = DB.Debug()
+	}
 
 	return nil
 }
 
 func AutoMigrate() error {
 
-	return DB.AutoMigrate(&WalletAddress{}, &TradeOrders{}, &NotifyRecord{}, &Config{}, &Webhook{})
+	return DB.AutoMigrate(&WalletAddress{}, &TradeOrders{}, &NotifyRecord{}, &Config{}, &BlockHash{}, &TradePayment{}, &UnmatchedPayment{}, &Ledger{}, &OrderEvent{}, &Outbox{}, &Merchant{}, &RateHistory{}, &Quote{})
 }
 
 func gormConfig() *gorm.Config {
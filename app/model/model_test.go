@@ -0,0 +1,40 @@
+package model
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/v03413/bepusdt/app/log"
+)
+
+// setupTestDB 包内测试无法引用 modeltest，直接复用 InitSqlite
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	_ = log.Init()
+
+	if err := InitSqlite(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// createTestOrder 直接写入订单，绕过状态机以便构造任意状态
+func createTestOrder(t *testing.T, o TradeOrders) TradeOrders {
+	t.Helper()
+	if o.TradeHash == "" {
+		o.TradeHash = o.TradeId
+	}
+	if o.TradeType == "" {
+		o.TradeType = OrderTradeTypeUsdtTrc20
+	}
+	if o.ExpiredAt.IsZero() {
+		o.ExpiredAt = CalcTradeExpiredAt(0)
+	}
+	if err := DB.Create(&o).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return o
+}
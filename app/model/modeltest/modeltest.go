@@ -0,0 +1,23 @@
+// Package modeltest 为依赖数据库的测试提供临时 sqlite 数据库
+package modeltest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/v03413/bepusdt/app/log"
+	"github.com/v03413/bepusdt/app/model"
+)
+
+// Setup 在测试临时目录中初始化数据库并迁移表结构，测试结束后随目录一起清理
+func Setup(t testing.TB) {
+	t.Helper()
+	_ = log.Init()
+
+	if err := model.InitSqlite(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/v03413/bepusdt/app/help"
+	"github.com/v03413/bepusdt/app/log"
+)
+
+// GetOpenAmountAddress 不定额订单独占收款地址，选取没有其它待支付订单的钱包地址
+func GetOpenAmountAddress(wa []WalletAddress, tradeType string) (WalletAddress, error) {
+	for _, w := range wa {
+		var count int64
+		DB.Model(&TradeOrders{}).Where("status in (?) and trade_type = ? and address = ?", OrderOpenStatus, tradeType, w.Address).Count(&count)
+		if count == 0 {
+
+			return w, nil
+		}
+	}
+
+	return WalletAddress{}, fmt.Errorf("类型(%s)没有空闲的收款地址，不定额订单需独占收款地址", tradeType)
+}
+
+// ExcludeOpenAmountAddress 排除被不定额订单独占的收款地址
+func ExcludeOpenAmountAddress(wa []WalletAddress, tradeType string) []WalletAddress {
+	var used []string
+	DB.Model(&TradeOrders{}).Where("status = ? and trade_type = ? and open_amount = ?", OrderStatusWaiting, tradeType, true).Pluck("address", &used)
+	if len(used) == 0 {
+
+		return wa
+	}
+
+	var result = make([]WalletAddress, 0, len(wa))
+	for _, w := range wa {
+		if !help.InStrings(w.Address, used) {
+			result = append(result, w)
+		}
+	}
+
+	return result
+}
+
+// GetOpenAmountWaitingOrders 等待支付的不定额订单 [地址+交易类型] => 订单
+func GetOpenAmountWaitingOrders() map[string]TradeOrders {
+	var orders []TradeOrders
+	var data = make(map[string]TradeOrders)
+
+	DB.Where("status = ? and open_amount = ?", OrderStatusWaiting, true).Find(&orders)
+	for _, o := range orders {
+		if o.TradeType == OrderTradeTypeUsdtPolygon {
+			o.Address = strings.ToLower(o.Address)
+		}
+
+		data[o.Address+o.TradeType] = o
+	}
+
+	return data
+}
+
+// SettleOpenAmount 不定额订单按实际收款数额结算，订单金额按收款时的汇率折算
+func (o *TradeOrders) SettleOpenAmount(amount decimal.Decimal) error {
+	if !o.OpenAmount {
+
+		return errors.New("订单不是不定额订单")
+	}
+
+	tokenType, err := GetTokenType(o.TradeType)
+	if err != nil {
+
+		return err
+	}
+
+	// 款项已经到账，汇率过期时仍按最后汇率结算，快照中保留过期标记
+	snapshot, err := GetRateSnapshot(tokenType, o.Currency, "")
+	if err != nil {
+		log.Warn("不定额订单结算汇率已过期：", o.TradeId, err)
+	}
+
+	tradeRate, err := GetTradeRate(tokenType, o.Currency, "")
+	if err != nil {
+
+		return err
+	}
+	if tradeRate <= 0 {
+		log.Warn("不定额订单结算汇率无效，订单金额记为 0：", o.TradeId)
+	}
+
+	o.Amount = amount.String()
+	o.PaidAmount = amount.String()
+	o.TradeRate = fmt.Sprintf("%v", tradeRate)
+	o.RateSnapshot = snapshot.String()
+	o.Money = amount.Mul(decimal.NewFromFloat(tradeRate)).Round(2).InexactFloat64()
+
+	return nil
+}
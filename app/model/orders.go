@@ -3,13 +3,16 @@ package model
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/shopspring/decimal"
+	"github.com/spf13/cast"
 	"github.com/v03413/bepusdt/app/conf"
 	"github.com/v03413/bepusdt/app/help"
 	"github.com/v03413/bepusdt/app/task/rate"
+	"gorm.io/gorm"
 )
 
 const (
@@ -22,6 +25,8 @@ const (
 	OrderStatusCanceled   = 4 // 订单取消
 	OrderStatusConfirming = 5 // 等待交易确认
 	OrderStatusFailed     = 6 // 交易确认失败
+	OrderStatusPartial    = 7 // 部分支付 partially_paid
+	OrderStatusLatePaid   = 8 // 过期后收到支付 late_paid
 
 	OrderTradeTypeTronTrx      = "tron.trx"
 	OrderTradeTypeUsdtTrc20    = "usdt.trc20"
@@ -41,6 +46,18 @@ const (
 	OrderTradeTypeUsdcSolana   = "usdc.solana"
 	OrderTradeTypeUsdtAptos    = "usdt.aptos"
 	OrderTradeTypeUsdcAptos    = "usdc.aptos"
+	OrderTradeTypeUsdtTon      = "usdt.ton"
+	OrderTradeTypeEthErc20     = "eth.erc20"
+	OrderTradeTypeEthArbitrum  = "eth.arbitrum"
+	OrderTradeTypeEthBase      = "eth.base"
+	OrderTradeTypeBnbBep20     = "bnb.bep20"
+	OrderTradeTypePolPolygon   = "pol.polygon"
+	OrderTradeTypeOkbXlayer    = "okb.xlayer"
+	OrderTradeTypeSolSolana    = "sol.solana"
+	OrderTradeTypeAptAptos     = "apt.aptos"
+	OrderTradeTypeBtcBitcoin   = "btc.bitcoin"
+	OrderTradeTypeLtcLitecoin  = "ltc.litecoin"
+	OrderTradeTypeAny          = "any" // 由用户在收银台选择网络，选择前不分配收款地址及数额
 )
 
 const (
@@ -50,70 +67,178 @@ const (
 
 var calcMutex sync.Mutex
 
+// OrderOpenStatus 仍在等待收款的订单状态
+var OrderOpenStatus = []int{OrderStatusWaiting, OrderStatusPartial}
+
 type TradeOrders struct {
-	Id          int64     `gorm:"primary_key;AUTO_INCREMENT;comment:id"`
-	OrderId     string    `gorm:"column:order_id;type:varchar(128);not null;index;comment:商户ID"`
-	TradeId     string    `gorm:"column:trade_id;type:varchar(128);not null;uniqueIndex;comment:本地ID"`
-	TradeType   string    `gorm:"column:trade_type;type:varchar(20);not null;index;comment:交易类型"`
-	TradeHash   string    `gorm:"column:trade_hash;type:varchar(130);default:'';unique;comment:交易哈希"`
-	TradeRate   string    `gorm:"column:trade_rate;type:varchar(10);not null;comment:交易汇率"`
-	Amount      string    `gorm:"type:decimal(10,2);not null;default:0;comment:交易数额"`
-	Money       float64   `gorm:"type:decimal(10,2);not null;default:0;comment:订单交易金额"`
-	Address     string    `gorm:"column:address;type:varchar(64);not null;comment:收款地址"`
-	FromAddress string    `gorm:"type:varchar(34);not null;default:'';comment:支付地址"`
-	Status      int       `gorm:"type:tinyint(1);not null;default:1;index;comment:交易状态"`
-	Name        string    `gorm:"type:varchar(64);not null;default:'';comment:商品名称"`
-	ApiType     string    `gorm:"type:varchar(20);not null;default:'epusdt';comment:API类型"`
-	ReturnUrl   string    `gorm:"type:varchar(255);not null;default:'';comment:同步地址"`
-	NotifyUrl   string    `gorm:"type:varchar(255);not null;default:'';comment:异步地址"`
-	NotifyNum   int       `gorm:"column:notify_num;type:int(11);not null;default:0;comment:回调次数"`
-	NotifyState int       `gorm:"column:notify_state;type:tinyint(1);not null;default:0;comment:回调状态 1：成功 0：失败"`
-	RefBlockNum int64     `gorm:"type:bigint(20);not null;default:0;comment:交易所在区块"`
-	ExpiredAt   time.Time `gorm:"column:expired_at;type:timestamp;not null;comment:失效时间"`
-	CreatedAt   time.Time `gorm:"autoCreateTime;type:timestamp;not null;comment:创建时间"`
-	UpdatedAt   time.Time `gorm:"autoUpdateTime;type:timestamp;not null;comment:更新时间"`
-	ConfirmedAt time.Time `gorm:"type:timestamp;null;comment:交易确认时间"`
+	Id           int64     `gorm:"primary_key;AUTO_INCREMENT;comment:id"`
+	OrderId      string    `gorm:"column:order_id;type:varchar(128);not null;index;comment:商户ID"`
+	TradeId      string    `gorm:"column:trade_id;type:varchar(128);not null;uniqueIndex;comment:本地ID"`
+	TradeType    string    `gorm:"column:trade_type;type:varchar(20);not null;index;comment:交易类型"`
+	TradeHash    string    `gorm:"column:trade_hash;type:varchar(130);default:'';unique;comment:交易哈希"`
+	TradeRate    string    `gorm:"column:trade_rate;type:varchar(32);not null;comment:交易汇率"`
+	Currency     string    `gorm:"column:currency;type:varchar(8);not null;default:'CNY';comment:订单法币"`
+	RateSnapshot string    `gorm:"column:rate_snapshot;type:varchar(512);not null;default:'';comment:下单汇率快照"`
+	Amount       string    `gorm:"type:decimal(20,8);not null;default:0;comment:交易数额"`
+	PaidAmount   string    `gorm:"column:paid_amount;type:decimal(20,8);not null;default:0;comment:已支付数额"`
+	ActualAmount string    `gorm:"column:actual_amount;type:decimal(20,8);not null;default:0;comment:实际收款数额"`
+	Money        float64   `gorm:"type:decimal(20,8);not null;default:0;comment:订单交易金额，以 Currency 计价"`
+	Address      string    `gorm:"column:address;type:varchar(64);not null;comment:收款地址"`
+	FromAddress  string    `gorm:"type:varchar(66);not null;default:'';comment:支付地址"`
+	Status       int       `gorm:"type:tinyint(1);not null;default:1;index;comment:交易状态"`
+	Name         string    `gorm:"type:varchar(64);not null;default:'';comment:商品名称"`
+	ApiType      string    `gorm:"type:varchar(20);not null;default:'epusdt';comment:API类型"`
+	ReturnUrl    string    `gorm:"type:varchar(255);not null;default:'';comment:同步地址"`
+	NotifyUrl    string    `gorm:"type:varchar(255);not null;default:'';comment:异步地址"`
+	NotifyNum    int       `gorm:"column:notify_num;type:int(11);not null;default:0;comment:回调次数"`
+	NotifyState  int       `gorm:"column:notify_state;type:tinyint(1);not null;default:0;comment:回调状态 1：成功 0：失败"`
+	RefBlockNum  int64     `gorm:"type:bigint(20);not null;default:0;comment:交易所在区块"`
+	ExpiredAt    time.Time `gorm:"column:expired_at;type:timestamp;not null;comment:失效时间"`
+	CreatedAt    time.Time `gorm:"autoCreateTime;type:timestamp;not null;comment:创建时间"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime;type:timestamp;not null;comment:更新时间"`
+	ConfirmedAt  time.Time `gorm:"type:timestamp;null;comment:交易确认时间"`
+	Version      int       `gorm:"column:version;type:int(11);not null;default:0;comment:乐观锁版本"`
+	MerchantId   int64     `gorm:"column:merchant_id;type:bigint(20);not null;default:0;index;comment:所属商户"`
+	Selectable   bool      `gorm:"column:selectable;not null;default:false;comment:是否由用户在收银台选择网络"`
+	OpenAmount   bool      `gorm:"column:open_amount;not null;default:false;comment:是否为不定额订单，按实际收款数额完成"`
+}
+
+// AfterFind 去除交易数额末尾多余的0，保证与链上解析的数额字符串一致
+func (o *TradeOrders) AfterFind(*gorm.DB) error {
+	if v, err := decimal.NewFromString(o.Amount); err == nil {
+		o.Amount = v.String()
+	}
+	if v, err := decimal.NewFromString(o.PaidAmount); err == nil {
+		o.PaidAmount = v.String()
+	}
+	if v, err := decimal.NewFromString(o.ActualAmount); err == nil {
+		o.ActualAmount = v.String()
+	}
+
+	return nil
 }
 
 func (o *TradeOrders) SetCanceled() error {
-	o.Status = OrderStatusCanceled
 
-	return DB.Save(o).Error
+	return o.Transition(OrderStatusCanceled, ActorApi, "商户取消订单", WebhookEffect(WebhookEventOrderCancel))
 }
 
-func (o *TradeOrders) SetExpired() {
-	o.Status = OrderStatusExpired
+func (o *TradeOrders) SetExpired() error {
 
-	DB.Save(o)
+	return o.Transition(OrderStatusExpired, ActorScanner, "订单超时", NotifyEffect(), WebhookEffect(WebhookEventOrderTimeout))
 }
 
-func (o *TradeOrders) SetSuccess() {
-	o.Status = OrderStatusSuccess
+func (o *TradeOrders) SetSuccess() error {
 
-	DB.Save(o)
+	return o.SetPaid(ActorScanner, "交易最终确认")
 }
 
-func (o *TradeOrders) SetFailed() {
-	o.Status = OrderStatusFailed
+// SetPaid 订单标记为收款成功，同时产生 Webhook、商户回调及机器人通知
+func (o *TradeOrders) SetPaid(actor, reason string) error {
 
-	DB.Save(o)
+	return o.Transition(OrderStatusSuccess, actor, reason, paidEffects()...)
 }
 
-func (o *TradeOrders) MarkConfirming(blockNum int64, from, hash string, at time.Time) {
+func (o *TradeOrders) SetFailed() error {
+
+	return o.Transition(OrderStatusFailed, ActorScanner, "交易确认超时", NotifyEffect(), WebhookEffect(WebhookEventOrderFailed))
+}
+
+// MarkConfirming 进入确认状态，实际收款数额取已支付数额
+func (o *TradeOrders) MarkConfirming(blockNum int64, from, hash string, at time.Time) error {
+	// 追赶扫描恢复的过期订单，原有效期已过，重新计算确认时限，避免尚未确认即被判定为确认超时
+	if o.Status == OrderStatusExpired {
+		o.ExpiredAt = CalcTradeExpiredAt(0)
+	}
+
+	o.ActualAmount = o.PaidAmount
 	o.FromAddress = from
 	o.ConfirmedAt = at
 	o.TradeHash = hash
 	o.RefBlockNum = blockNum
-	o.Status = OrderStatusConfirming
 
-	DB.Save(o)
+	return o.Transition(OrderStatusConfirming, ActorScanner, "收到支付交易")
 }
 
+// RollbackWaiting 交易因区块重组从链上消失，订单回滚至等待支付；部分支付订单扣除该笔交易后回滚至部分支付
+func (o *TradeOrders) RollbackWaiting() error {
+	var hash = o.TradeHash
+	var paid = o.sumPayments(hash)
+	var reason = "交易已不在链上：" + hash
+	var effects = []Effect{WebhookEffect(WebhookEventOrderRollback), BotEffect(BotMsgRollback, RollbackArgs{Hash: hash, BlockNum: o.RefBlockNum})}
+	var to = OrderStatusWaiting
+	if paid.IsPositive() {
+		to = OrderStatusPartial
+	}
+
+	o.PaidAmount = paid.String()
+	o.ActualAmount = "0"
+	o.FromAddress = ""
+	o.ConfirmedAt = time.Time{}
+	o.TradeHash = o.TradeId
+	o.RefBlockNum = 0
+	// 原有效期可能已在确认期间过去，重新计算有效期，避免回滚后立即过期无法再次支付
+	o.ExpiredAt = CalcTradeExpiredAt(0)
+
+	// 支付记录与订单回滚在同一事务中删除，状态变更失败时保留该笔支付
+	return o.transitionWith(to, ActorScanner, reason, func(tx *gorm.DB) error {
+
+		return tx.Where("trade_id = ? and tx_hash = ?", o.TradeId, hash).Delete(&TradePayment{}).Error
+	}, effects...)
+}
+
+// SetNotifyState 仅更新回调相关字段，不会覆盖其它流程写入的订单状态
 func (o *TradeOrders) SetNotifyState(state int) error {
+
+	return o.setNotifyState(DB, state)
+}
+
+// SetNotifyFail 标记回调失败，同一事务中写入机器人提醒，由发件箱投递
+func (o *TradeOrders) SetNotifyFail(reason string) error {
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := o.setNotifyState(tx, OrderNotifyStateFail); err != nil {
+
+			return err
+		}
+
+		return o.saveOutbox(tx, []Effect{BotEffect(BotMsgNotifyFail, NotifyFailArgs{Reason: reason})})
+	})
+}
+
+func (o *TradeOrders) setNotifyState(tx *gorm.DB, state int) error {
+	var res = tx.Model(&TradeOrders{}).Where("id = ?", o.Id).Updates(map[string]any{
+		"notify_num":   gorm.Expr("notify_num + 1"),
+		"notify_state": state,
+	})
+	if res.Error != nil {
+
+		return res.Error
+	}
+
 	o.NotifyNum += 1
 	o.NotifyState = state
 
-	return DB.Save(o).Error
+	return nil
+}
+
+// SetRefBlockNum 确认中的交易被重新打包进新的区块，仅在订单仍处于确认状态时更新区块高度
+func (o *TradeOrders) SetRefBlockNum(num int64) error {
+	var res = DB.Model(&TradeOrders{}).Where("id = ? and status = ? and version = ?", o.Id, OrderStatusConfirming, o.Version).
+		Updates(map[string]any{"ref_block_num": num, "version": gorm.Expr("version + 1")})
+	if res.Error != nil {
+
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+
+		return ErrOrderConflict
+	}
+
+	o.RefBlockNum = num
+	o.Version += 1
+
+	return nil
 }
 
 func (o *TradeOrders) GetStatusLabel() string {
@@ -130,6 +255,14 @@ func (o *TradeOrders) GetStatusLabel() string {
 
 		label = "⚪️订单取消"
 	}
+	if o.Status == OrderStatusPartial {
+
+		label = "🟠部分支付"
+	}
+	if o.Status == OrderStatusLatePaid {
+
+		label = "🟣过期后支付"
+	}
 
 	return label
 }
@@ -148,6 +281,14 @@ func (o *TradeOrders) GetStatusEmoji() string {
 
 		label = "⚪️"
 	}
+	if o.Status == OrderStatusPartial {
+
+		label = "🟠"
+	}
+	if o.Status == OrderStatusLatePaid {
+
+		label = "🟣"
+	}
 
 	return label
 }
@@ -158,30 +299,42 @@ func (o *TradeOrders) GetDetailUrl() string {
 }
 
 func GetDetailUrl(tradeType, hash string) string {
-	if help.InStrings(tradeType, []string{OrderTradeTypeUsdtErc20, OrderTradeTypeUsdcErc20}) {
+	if help.InStrings(tradeType, []string{OrderTradeTypeUsdtErc20, OrderTradeTypeUsdcErc20, OrderTradeTypeEthErc20}) {
 		return "https://etherscan.io/tx/" + hash
 	}
-	if help.InStrings(tradeType, []string{OrderTradeTypeUsdtBep20, OrderTradeTypeUsdcBep20}) {
+	if help.InStrings(tradeType, []string{OrderTradeTypeUsdtBep20, OrderTradeTypeUsdcBep20, OrderTradeTypeBnbBep20}) {
 		return "https://bscscan.com/tx/" + hash
 	}
-	if help.InStrings(tradeType, []string{OrderTradeTypeUsdtXlayer, OrderTradeTypeUsdcXlayer}) {
+	if help.InStrings(tradeType, []string{OrderTradeTypeUsdtXlayer, OrderTradeTypeUsdcXlayer, OrderTradeTypeOkbXlayer}) {
 		return "https://web3.okx.com/zh-hans/explorer/x-layer/tx/" + hash
 	}
-	if help.InStrings(tradeType, []string{OrderTradeTypeUsdtPolygon, OrderTradeTypeUsdcPolygon}) {
+	if help.InStrings(tradeType, []string{OrderTradeTypeUsdtPolygon, OrderTradeTypeUsdcPolygon, OrderTradeTypePolPolygon}) {
 		return "https://polygonscan.com/tx/" + hash
 	}
-	if help.InStrings(tradeType, []string{OrderTradeTypeUsdtArbitrum, OrderTradeTypeUsdcArbitrum}) {
+	if help.InStrings(tradeType, []string{OrderTradeTypeUsdtArbitrum, OrderTradeTypeUsdcArbitrum, OrderTradeTypeEthArbitrum}) {
 		return "https://arbiscan.io/tx/" + hash
 	}
-	if help.InStrings(tradeType, []string{OrderTradeTypeUsdcBase}) {
+	if help.InStrings(tradeType, []string{OrderTradeTypeUsdcBase, OrderTradeTypeEthBase}) {
 		return "https://basescan.org/tx/" + hash
 	}
-	if help.InStrings(tradeType, []string{OrderTradeTypeUsdtSolana, OrderTradeTypeUsdcSolana}) {
+	if help.InStrings(tradeType, []string{OrderTradeTypeUsdtSolana, OrderTradeTypeUsdcSolana, OrderTradeTypeSolSolana}) {
 		return "https://solscan.io/tx/" + hash
 	}
-	if help.InStrings(tradeType, []string{OrderTradeTypeUsdtAptos, OrderTradeTypeUsdcAptos}) {
+	if help.InStrings(tradeType, []string{OrderTradeTypeUsdtAptos, OrderTradeTypeUsdcAptos, OrderTradeTypeAptAptos}) {
 		return fmt.Sprintf("https://explorer.aptoslabs.com/txn/%s?network=mainnet", hash)
 	}
+	if tradeType == OrderTradeTypeUsdtTon {
+		return "https://tonviewer.com/transaction/" + hash
+	}
+	if tradeType == OrderTradeTypeBtcBitcoin {
+		return "https://mempool.space/tx/" + hash
+	}
+	if tradeType == OrderTradeTypeLtcLitecoin {
+		return "https://litecoinspace.org/tx/" + hash
+	}
+	if c, _, ok := conf.GetChainToken(tradeType); ok {
+		return strings.ReplaceAll(c.Explorer, "{hash}", hash)
+	}
 
 	return "https://tronscan.org/#/transaction/" + hash
 }
@@ -201,20 +354,11 @@ func GetOrderByStatus(Status int) []TradeOrders {
 	return orders
 }
 
-func GetNotifyFailedTradeOrders() ([]TradeOrders, error) {
-	var orders []TradeOrders
-	var res = DB.Where("status = ?", OrderStatusSuccess).
-		Where("notify_num <= ?", conf.NotifyMaxRetry).
-		Where("notify_state = ?", OrderNotifyStateFail).Find(&orders)
-
-	return orders, res.Error
-}
-
 func existsWaitPayOrderByMoney(tradeType string, walletAddr string, payAmount string) (bool, error) {
 	var count int64
 	err := DB.Model(&TradeOrders{}).Where(
-		"status = ? and trade_type = ? and address = ? and amount = ?",
-		OrderStatusWaiting, tradeType, walletAddr, payAmount,
+		"status in (?) and trade_type = ? and address = ? and amount = ?",
+		OrderOpenStatus, tradeType, walletAddr, payAmount,
 	).Count(&count).Error
 	return count > 0, err
 }
@@ -234,15 +378,16 @@ func CalcTradeAmount(wa []WalletAddress, rate, money float64, tradeType string)
 		isExists = func(walletAddr string, payAmount string) (bool, error) {
 			count = 0
 			err := DB.Model(&order).Where(
-				"status = ? and trade_type = ? and address = ? and amount = ?",
-				OrderStatusWaiting, tradeType, walletAddr, payAmount,
+				"status in (?) and trade_type = ? and address = ? and amount = ?",
+				OrderOpenStatus, tradeType, walletAddr, payAmount,
 			).Count(&count).Error
 			return count > 0, err
 		}
 	} else {
 		var orders []TradeOrders
 		var lock = make(map[string]bool)
-		err = DB.Where("status = ? and trade_type = ?", OrderStatusWaiting, tradeType).Find(&orders).Error
+		// 部分支付的订单仍占用地址及数额，避免新订单抢占剩余支付
+		err = DB.Where("status in (?) and trade_type = ?", OrderOpenStatus, tradeType).Find(&orders).Error
 		if err != nil {
 			return WalletAddress{}, ``, err
 		}
@@ -254,9 +399,9 @@ func CalcTradeAmount(wa []WalletAddress, rate, money float64, tradeType string)
 		}
 	}
 
-	atom, prec := getTokenAtomicityByTradeType(tradeType)
+	atom, _ := getTokenAtomicityByTradeType(tradeType)
 	var payAmount decimal.Decimal
-	payAmount, err = decimal.NewFromString(strconv.FormatFloat(money/rate, 'f', prec, 64))
+	payAmount, err = calcPayAmount(rate, money, tradeType)
 	if err != nil {
 		return WalletAddress{}, ``, err
 	}
@@ -279,6 +424,13 @@ func CalcTradeAmount(wa []WalletAddress, rate, money float64, tradeType string)
 	}
 }
 
+// calcPayAmount 按汇率换算支付数额，保留交易类型原子精度对应的小数位数
+func calcPayAmount(rate, money float64, tradeType string) (decimal.Decimal, error) {
+	_, prec := getTokenAtomicityByTradeType(tradeType)
+
+	return decimal.NewFromString(strconv.FormatFloat(money/rate, 'f', prec, 64))
+}
+
 func CalcTradeExpiredAt(sec uint64) time.Time {
 	timeout := conf.GetExpireTime() * time.Second
 	if sec >= 60 {
@@ -288,15 +440,22 @@ func CalcTradeExpiredAt(sec uint64) time.Time {
 	return time.Now().Add(timeout)
 }
 
-func GetTradeRate(token TokenType, param string) (float64, error) {
+// GetTradeRate 交易汇率，即 1 个代币折合多少订单法币；CNY 以外的法币按 usdt 交叉汇率折算
+func GetTradeRate(token TokenType, currency, param string) (float64, error) {
+	if currency != rate.FiatCNY {
+
+		return getFiatTradeRate(token, currency, param)
+	}
+
 	if param != "" {
-		switch token {
-		case TokenTypeUSDT:
-			return rate.ParseFloatRate(param, rate.GetOkxUsdtRawRate()), nil
-		case TokenTypeUSDC:
-			return rate.ParseFloatRate(param, rate.GetOkxUsdcRawRate()), nil
-		case TokenTypeTRX:
-			return rate.ParseFloatRate(param, rate.GetOkxTrxRawRate()), nil
+		// 固定数值的汇率(含指定汇率及报价锁定汇率)不依赖实时汇率
+		if help.IsNumber(param) {
+
+			return cast.ToFloat64(param), nil
+		}
+		if raw := getRawRate(token); raw > 0 {
+
+			return rate.ParseFloatRate(param, raw), nil
 		}
 
 		return 0, fmt.Errorf("(%s)交易汇率计算获取失败：%s", token, param)
@@ -309,11 +468,68 @@ func GetTradeRate(token TokenType, param string) (float64, error) {
 		return rate.GetUsdcCalcRate(), nil
 	case TokenTypeTRX:
 		return rate.GetTrxCalcRate(), nil
+	case TokenTypeETH, TokenTypeBNB, TokenTypePOL, TokenTypeOKB, TokenTypeSOL, TokenTypeAPT, TokenTypeBTC, TokenTypeLTC:
+		if v := rate.GetCoinCalcRate(string(token)); v > 0 {
+
+			return v, nil
+		}
 	}
 
 	return 0, fmt.Errorf("(%s)交易汇率获取失败", token)
 }
 
+// getFiatTradeRate 指定汇率时固定数值及加减数值均以订单法币计，未指定时由 CNY 计算汇率折算
+func getFiatTradeRate(token TokenType, currency, param string) (float64, error) {
+	if help.IsNumber(param) {
+
+		return cast.ToFloat64(param), nil
+	}
+
+	var factor = rate.GetFiatFactor(currency)
+	if factor <= 0 {
+
+		return 0, fmt.Errorf("(%s)法币汇率获取失败", currency)
+	}
+
+	if param != "" {
+		// 固定数值的汇率(含指定汇率及报价锁定汇率)不依赖实时汇率
+		if help.IsNumber(param) {
+
+			return cast.ToFloat64(param), nil
+		}
+		if raw := getRawRate(token); raw > 0 {
+
+			return rate.ParseFiatRate(param, raw*factor), nil
+		}
+
+		return 0, fmt.Errorf("(%s)交易汇率计算获取失败：%s", token, param)
+	}
+
+	calc, err := GetTradeRate(token, rate.FiatCNY, "")
+	if err != nil {
+
+		return 0, err
+	}
+
+	return rate.RoundFiatRate(calc * factor), nil
+}
+
+// getRawRate 代币 CNY 计价的原始汇率，尚未获取到汇率时返回 0
+func getRawRate(token TokenType) float64 {
+	switch token {
+	case TokenTypeUSDT:
+		return rate.GetUsdtRawRate()
+	case TokenTypeUSDC:
+		return rate.GetUsdcRawRate()
+	case TokenTypeTRX:
+		return rate.GetTrxRawRate()
+	case TokenTypeETH, TokenTypeBNB, TokenTypePOL, TokenTypeOKB, TokenTypeSOL, TokenTypeAPT, TokenTypeBTC, TokenTypeLTC:
+		return rate.GetCoinRawRate(string(token))
+	}
+
+	return 0
+}
+
 func getTokenAtomicityByTradeType(tradeType string) (decimal.Decimal, int) {
 	switch tradeType {
 	case OrderTradeTypeTronTrx:
@@ -334,6 +550,8 @@ func getTokenAtomicityByTradeType(tradeType string) (decimal.Decimal, int) {
 		return conf.GetUsdtAtomicity()
 	case OrderTradeTypeUsdtArbitrum:
 		return conf.GetUsdtAtomicity()
+	case OrderTradeTypeUsdtTon:
+		return conf.GetUsdtAtomicity()
 	case OrderTradeTypeUsdcTrc20:
 		return conf.GetUsdcAtomicity()
 	case OrderTradeTypeUsdcErc20:
@@ -352,7 +570,28 @@ func getTokenAtomicityByTradeType(tradeType string) (decimal.Decimal, int) {
 		return conf.GetUsdcAtomicity()
 	case OrderTradeTypeUsdcBase:
 		return conf.GetUsdcAtomicity()
+	case OrderTradeTypeEthErc20, OrderTradeTypeEthArbitrum, OrderTradeTypeEthBase:
+		return conf.GetCoinAtomicity(string(TokenTypeETH))
+	case OrderTradeTypeBnbBep20:
+		return conf.GetCoinAtomicity(string(TokenTypeBNB))
+	case OrderTradeTypePolPolygon:
+		return conf.GetCoinAtomicity(string(TokenTypePOL))
+	case OrderTradeTypeOkbXlayer:
+		return conf.GetCoinAtomicity(string(TokenTypeOKB))
+	case OrderTradeTypeSolSolana:
+		return conf.GetCoinAtomicity(string(TokenTypeSOL))
+	case OrderTradeTypeAptAptos:
+		return conf.GetCoinAtomicity(string(TokenTypeAPT))
+	case OrderTradeTypeBtcBitcoin:
+		return conf.GetCoinAtomicity(string(TokenTypeBTC))
+	case OrderTradeTypeLtcLitecoin:
+		return conf.GetCoinAtomicity(string(TokenTypeLTC))
 	default:
+		if _, t, ok := conf.GetChainToken(tradeType); ok && t.Symbol == string(TokenTypeUSDC) {
+
+			return conf.GetUsdcAtomicity()
+		}
+
 		return conf.GetUsdtAtomicity()
 	}
 }
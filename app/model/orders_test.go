@@ -0,0 +1,120 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalcTradeAmountSkipsOpenSlots(t *testing.T) {
+	var cases = []struct {
+		name   string
+		status int
+		want   string
+	}{
+		{"waiting order holds slot", OrderStatusWaiting, "10.01"},
+		{"partially paid order holds slot", OrderStatusPartial, "10.01"},
+		{"expired order releases slot", OrderStatusExpired, "10"},
+		{"confirming order releases slot", OrderStatusConfirming, "10"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setupTestDB(t)
+			createTestOrder(t, TradeOrders{TradeId: "t1", Amount: "10", Address: "TAddr", Status: c.status})
+
+			var wa = []WalletAddress{{TradeType: OrderTradeTypeUsdtTrc20, Address: "TAddr"}}
+			_, amount, err := CalcTradeAmount(wa, 1, 10, OrderTradeTypeUsdtTrc20)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if amount != c.want {
+				t.Fatalf("amount = %s, want %s", amount, c.want)
+			}
+		})
+	}
+}
+
+func TestRollbackWaiting(t *testing.T) {
+	var cases = []struct {
+		name     string
+		payments []TradePayment
+		status   int
+		paid     string
+	}{
+		{"single payment", []TradePayment{{TxHash: "h1", Amount: "10"}}, OrderStatusWaiting, "0"},
+		{"earlier partial payment kept", []TradePayment{{TxHash: "h0", Amount: "4"}, {TxHash: "h1", Amount: "6"}}, OrderStatusPartial, "4"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setupTestDB(t)
+
+			var o = createTestOrder(t, TradeOrders{TradeId: "t1", Amount: "10", Address: "TAddr", Status: OrderStatusConfirming,
+				TradeHash: "h1", ExpiredAt: time.Now().Add(-time.Minute)})
+			for _, p := range c.payments {
+				p.TradeId, p.PaidAt = "t1", time.Now()
+				DB.Create(&p)
+			}
+
+			if err := o.RollbackWaiting(); err != nil {
+				t.Fatal(err)
+			}
+
+			saved, _ := GetTradeOrder("t1")
+			if saved.Status != c.status || saved.PaidAmount != c.paid || saved.TradeHash != "t1" {
+				t.Fatalf("unexpected order status %s paid %s hash %s", OrderStatusName(saved.Status), saved.PaidAmount, saved.TradeHash)
+			}
+			if !saved.ExpiredAt.After(time.Now()) {
+				t.Fatalf("expired_at not renewed: %v", saved.ExpiredAt)
+			}
+
+			var rows []TradePayment
+			DB.Where("trade_id = ?", "t1").Find(&rows)
+			if len(rows) != len(c.payments)-1 {
+				t.Fatalf("unexpected payments %+v", rows)
+			}
+		})
+	}
+}
+
+// 订单已被其它流程更新时回滚失败，支付记录保持不变
+func TestRollbackWaitingConflict(t *testing.T) {
+	setupTestDB(t)
+
+	var o = createTestOrder(t, TradeOrders{TradeId: "t1", Amount: "10", Address: "TAddr", Status: OrderStatusConfirming, TradeHash: "h1"})
+	DB.Create(&TradePayment{TradeId: "t1", TxHash: "h1", Amount: "10", PaidAt: time.Now()})
+	DB.Model(&TradeOrders{}).Where("id = ?", o.Id).Update("version", o.Version+1)
+
+	if err := o.RollbackWaiting(); err != ErrOrderConflict {
+		t.Fatalf("err = %v, want ErrOrderConflict", err)
+	}
+
+	var count int64
+	DB.Model(&TradePayment{}).Where("trade_id = ?", "t1").Count(&count)
+	if count != 1 {
+		t.Fatalf("payment removed on failed rollback, %d rows left", count)
+	}
+}
+
+// 回调状态与订单状态互不覆盖，记录回调结果不会使扫描流程持有的订单副本失效
+func TestSetNotifyStateKeepsVersion(t *testing.T) {
+	setupTestDB(t)
+
+	var o = createTestOrder(t, TradeOrders{TradeId: "t1", Amount: "10", Status: OrderStatusConfirming})
+	var scanner = o
+
+	if err := o.SetNotifyState(OrderNotifyStateFail); err != nil {
+		t.Fatal(err)
+	}
+	if err := scanner.Transition(OrderStatusSuccess, ActorScanner, "confirmed"); err != nil {
+		t.Fatalf("transition after notify state update: %v", err)
+	}
+
+	saved, _ := GetTradeOrder("t1")
+	if saved.Status != OrderStatusSuccess || saved.Version != scanner.Version {
+		t.Fatalf("unexpected saved status %s version %d", OrderStatusName(saved.Status), saved.Version)
+	}
+	if saved.NotifyState != OrderNotifyStateFail || saved.NotifyNum != 1 {
+		t.Fatalf("notify state overwritten: state %d num %d", saved.NotifyState, saved.NotifyNum)
+	}
+}
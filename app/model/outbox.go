@@ -0,0 +1,204 @@
+package model
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/v03413/bepusdt/app/conf"
+	"gorm.io/gorm"
+)
+
+const (
+	OutboxKindWebhook = "webhook" // Webhook 事件
+	OutboxKindNotify  = "notify"  // 商户回调
+	OutboxKindBot     = "bot"     // 机器人消息
+
+	OutboxStatusWait = 0
+	OutboxStatusSucc = 1
+	OutboxStatusFail = -1
+
+	BotMsgTradeSucc  = "trade_succ"  // 收款成功
+	BotMsgLatePaid   = "late_paid"   // 过期后收到支付
+	BotMsgRollback   = "rollback"    // 区块重组订单回滚
+	BotMsgNotifyFail = "notify_fail" // 商户回调失败
+)
+
+// Outbox 订单状态变更产生的通知，与状态变更在同一事务中写入，由任务投递并失败重试
+type Outbox struct {
+	ID        int64           `gorm:"primary_key;AUTO_INCREMENT;comment:id"`
+	TradeId   string          `gorm:"column:trade_id;type:varchar(128);not null;index;comment:本地ID"`
+	Kind      string          `gorm:"column:kind;type:varchar(20);not null;comment:通知类型"`
+	Event     string          `gorm:"column:event;type:varchar(64);not null;default:'';comment:Webhook事件或机器人消息类型"`
+	Snapshot  json.RawMessage `gorm:"column:snapshot;type:json;not null;comment:状态变更时的订单快照"`
+	Args      json.RawMessage `gorm:"column:args;type:json;null;comment:附加参数"`
+	Status    int8            `gorm:"column:status;type:tinyint;not null;default:0;index;comment:投递状态"`
+	Num       int             `gorm:"column:num;type:int(11);not null;default:0;comment:投递次数"`
+	NextAt    time.Time       `gorm:"column:next_at;type:timestamp;not null;index;comment:下次投递时间"`
+	Error     string          `gorm:"column:error;type:varchar(255);not null;default:'';comment:最近一次失败原因"`
+	CreatedAt time.Time       `gorm:"autoCreateTime;type:timestamp;not null;comment:创建时间"`
+	UpdatedAt time.Time       `gorm:"autoUpdateTime;type:timestamp;not null;comment:更新时间"`
+}
+
+// Effect 订单状态变更需要产生的通知
+type Effect struct {
+	Kind  string
+	Event string
+	Args  any
+}
+
+// RollbackArgs 区块重组回滚消息的附加参数
+type RollbackArgs struct {
+	Hash     string `json:"hash"`
+	BlockNum int64  `json:"block_num"`
+}
+
+// NotifyFailArgs 商户回调失败消息的附加参数
+type NotifyFailArgs struct {
+	Reason string `json:"reason"`
+}
+
+func (Outbox) TableName() string {
+
+	return "outbox"
+}
+
+func WebhookEffect(event string) Effect {
+
+	return Effect{Kind: OutboxKindWebhook, Event: event}
+}
+
+func NotifyEffect() Effect {
+
+	return Effect{Kind: OutboxKindNotify}
+}
+
+func BotEffect(msg string, args any) Effect {
+
+	return Effect{Kind: OutboxKindBot, Event: msg, Args: args}
+}
+
+// paidEffects 订单收款成功需要产生的通知
+func paidEffects() []Effect {
+
+	return []Effect{WebhookEffect(WebhookEventOrderPaid), NotifyEffect(), BotEffect(BotMsgTradeSucc, nil)}
+}
+
+// GetOrder 状态变更时的订单快照
+func (b Outbox) GetOrder() (TradeOrders, error) {
+	var o TradeOrders
+	if err := json.Unmarshal(b.Snapshot, &o); err != nil {
+
+		return o, err
+	}
+
+	return o, o.AfterFind(nil)
+}
+
+// SetSucc 标记投递成功
+func (b Outbox) SetSucc() {
+	DB.Model(&Outbox{}).Where("id = ?", b.ID).Updates(map[string]any{"status": OutboxStatusSucc, "num": b.Num + 1, "error": ""})
+}
+
+// SetFail 投递失败，按次数指数退避，超过最大重试次数后不再投递
+func (b Outbox) SetFail(reason string) {
+	var num = b.Num + 1
+	var status = int8(OutboxStatusWait)
+	if num > conf.NotifyMaxRetry {
+		status = OutboxStatusFail
+	}
+	if len(reason) > 255 {
+		reason = reason[:255]
+	}
+
+	DB.Model(&Outbox{}).Where("id = ?", b.ID).Updates(map[string]any{
+		"status":  status,
+		"num":     num,
+		"error":   reason,
+		"next_at": time.Now().Add(b.retryDelay(num)),
+	})
+}
+
+// NotifyRetryDelay 商户回调及 Webhook 第 num 次失败后的等待时间，按 2 4 8 16... 分钟间隔重试
+func NotifyRetryDelay(num int) time.Duration {
+
+	return time.Minute * time.Duration(math.Pow(2, float64(num)))
+}
+
+// retryDelay 第 num 次投递失败后距下次投递的等待时间
+func (b Outbox) retryDelay(num int) time.Duration {
+	if b.Kind == OutboxKindNotify || b.Kind == OutboxKindWebhook {
+
+		return NotifyRetryDelay(num)
+	}
+
+	return time.Second * 10 * time.Duration(math.Pow(2, float64(num-1)))
+}
+
+// RetryNotify 立即重新投递尚未成功的商户回调，没有回调记录的历史订单补充写入
+func (o *TradeOrders) RetryNotify() error {
+	var res = DB.Model(&Outbox{}).Where("trade_id = ? and kind = ? and status <> ?", o.TradeId, OutboxKindNotify, OutboxStatusSucc).
+		Updates(map[string]any{"status": OutboxStatusWait, "next_at": time.Now()})
+	if res.Error != nil {
+
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+
+		return nil
+	}
+
+	return o.saveOutbox(DB, []Effect{NotifyEffect()})
+}
+
+// Destination 通知的投递目标，同一目标的通知需按写入顺序依次投递
+func (b Outbox) Destination(o TradeOrders) string {
+	switch b.Kind {
+	case OutboxKindNotify:
+
+		return b.Kind + "|" + o.NotifyUrl
+	case OutboxKindWebhook:
+
+		return b.Kind + "|" + GetMerchant(o.MerchantId).WebhookUrl
+	}
+
+	return b.Kind
+}
+
+// GetDueOutboxes 到达投递时间的通知，按写入顺序返回
+func GetDueOutboxes(limit int) []Outbox {
+	var rows = make([]Outbox, 0)
+
+	DB.Where("status = ? and next_at <= ?", OutboxStatusWait, time.Now()).Order("id asc").Limit(limit).Find(&rows)
+
+	return rows
+}
+
+// saveOutbox 在订单状态变更的事务中写入通知
+func (o *TradeOrders) saveOutbox(tx *gorm.DB, effects []Effect) error {
+	if len(effects) == 0 {
+
+		return nil
+	}
+
+	snapshot, err := json.Marshal(o)
+	if err != nil {
+
+		return err
+	}
+
+	var rows = make([]Outbox, 0, len(effects))
+	for _, e := range effects {
+		var args json.RawMessage
+		if e.Args != nil {
+			if args, err = json.Marshal(e.Args); err != nil {
+
+				return err
+			}
+		}
+
+		rows = append(rows, Outbox{TradeId: o.TradeId, Kind: e.Kind, Event: e.Event, Snapshot: snapshot, Args: args, NextAt: time.Now()})
+	}
+
+	return tx.Create(&rows).Error
+}
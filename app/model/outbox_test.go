@@ -0,0 +1,38 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryNotify(t *testing.T) {
+	var cases = []struct {
+		name   string
+		outbox bool
+		status int8
+	}{
+		{"failed outbox delivered immediately", true, OutboxStatusFail},
+		{"order without outbox", false, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setupTestDB(t)
+
+			var o = TradeOrders{TradeId: "t1", TradeHash: "hash1", Amount: "10", Status: OrderStatusSuccess, ExpiredAt: time.Now()}
+			DB.Create(&o)
+			if c.outbox {
+				DB.Create(&Outbox{TradeId: "t1", Kind: OutboxKindNotify, Snapshot: []byte("{}"), Status: c.status, NextAt: time.Now().Add(time.Hour)})
+			}
+
+			if err := o.RetryNotify(); err != nil {
+				t.Fatal(err)
+			}
+
+			var rows = GetDueOutboxes(10)
+			if len(rows) != 1 || rows[0].Kind != OutboxKindNotify {
+				t.Fatalf("unexpected due outboxes %+v", rows)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/v03413/bepusdt/app/conf"
+)
+
+// TradePayment 部分支付模式下订单收到的每一笔转账，交易哈希唯一，重复扫描的交易不会重复累计
+type TradePayment struct {
+	ID          int64     `gorm:"primary_key;AUTO_INCREMENT;comment:id"`
+	TradeId     string    `gorm:"column:trade_id;type:varchar(128);not null;index;comment:本地ID"`
+	TxHash      string    `gorm:"column:tx_hash;type:varchar(130);not null;uniqueIndex;comment:交易哈希"`
+	Amount      string    `gorm:"column:amount;type:decimal(20,8);not null;default:0;comment:交易数额"`
+	FromAddress string    `gorm:"column:from_address;type:varchar(66);not null;default:'';comment:支付地址"`
+	BlockNum    int64     `gorm:"column:block_num;type:bigint(20);not null;default:0;comment:交易所在区块"`
+	PaidAt      time.Time `gorm:"column:paid_at;type:timestamp;not null;comment:交易时间"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;type:timestamp;not null;comment:创建时间"`
+}
+
+func (p *TradePayment) TableName() string {
+
+	return "trade_payment"
+}
+
+// PartialOrders 可累计支付的订单 [小写地址+交易类型] => 订单，每批交易只查询一次
+type PartialOrders map[string][]TradeOrders
+
+// GetPartialOrders 仍在等待收款的订单，按创建先后排序
+func GetPartialOrders() PartialOrders {
+	var orders []TradeOrders
+	var data = make(PartialOrders)
+
+	DB.Where("status in (?)", OrderOpenStatus).Order("id asc").Find(&orders)
+	for _, o := range orders {
+		var key = strings.ToLower(o.Address) + o.TradeType
+		data[key] = append(data[key], o)
+	}
+
+	return data
+}
+
+// Match 按收款地址查找可累计支付的订单，优先已部分支付的订单；地址被多个等待支付订单共用时无法区分归属
+func (p PartialOrders) Match(address, tradeType string) (TradeOrders, bool) {
+	var match = make([]TradeOrders, 0)
+	for _, o := range p[strings.ToLower(address)+tradeType] {
+		if o.Status == OrderStatusPartial {
+
+			return o, true
+		}
+
+		match = append(match, o)
+	}
+
+	if len(match) == 1 {
+
+		return match[0], true
+	}
+
+	return TradeOrders{}, false
+}
+
+// Update 订单变更后同步到缓存，同一批次的后续交易基于最新版本累计，不再等待收款的订单移除
+func (p PartialOrders) Update(o TradeOrders) {
+	var key = strings.ToLower(o.Address) + o.TradeType
+	var rows = make([]TradeOrders, 0, len(p[key]))
+	for _, v := range p[key] {
+		if v.TradeId != o.TradeId {
+			rows = append(rows, v)
+
+			continue
+		}
+
+		if o.Status == OrderStatusWaiting || o.Status == OrderStatusPartial {
+			rows = append(rows, o)
+		}
+	}
+
+	p[key] = rows
+}
+
+// AddPayment 记录一笔支付并累计已支付数额，交易已记录过则返回 false
+func (o *TradeOrders) AddPayment(amount decimal.Decimal, from, hash string, blockNum int64, at time.Time) bool {
+	var p = TradePayment{
+		TradeId:     o.TradeId,
+		TxHash:      hash,
+		Amount:      amount.String(),
+		FromAddress: from,
+		BlockNum:    blockNum,
+		PaidAt:      at,
+	}
+	if DB.Create(&p).Error != nil {
+
+		return false
+	}
+
+	o.PaidAmount = o.sumPayments("").String()
+
+	return true
+}
+
+// SetPartial 已支付数额不足，订单进入部分支付状态
+func (o *TradeOrders) SetPartial() error {
+
+	return o.Transition(OrderStatusPartial, ActorScanner, "收到部分支付，已支付 "+o.PaidAmount, WebhookEffect(WebhookEventOrderPartial))
+}
+
+// IsPaidEnough 支付数额扣除允许差额后是否不低于订单数额
+func (o *TradeOrders) IsPaidEnough(amount decimal.Decimal) bool {
+	want, err := decimal.NewFromString(o.Amount)
+	if err != nil {
+
+		return false
+	}
+
+	return !amount.LessThan(want.Mul(decimal.NewFromInt(1).Sub(conf.GetPartialTolerance())))
+}
+
+func (o *TradeOrders) sumPayments(excludeHash string) decimal.Decimal {
+	var rows []TradePayment
+	var sum = decimal.Zero
+
+	DB.Where("trade_id = ? and tx_hash <> ?", o.TradeId, excludeHash).Find(&rows)
+	for _, row := range rows {
+		if v, err := decimal.NewFromString(row.Amount); err == nil {
+			sum = sum.Add(v)
+		}
+	}
+
+	return sum
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestPartialOrdersMatch(t *testing.T) {
+	var cases = []struct {
+		name   string
+		orders []TradeOrders
+		want   string
+	}{
+		{"single waiting order", []TradeOrders{{TradeId: "t1", Status: OrderStatusWaiting}}, "t1"},
+		{"partial order preferred", []TradeOrders{{TradeId: "t1", Status: OrderStatusWaiting}, {TradeId: "t2", Status: OrderStatusPartial}}, "t2"},
+		{"ambiguous waiting orders", []TradeOrders{{TradeId: "t1", Status: OrderStatusWaiting}, {TradeId: "t2", Status: OrderStatusWaiting, Amount: "11"}}, ""},
+		{"closed order ignored", []TradeOrders{{TradeId: "t1", Status: OrderStatusSuccess}}, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setupTestDB(t)
+			for _, o := range c.orders {
+				o.Address = "0xAbC"
+				if o.Amount == "" {
+					o.Amount = "10"
+				}
+				createTestOrder(t, o)
+			}
+
+			o, ok := GetPartialOrders().Match("0xabc", OrderTradeTypeUsdtTrc20)
+			if ok != (c.want != "") || o.TradeId != c.want {
+				t.Fatalf("matched %q(%v), want %q", o.TradeId, ok, c.want)
+			}
+		})
+	}
+}
+
+func TestPartialOrdersUpdate(t *testing.T) {
+	setupTestDB(t)
+	createTestOrder(t, TradeOrders{TradeId: "t1", Amount: "10", Address: "TAddr", Status: OrderStatusWaiting})
+
+	var partial = GetPartialOrders()
+	o, ok := partial.Match("TAddr", OrderTradeTypeUsdtTrc20)
+	if !ok {
+		t.Fatal("order not matched")
+	}
+
+	o.PaidAmount = "4"
+	if err := o.SetPartial(); err != nil {
+		t.Fatal(err)
+	}
+	partial.Update(o)
+
+	// 同一批次的下一笔交易取到最新版本，状态变更不会因版本冲突失败
+	o, _ = partial.Match("TAddr", OrderTradeTypeUsdtTrc20)
+	if err := o.MarkConfirming(1, "TFrom", "hash", o.CreatedAt.Add(1)); err != nil {
+		t.Fatal(err)
+	}
+	partial.Update(o)
+
+	if _, ok = partial.Match("TAddr", OrderTradeTypeUsdtTrc20); ok {
+		t.Fatal("confirming order must be removed from batch cache")
+	}
+}
@@ -0,0 +1,158 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/v03413/bepusdt/app/conf"
+	"github.com/v03413/bepusdt/app/help"
+	"gorm.io/gorm"
+)
+
+// Quote 报价，记录报价时各交易类型的汇率；有效期内凭报价ID下单按锁定的汇率计算数额
+type Quote struct {
+	ID         int64     `gorm:"primary_key;AUTO_INCREMENT;comment:id"`
+	QuoteId    string    `gorm:"column:quote_id;type:varchar(32);not null;uniqueIndex;comment:报价ID"`
+	MerchantId int64     `gorm:"column:merchant_id;type:bigint(20);not null;default:0;comment:所属商户"`
+	Money      float64   `gorm:"column:money;type:decimal(20,8);not null;default:0;comment:报价金额"`
+	Currency   string    `gorm:"column:currency;type:varchar(8);not null;default:'CNY';comment:报价法币"`
+	Rates      string    `gorm:"column:rates;type:text;not null;comment:锁定汇率 交易类型=>汇率"`
+	OrderId    string    `gorm:"column:order_id;type:varchar(128);not null;default:'';comment:使用报价的商户订单ID"`
+	ExpiredAt  time.Time `gorm:"column:expired_at;type:timestamp;not null;index;comment:失效时间"`
+	CreatedAt  time.Time `gorm:"autoCreateTime;type:timestamp;not null;comment:创建时间"`
+}
+
+func (q *Quote) TableName() string {
+
+	return "quote"
+}
+
+// QuoteItem 单个交易类型的报价
+type QuoteItem struct {
+	TradeType   string  `json:"trade_type"`
+	Token       string  `json:"token"`
+	Rate        float64 `json:"rate"`
+	TokenAmount string  `json:"token_amount"` // 基础支付数额，同金额订单较多时实际数额可能按原子精度递增
+}
+
+// CreateQuote 按当前汇率为各交易类型报价，汇率不可用的交易类型跳过
+func CreateQuote(merchantId int64, money float64, currency string, tradeTypes []string) (Quote, []QuoteItem, error) {
+	var items = make([]QuoteItem, 0, len(tradeTypes))
+	var rates = make(map[string]float64)
+	for _, tradeType := range tradeTypes {
+		token, err := GetTokenType(tradeType)
+		if err != nil {
+
+			continue
+		}
+
+		if _, err = GetRateSnapshot(token, currency, ""); err != nil {
+
+			continue
+		}
+
+		r, err := GetTradeRate(token, currency, "")
+		if err != nil || r <= 0 {
+
+			continue
+		}
+
+		amount, err := calcPayAmount(r, money, tradeType)
+		if err != nil || !amount.IsPositive() {
+
+			continue
+		}
+
+		rates[tradeType] = r
+		items = append(items, QuoteItem{TradeType: tradeType, Token: string(token), Rate: r, TokenAmount: amount.String()})
+	}
+
+	if len(items) == 0 {
+
+		return Quote{}, items, errors.New("暂无可报价的交易类型")
+	}
+
+	quoteId, err := help.GenerateTradeId()
+	if err != nil {
+
+		return Quote{}, items, err
+	}
+
+	data, _ := json.Marshal(rates)
+	var q = Quote{
+		QuoteId:    quoteId,
+		MerchantId: merchantId,
+		Money:      money,
+		Currency:   currency,
+		Rates:      string(data),
+		ExpiredAt:  time.Now().Add(conf.GetQuoteExpireTime()),
+	}
+	if err = DB.Create(&q).Error; err != nil {
+
+		return q, items, err
+	}
+
+	// 顺带清理失效超过一天的报价
+	DB.Where("expired_at < ?", time.Now().Add(-time.Hour*24)).Delete(&Quote{})
+
+	return q, items, nil
+}
+
+// GetQuote 商户有效期内的报价
+func GetQuote(quoteId string, merchantId int64) (Quote, bool) {
+	var q Quote
+	var res = DB.Where("quote_id = ? and merchant_id = ? and expired_at > ?", quoteId, merchantId, time.Now()).Limit(1).Find(&q)
+
+	return q, res.RowsAffected > 0
+}
+
+// IsSameMoney 下单金额与报价金额是否一致，按订单金额精度比较，避免浮点误差
+func (q Quote) IsSameMoney(money float64) bool {
+
+	return decimal.NewFromFloat(q.Money).Round(8).Equal(decimal.NewFromFloat(money).Round(8))
+}
+
+// useQuote 报价绑定到商户订单，同一报价只能用于一个订单，同一订单重复提交不受影响
+func useQuote(tx *gorm.DB, quoteId, orderId string) error {
+	var res = tx.Model(&Quote{}).Where("quote_id = ? and order_id = ''", quoteId).Update("order_id", orderId)
+	if res.Error != nil {
+
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		var cur Quote
+		tx.Where("quote_id = ?", quoteId).Limit(1).Find(&cur)
+		if cur.OrderId != orderId {
+
+			return fmt.Errorf("报价(%s)已被其它订单使用", quoteId)
+		}
+	}
+
+	return nil
+}
+
+// TransitionWithQuote 凭报价下单，报价绑定与订单状态变更在同一事务中完成，订单写入失败时报价不会被占用
+func (o *TradeOrders) TransitionWithQuote(quoteId string, to int, actor, reason string, effects ...Effect) error {
+
+	return o.transitionWith(to, actor, reason, func(tx *gorm.DB) error {
+
+		return useQuote(tx, quoteId, o.OrderId)
+	}, effects...)
+}
+
+// GetRate 报价锁定的交易类型汇率
+func (q Quote) GetRate(tradeType string) (float64, bool) {
+	var rates map[string]float64
+	if err := json.Unmarshal([]byte(q.Rates), &rates); err != nil {
+
+		return 0, false
+	}
+
+	r, ok := rates[tradeType]
+
+	return r, ok && r > 0
+}
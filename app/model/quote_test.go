@@ -0,0 +1,96 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuoteIsSameMoney(t *testing.T) {
+	var cases = []struct {
+		quote float64
+		money float64
+		want  bool
+	}{
+		{100, 100, true},
+		{0.3, 0.1 + 0.2, true},
+		{12.345678, 12.345678, true},
+		{12.345678, 12.35, false},
+		{10.01, 10.02, false},
+	}
+
+	for _, c := range cases {
+		var q = Quote{Money: c.quote}
+		if got := q.IsSameMoney(c.money); got != c.want {
+			t.Errorf("IsSameMoney(%v) with quote %v = %v, want %v", c.money, c.quote, got, c.want)
+		}
+	}
+}
+
+func TestGetQuote(t *testing.T) {
+	setupTestDB(t)
+	DB.Create(&Quote{QuoteId: "q1", MerchantId: 1, Money: 12.345678, Rates: "{}", ExpiredAt: time.Now().Add(time.Minute)})
+	DB.Create(&Quote{QuoteId: "q2", MerchantId: 1, Money: 10, Rates: "{}", ExpiredAt: time.Now().Add(-time.Minute)})
+
+	var cases = []struct {
+		name       string
+		quoteId    string
+		merchantId int64
+		ok         bool
+	}{
+		{"valid quote", "q1", 1, true},
+		{"other merchant", "q1", 2, false},
+		{"expired quote", "q2", 1, false},
+		{"missing quote", "q3", 1, false},
+	}
+
+	for _, c := range cases {
+		q, ok := GetQuote(c.quoteId, c.merchantId)
+		if ok != c.ok {
+			t.Fatalf("%s: ok = %v, want %v", c.name, ok, c.ok)
+		}
+		if ok && !q.IsSameMoney(12.345678) {
+			t.Fatalf("%s: money precision lost, got %v", c.name, q.Money)
+		}
+	}
+}
+
+func TestQuoteUse(t *testing.T) {
+	var cases = []struct {
+		name   string
+		orders []string
+		err    string
+	}{
+		{"first order", []string{"o1"}, ""},
+		{"same order submitted again", []string{"o1", "o1"}, ""},
+		{"other order rejected", []string{"o1", "o2"}, "已被其它订单使用"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setupTestDB(t)
+
+			var q = Quote{QuoteId: "q1", Money: 10, Rates: "{}", ExpiredAt: time.Now().Add(time.Minute)}
+			DB.Create(&q)
+
+			var err error
+			for _, orderId := range c.orders {
+				if err = useQuote(DB, "q1", orderId); err != nil {
+
+					break
+				}
+			}
+
+			if c.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)) {
+				t.Fatalf("err = %v, want %s", err, c.err)
+			}
+
+			if saved, _ := GetQuote("q1", 0); saved.OrderId != c.orders[0] {
+				t.Fatalf("quote bound to %q, want %q", saved.OrderId, c.orders[0])
+			}
+		})
+	}
+}
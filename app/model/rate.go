@@ -0,0 +1,193 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/v03413/bepusdt/app/conf"
+	"github.com/v03413/bepusdt/app/help"
+	"github.com/v03413/bepusdt/app/log"
+	"github.com/v03413/bepusdt/app/task/rate"
+)
+
+const RateSourceMedian = "median" // 多个来源聚合后的汇率
+
+// RateHistory 每次获取到的汇率，含各来源报价及聚合结果
+type RateHistory struct {
+	ID        int64     `gorm:"primary_key;AUTO_INCREMENT;comment:id" json:"id"`
+	Token     string    `gorm:"column:token;type:varchar(16);not null;index:idx_rate_history;comment:代币" json:"token"`
+	Fiat      string    `gorm:"column:fiat;type:varchar(8);not null;index:idx_rate_history;comment:法币" json:"fiat"`
+	Source    string    `gorm:"column:source;type:varchar(32);not null;comment:汇率来源" json:"source"`
+	Rate      float64   `gorm:"column:rate;type:decimal(20,8);not null;default:0;comment:原始汇率" json:"rate"`
+	Accepted  bool      `gorm:"column:accepted;not null;comment:是否采纳" json:"accepted"`
+	CreatedAt time.Time `gorm:"autoCreateTime;type:timestamp;not null;index:idx_rate_history;comment:创建时间" json:"created_at"`
+}
+
+func (r *RateHistory) TableName() string {
+
+	return "rate_history"
+}
+
+type RateHistoryQuery struct {
+	Token  string
+	Fiat   string
+	Source string
+	Start  time.Time
+	End    time.Time
+	Page   int
+	Size   int
+}
+
+// RateSnapshot 订单创建时采用的汇率快照，便于事后核对订单汇率来源及时效
+type RateSnapshot struct {
+	Syntax    string    `json:"syntax"`             // 汇率语法，指定汇率或配置汇率
+	Raw       float64   `json:"raw"`                // 代币/CNY 原始汇率
+	FiatRaw   float64   `json:"fiat_raw,omitempty"` // USDT/订单法币 原始汇率，非 CNY 订单
+	Sources   []string  `json:"sources"`            // 汇率来源
+	UpdatedAt time.Time `json:"updated_at"`         // 汇率更新时间
+	Stale     bool      `json:"stale"`              // 汇率已过期或尚未获取成功
+	Reason    string    `json:"reason,omitempty"`   // 过期原因
+	QuoteId   string    `json:"quote_id,omitempty"` // 凭报价下单时锁定汇率的报价ID
+}
+
+func (s RateSnapshot) String() string {
+	var data, _ = json.Marshal(s)
+
+	return string(data)
+}
+
+// ParseRateSnapshot 订单的汇率快照，历史订单未记录时返回 nil
+func (o TradeOrders) ParseRateSnapshot() *RateSnapshot {
+	var s RateSnapshot
+	if o.RateSnapshot == "" || json.Unmarshal([]byte(o.RateSnapshot), &s) != nil {
+
+		return nil
+	}
+
+	return &s
+}
+
+// SaveRateHistory 写入各来源报价及聚合结果
+func SaveRateHistory(token, fiat string, quotes []rate.Quote, median float64, accepted bool) {
+	var rows = make([]RateHistory, 0, len(quotes)+1)
+	for _, q := range quotes {
+		rows = append(rows, RateHistory{Token: token, Fiat: fiat, Source: q.Source, Rate: q.Price, Accepted: accepted})
+	}
+	if median > 0 {
+		rows = append(rows, RateHistory{Token: token, Fiat: fiat, Source: RateSourceMedian, Rate: median, Accepted: accepted})
+	}
+
+	if len(rows) == 0 {
+
+		return
+	}
+
+	if err := DB.Create(&rows).Error; err != nil {
+		log.Warn("汇率记录写入失败：", token, fiat, err)
+	}
+}
+
+// QueryRateHistory 按代币、法币、来源及时间筛选汇率记录，按时间倒序分页
+func QueryRateHistory(q RateHistoryQuery) ([]RateHistory, int64) {
+	var rows = make([]RateHistory, 0)
+	var total int64
+
+	var db = DB.Model(&RateHistory{})
+	if q.Token != "" {
+		db = db.Where("token = ?", strings.ToUpper(q.Token))
+	}
+	if q.Fiat != "" {
+		db = db.Where("fiat = ?", strings.ToUpper(q.Fiat))
+	}
+	if q.Source != "" {
+		db = db.Where("source = ?", q.Source)
+	}
+	if !q.Start.IsZero() {
+		db = db.Where("created_at >= ?", q.Start)
+	}
+	if !q.End.IsZero() {
+		db = db.Where("created_at < ?", q.End)
+	}
+
+	db.Count(&total)
+	db.Order("created_at desc, id desc").Offset((q.Page - 1) * q.Size).Limit(q.Size).Find(&rows)
+
+	return rows, total
+}
+
+// GetRecentRates 最近 n 条被采纳的聚合汇率，按时间正序
+func GetRecentRates(token, fiat string, n int) []RateHistory {
+	var rows = make([]RateHistory, 0)
+	DB.Where("token = ? and fiat = ? and source = ? and accepted = ?", token, fiat, RateSourceMedian, true).Order("id desc").Limit(n).Find(&rows)
+
+	for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+		rows[i], rows[j] = rows[j], rows[i]
+	}
+
+	return rows
+}
+
+// GetRateSnapshot 订单汇率快照；依赖的实时汇率过期时，按配置拒绝下单或在快照中标记过期
+func GetRateSnapshot(token TokenType, currency, param string) (RateSnapshot, error) {
+	var snap = RateSnapshot{Syntax: param}
+	if snap.Syntax == "" {
+		snap.Syntax = getRateSyntax(token)
+	}
+
+	// 固定数值的汇率(含指定汇率及报价锁定汇率)不依赖实时汇率
+	if help.IsNumber(snap.Syntax) && (param != "" || currency == rate.FiatCNY) {
+		snap.Sources = []string{conf.RateSourceFixed}
+		snap.UpdatedAt = time.Now()
+
+		return snap, nil
+	}
+
+	var stale []string
+	if s, ok := rate.GetSnapshot(string(token), rate.FiatCNY); ok {
+		snap.Raw, snap.Sources, snap.UpdatedAt = s.Raw, s.Sources, s.UpdatedAt
+	}
+	if err := rate.CheckFresh(string(token), rate.FiatCNY); err != nil {
+		stale = append(stale, err.Error())
+	}
+
+	if currency != rate.FiatCNY {
+		if s, ok := rate.GetSnapshot(string(TokenTypeUSDT), currency); ok {
+			snap.FiatRaw = s.Raw
+		}
+		if err := rate.CheckFresh(string(TokenTypeUSDT), currency); err != nil {
+			stale = append(stale, err.Error())
+		}
+	}
+
+	if len(stale) == 0 {
+
+		return snap, nil
+	}
+
+	snap.Stale = true
+	snap.Reason = strings.Join(stale, "；")
+	if conf.IsRateStaleRefuse() {
+
+		return snap, fmt.Errorf("汇率暂不可用：%s", snap.Reason)
+	}
+
+	log.Warn("汇率已过期，订单仍按最后汇率创建：", snap.Reason)
+
+	return snap, nil
+}
+
+// getRateSyntax 代币的汇率配置语法
+func getRateSyntax(token TokenType) string {
+	switch token {
+	case TokenTypeUSDT:
+		return conf.GetUsdtRate()
+	case TokenTypeUSDC:
+		return conf.GetUsdcRate()
+	case TokenTypeTRX:
+		return conf.GetTrxRate()
+	}
+
+	return conf.GetCoinRate(string(token))
+}
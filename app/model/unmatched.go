@@ -0,0 +1,137 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/v03413/bepusdt/app/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UnmatchedPayment 转入收款地址但未匹配到任何订单的交易，可手动关联到订单
+type UnmatchedPayment struct {
+	ID          int64     `gorm:"primary_key;AUTO_INCREMENT;comment:id" json:"id"`
+	TradeType   string    `gorm:"column:trade_type;type:varchar(20);not null;index;comment:交易类型" json:"trade_type"`
+	TxHash      string    `gorm:"column:tx_hash;type:varchar(130);not null;uniqueIndex;comment:交易哈希" json:"tx_hash"`
+	Amount      string    `gorm:"column:amount;type:decimal(20,8);not null;default:0;comment:交易数额" json:"amount"`
+	FromAddress string    `gorm:"column:from_address;type:varchar(66);not null;default:'';comment:支付地址" json:"from_address"`
+	RecvAddress string    `gorm:"column:recv_address;type:varchar(66);not null;index;comment:收款地址" json:"recv_address"`
+	BlockNum    int64     `gorm:"column:block_num;type:bigint(20);not null;default:0;comment:交易所在区块" json:"block_num"`
+	PaidAt      time.Time `gorm:"column:paid_at;type:timestamp;not null;comment:交易时间" json:"paid_at"`
+	TradeId     string    `gorm:"column:trade_id;type:varchar(128);not null;default:'';index;comment:关联订单" json:"trade_id"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;type:timestamp;not null;comment:创建时间" json:"created_at"`
+}
+
+func (p *UnmatchedPayment) TableName() string {
+
+	return "unmatched_payment"
+}
+
+// SaveUnmatchedPayment 记录未匹配收款，重复扫描的交易直接忽略
+func SaveUnmatchedPayment(p UnmatchedPayment) error {
+
+	return DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&p).Error
+}
+
+// GetUnmatchedPayments 尚未关联订单的收款，按时间倒序分页
+func GetUnmatchedPayments(page, size int) ([]UnmatchedPayment, int64) {
+	var rows = make([]UnmatchedPayment, 0)
+	var total int64
+
+	var db = DB.Model(&UnmatchedPayment{}).Where("trade_id = ''")
+	db.Count(&total)
+	db.Order("id desc").Offset((page - 1) * size).Limit(size).Find(&rows)
+
+	return rows, total
+}
+
+func GetUnmatchedPayment(id int64) (UnmatchedPayment, bool) {
+	var row UnmatchedPayment
+	var res = DB.Where("id = ?", id).Limit(1).Find(&row)
+
+	return row, res.RowsAffected > 0
+}
+
+// Attach 将收款关联到等待支付或已过期的订单，订单直接标记为收款成功，随后按正常流程通知；
+// 订单状态与收款关联在同一事务中写入，同一笔收款并发关联时只有一个订单生效
+func (p *UnmatchedPayment) Attach(tradeId, actor string) (TradeOrders, error) {
+	if p.TradeId != "" {
+
+		return TradeOrders{}, fmt.Errorf("该收款已关联订单 %s", p.TradeId)
+	}
+
+	order, ok := GetTradeOrder(tradeId)
+	if !ok {
+
+		return TradeOrders{}, errors.New("订单不存在")
+	}
+
+	if order.TradeType != p.TradeType {
+
+		return TradeOrders{}, fmt.Errorf("交易类型不一致：订单 %s，收款 %s", order.TradeType, p.TradeType)
+	}
+
+	if order.Address != "" && !strings.EqualFold(order.Address, p.RecvAddress) {
+
+		return TradeOrders{}, fmt.Errorf("收款地址不一致：订单 %s，收款 %s", order.Address, p.RecvAddress)
+	}
+
+	var reason = "手动关联收款"
+	if order.OpenAmount {
+		amount, err := decimal.NewFromString(p.Amount)
+		if err != nil {
+
+			return TradeOrders{}, err
+		}
+
+		// 不定额订单按关联的收款数额结算
+		if err = order.SettleOpenAmount(amount); err != nil {
+
+			return TradeOrders{}, err
+		}
+	}
+	if !isSameAmount(order.Amount, p.Amount) {
+		reason = fmt.Sprintf("手动关联收款，数额不一致：订单 %s，收款 %s", order.Amount, p.Amount)
+		log.Warn(fmt.Sprintf("订单(%s)%s", order.TradeId, reason))
+	}
+
+	order.PaidAmount = p.Amount
+	order.ActualAmount = p.Amount
+	order.FromAddress = p.FromAddress
+	order.TradeHash = p.TxHash
+	order.RefBlockNum = p.BlockNum
+	order.ConfirmedAt = p.PaidAt
+	err := order.transitionWith(OrderStatusSuccess, actor, reason, func(tx *gorm.DB) error {
+		var res = tx.Model(&UnmatchedPayment{}).Where("id = ? and trade_id = ''", p.ID).Update("trade_id", order.TradeId)
+		if res.Error != nil {
+
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+
+			return errors.New("该收款已关联其它订单")
+		}
+
+		return nil
+	}, paidEffects()...)
+	if err != nil {
+
+		return TradeOrders{}, err
+	}
+
+	p.TradeId = order.TradeId
+	LinkLedger(p.TxHash, p.RecvAddress, order.TradeId)
+
+	return order, nil
+}
+
+func isSameAmount(a, b string) bool {
+	x, err1 := decimal.NewFromString(a)
+	y, err2 := decimal.NewFromString(b)
+
+	return err1 == nil && err2 == nil && x.Equal(y)
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnmatchedPaymentAttach(t *testing.T) {
+	var cases = []struct {
+		name    string
+		order   TradeOrders
+		payment UnmatchedPayment
+		err     string
+	}{
+		{"same amount", TradeOrders{Amount: "10"}, UnmatchedPayment{Amount: "10"}, ""},
+		{"different amount attached with warning", TradeOrders{Amount: "10"}, UnmatchedPayment{Amount: "9.5"}, ""},
+		{"receive address differs", TradeOrders{Amount: "10"}, UnmatchedPayment{Amount: "10", RecvAddress: "TOther"}, "收款地址不一致"},
+		{"trade type differs", TradeOrders{Amount: "10", TradeType: OrderTradeTypeUsdtPolygon}, UnmatchedPayment{Amount: "10"}, "交易类型不一致"},
+		{"open amount order settled", TradeOrders{Amount: "0", Currency: "CNY", OpenAmount: true}, UnmatchedPayment{Amount: "12.5"}, ""},
+		{"already attached", TradeOrders{Amount: "10"}, UnmatchedPayment{Amount: "10", TradeId: "t0"}, "该收款已关联订单"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setupTestDB(t)
+
+			var o = c.order
+			o.TradeId, o.Address, o.Status = "t1", "TAddr", OrderStatusWaiting
+			createTestOrder(t, o)
+
+			var p = c.payment
+			p.TradeType, p.TxHash, p.PaidAt = OrderTradeTypeUsdtTrc20, "hash1", time.Now()
+			if p.RecvAddress == "" {
+				p.RecvAddress = "TAddr"
+			}
+			DB.Create(&p)
+
+			_, err := p.Attach("t1", ActorApi)
+			if c.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				order, _ := GetTradeOrder("t1")
+				if order.Status != OrderStatusSuccess || order.TradeHash != "hash1" || order.ActualAmount != p.Amount {
+					t.Fatalf("unexpected order %+v", order)
+				}
+				if order.OpenAmount && order.Amount != p.Amount {
+					t.Fatalf("open amount order not settled, amount %s", order.Amount)
+				}
+
+				saved, _ := GetUnmatchedPayment(p.ID)
+				if saved.TradeId != "t1" {
+					t.Fatalf("payment not linked, trade_id %q", saved.TradeId)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Fatalf("err = %v, want %s", err, c.err)
+			}
+			if order, _ := GetTradeOrder("t1"); order.Status != OrderStatusWaiting {
+				t.Fatalf("order must stay waiting, got %s", OrderStatusName(order.Status))
+			}
+		})
+	}
+}
+
+// 同一笔收款的两个副本分别关联不同订单，只有先提交的生效
+func TestUnmatchedPaymentAttachConcurrent(t *testing.T) {
+	setupTestDB(t)
+	createTestOrder(t, TradeOrders{TradeId: "t1", Amount: "10", Address: "TAddr", Status: OrderStatusWaiting})
+	createTestOrder(t, TradeOrders{TradeId: "t2", Amount: "10.01", Address: "TAddr", Status: OrderStatusWaiting})
+
+	var p = UnmatchedPayment{TradeType: OrderTradeTypeUsdtTrc20, TxHash: "hash1", Amount: "10", RecvAddress: "TAddr", PaidAt: time.Now()}
+	DB.Create(&p)
+
+	var stale = p
+	if _, err := p.Attach("t1", ActorApi); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stale.Attach("t2", ActorBot); err == nil {
+		t.Fatal("payment attached twice")
+	}
+
+	if order, _ := GetTradeOrder("t2"); order.Status != OrderStatusWaiting {
+		t.Fatalf("second order must stay waiting, got %s", OrderStatusName(order.Status))
+	}
+	if events := GetOrderEvents("t2"); len(events) != 0 {
+		t.Fatalf("rolled back transition left %d events", len(events))
+	}
+}
@@ -3,11 +3,10 @@ package task
 import (
 	"context"
 	"fmt"
-	"io"
 	"math/big"
-	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/panjf2000/ants/v2"
@@ -23,8 +22,9 @@ type aptos struct {
 	versionChunkSize       int64
 	versionConfirmedOffset int64
 	versionInitStartOffset int64
-	lastVersion            int64
+	versionMaxDiff         int64
 	versionQueue           *chanx.UnboundedChan[version]
+	native                 atomic.Bool // 是否解析 APT 原生币转账
 }
 
 type version struct {
@@ -34,9 +34,12 @@ type version struct {
 
 var apt aptos
 
+const aptosCoinType = "0x1::aptos_coin::AptosCoin"
+
 var aptDecimals = map[string]int32{
 	model.OrderTradeTypeUsdtAptos: conf.UsdtAptosDecimals,
 	model.OrderTradeTypeUsdcAptos: conf.UsdcAptosDecimals,
+	model.OrderTradeTypeAptAptos:  conf.AptDecimals,
 }
 
 type aptEvent struct {
@@ -63,41 +66,24 @@ func newAptos() aptos {
 		versionChunkSize:       100, // 目前好像最大就只能100
 		versionConfirmedOffset: 1000,
 		versionInitStartOffset: -100 * 500,
-		lastVersion:            0,
+		versionMaxDiff:         10000,
 		versionQueue:           chanx.NewUnboundedChan[version](context.Background(), 30),
 	}
 }
 
 func (a *aptos) versionRoll(ctx context.Context) {
+	var cur = getScanCursor(conf.Aptos)
 	if rollBreak(conf.Aptos) {
+		cur.reset()
 
 		return
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", conf.GetAptosRpcNode()+"/v1", nil)
-	if err != nil {
-		log.Warn("aptos versionRoll Error creating request:", err)
-
-		return
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Warn("aptos versionRoll Error sending request:", err)
-
-		return
-	}
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		log.Warn("aptos versionRoll Error response status code:", resp.StatusCode)
-
-		return
-	}
+	a.native.Store(nativeWatch(conf.Aptos))
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := rpcCall(ctx, conf.Aptos, "/v1", nil)
 	if err != nil {
-		log.Warn("aptos versionRoll Error reading response body:", err)
+		log.Warn("aptos versionRoll Error:", err)
 
 		return
 	}
@@ -114,44 +100,23 @@ func (a *aptos) versionRoll(ctx context.Context) {
 		now = now - a.versionConfirmedOffset
 	}
 
-	if now-a.lastVersion > 10000 {
+	// ledger_version 为最新版本号，本次扫描至 now - 1
+	ranges, fresh := cur.next(now-1, a.versionMaxDiff, a.versionChunkSize)
+	if fresh {
 		a.versionInitOffset(now)
-		a.lastVersion = now - a.versionChunkSize
-	}
-
-	var sub = now - a.lastVersion
-	if now == 0 {
 
 		return
 	}
 
-	if sub <= a.versionChunkSize {
-		a.versionQueue.In <- version{Start: a.lastVersion, Limit: sub}
-	} else {
-		chunks := (sub + a.versionChunkSize - 1) / a.versionChunkSize
-		for i := int64(0); i < chunks; i++ {
-			limit := a.versionChunkSize
-			start := a.lastVersion + a.versionChunkSize*i
-			if i == chunks-1 {
-				limit = sub % a.versionChunkSize
-				if limit == 0 {
-					limit = a.versionChunkSize
-				}
-			}
-
-			a.versionQueue.In <- version{Start: start, Limit: limit}
-		}
+	for _, r := range ranges {
+		a.versionQueue.In <- version{Start: r.From, Limit: r.To - r.From + 1}
 	}
-
-	a.lastVersion = now
 }
 
 func (a *aptos) versionDispatch(ctx context.Context) {
 	p, err := ants.NewPoolWithFunc(3, a.versionParse)
 	if err != nil {
 		panic(err)
-
-		return
 	}
 
 	defer p.Release()
@@ -174,7 +139,7 @@ func (a *aptos) versionDispatch(ctx context.Context) {
 }
 
 func (a *aptos) versionInitOffset(now int64) {
-	if now == 0 || a.lastVersion != 0 {
+	if now == 0 {
 
 		return
 	}
@@ -197,36 +162,19 @@ func (a *aptos) versionInitOffset(now int64) {
 	}()
 }
 
-// 由于 aptos 网络特性，交易数据中不会显示存在交易转账 from => to 的对应关系，
-// 所以目前此解析函数存在大量循环嵌套解析，逻辑较为复杂，希望未来有更好的方式进行解析 慢慢优化
 func (a *aptos) versionParse(n any) {
 	p := n.(version)
 
 	var net = conf.Aptos
-	var url = fmt.Sprintf("%sv1/transactions?start=%d&limit=%d", conf.GetAptosRpcNode(), p.Start, p.Limit)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
 
 	conf.SetBlockTotal(net)
-	resp, err := client.Get(url)
-	if err != nil {
-		conf.SetBlockFail(net)
-		log.Warn("versionParse Error sending request:", err)
-
-		return
-	}
-
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		conf.SetBlockFail(net)
-		log.Warn("versionParse Error response status code:", resp.StatusCode)
-
-		return
-	}
-
-	body, err := io.ReadAll(resp.Body)
+	body, err := rpcCall(ctx, net, fmt.Sprintf("v1/transactions?start=%d&limit=%d", p.Start, p.Limit), nil)
 	if err != nil {
 		conf.SetBlockFail(net)
 		a.versionQueue.In <- p
-		log.Warn("versionParse Error reading response body:", err)
+		log.Warn("versionParse Error:", err)
 
 		return
 	}
@@ -239,8 +187,23 @@ func (a *aptos) versionParse(n any) {
 		return
 	}
 
+	transfers := a.parseTransfers(gjson.ParseBytes(body))
+	if len(transfers) > 0 {
+
+		transferQueue.In <- transfers
+	}
+
+	getScanCursor(net).done(p.Start)
+	log.Debug("区块扫描完成", fmt.Sprintf("%d.%d", p.Start, p.Limit), conf.GetBlockSuccRate(net), net)
+}
+
+// parseTransfers 解析一批交易中的代币及 APT 转账
+// 由于 aptos 网络特性，交易数据中不会显示存在交易转账 from => to 的对应关系，
+// 所以目前此解析函数存在大量循环嵌套解析，逻辑较为复杂，希望未来有更好的方式进行解析 慢慢优化
+func (a *aptos) parseTransfers(body gjson.Result) []transfer {
+	var net = conf.Aptos
 	transfers := make([]transfer, 0)
-	for _, trans := range gjson.ParseBytes(body).Array() {
+	for _, trans := range body.Array() {
 		tsNano := trans.Get("timestamp").Int() * 1000
 		timestamp := time.Unix(tsNano/1e9, tsNano%1e9)
 		ver := trans.Get("version").Int()
@@ -263,6 +226,10 @@ func (a *aptos) versionParse(n any) {
 					addrType[addr] = model.OrderTradeTypeUsdtAptos
 				case conf.UsdcAptos:
 					addrType[addr] = model.OrderTradeTypeUsdcAptos
+				case conf.AptosCoin:
+					if a.native.Load() {
+						addrType[addr] = model.OrderTradeTypeAptAptos
+					}
 				}
 			}
 			if data.Get("type").String() == "0x1::object::ObjectCore" {
@@ -280,11 +247,18 @@ func (a *aptos) versionParse(n any) {
 			}
 
 			address := v.Get("data.store").String()
+			if a.native.Load() && v.Get("data.coin_type").String() == aptosCoinType {
+				// 尚未迁移至 FungibleStore 的账户，APT 转账产生 0x1::coin 事件，事件直接给出账户地址
+				address = v.Get("data.account").String()
+				addrOwner[address] = address
+				addrType[address] = model.OrderTradeTypeAptAptos
+			}
+
 			switch v.Get("type").String() {
-			case "0x1::fungible_asset::Deposit":
+			case "0x1::fungible_asset::Deposit", "0x1::coin::CoinDeposit":
 				aptEvents = append(aptEvents, aptEvent{Amount: amt, Address: address, Action: "deposit"})
 				amtAddrMap["deposit"][aptAmount{Amount: amount, Type: addrType[address]}] = address
-			case "0x1::fungible_asset::Withdraw":
+			case "0x1::fungible_asset::Withdraw", "0x1::coin::CoinWithdraw":
 				amtAddrMap["withdraw"][aptAmount{Amount: amount, Type: addrType[address]}] = address
 				aptEvents = append(aptEvents, aptEvent{Amount: amt, Address: address, Action: "withdraw"})
 			}
@@ -382,14 +356,13 @@ func (a *aptos) versionParse(n any) {
 		// 处理 USDC
 		usdcDeposits, usdcFrom := processEvents(model.OrderTradeTypeUsdcAptos, aptEvents)
 		generateTransfers(usdcDeposits, usdcFrom, model.OrderTradeTypeUsdcAptos, aptDecimals[model.OrderTradeTypeUsdcAptos])
-	}
 
-	if len(transfers) > 0 {
-
-		transferQueue.In <- transfers
+		// 处理 APT
+		aptDeposits, aptFrom := processEvents(model.OrderTradeTypeAptAptos, aptEvents)
+		generateTransfers(aptDeposits, aptFrom, model.OrderTradeTypeAptAptos, aptDecimals[model.OrderTradeTypeAptAptos])
 	}
 
-	log.Debug("区块扫描完成", fmt.Sprintf("%d.%d", p.Start, p.Limit), conf.GetBlockSuccRate(net), net)
+	return transfers
 }
 
 func (a *aptos) padAddressLeadingZeros(addr string) string {
@@ -404,30 +377,9 @@ func (a *aptos) tradeConfirmHandle(ctx context.Context) {
 	var wg sync.WaitGroup
 
 	var handle = func(o model.TradeOrders) {
-		req, err := http.NewRequestWithContext(ctx, "GET", conf.GetAptosRpcNode()+"v1/transactions/by_hash/"+o.TradeHash, nil)
-		if err != nil {
-			log.Warn("aptos tradeConfirmHandle Error creating request:", err)
-
-			return
-		}
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Warn("aptos tradeConfirmHandle Error sending request:", err)
-
-			return
-		}
-
-		defer resp.Body.Close()
-
-		if resp.StatusCode != 200 {
-			log.Warn("aptos tradeConfirmHandle Error response status code:", resp.StatusCode)
-
-			return
-		}
-
-		body, err := io.ReadAll(resp.Body)
+		body, err := rpcCall(ctx, conf.Aptos, "v1/transactions/by_hash/"+o.TradeHash, nil)
 		if err != nil {
-			log.Warn("aptos tradeConfirmHandle Error reading response body:", err)
+			log.Warn("aptos tradeConfirmHandle Error:", err)
 
 			return
 		}
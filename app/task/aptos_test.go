@@ -0,0 +1,72 @@
+package task
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+	"github.com/v03413/bepusdt/app/conf"
+	"github.com/v03413/bepusdt/app/model"
+)
+
+// aptStore FungibleStore 及其所有者
+func aptStore(store, owner, metadata string) string {
+
+	return fmt.Sprintf(`{"type":"write_resource","address":"%s","data":{"type":"0x1::fungible_asset::FungibleStore","data":{"metadata":{"inner":"%s"}}}},
+		{"type":"write_resource","address":"%s","data":{"type":"0x1::object::ObjectCore","data":{"owner":"%s"}}}`, store, metadata, store, owner)
+}
+
+func TestAptosParseTransfers(t *testing.T) {
+	var fa = func(metadata, amount string) string {
+
+		return fmt.Sprintf(`[{"version":"100","hash":"0xhash","timestamp":"1700000000000000","changes":[%s,%s],"events":[
+			{"type":"0x1::fungible_asset::Withdraw","data":{"store":"0xs1","amount":"%s"}},
+			{"type":"0x1::fungible_asset::Deposit","data":{"store":"0xs2","amount":"%s"}}]}]`,
+			aptStore("0xs1", "0xf1", metadata), aptStore("0xs2", "0xe2", metadata), amount, amount)
+	}
+	var coin = fmt.Sprintf(`[{"version":"100","hash":"0xhash","timestamp":"1700000000000000","changes":[],"events":[
+		{"type":"0x1::coin::CoinWithdraw","data":{"coin_type":"%s","account":"0xf1","amount":"250000000"}},
+		{"type":"0x1::coin::CoinDeposit","data":{"coin_type":"%s","account":"0xe2","amount":"250000000"}}]}]`, aptosCoinType, aptosCoinType)
+
+	var cases = []struct {
+		name      string
+		native    bool
+		body      string
+		tradeType string
+		amount    string
+	}{
+		{"apt fungible asset transfer", true, fa(conf.AptosCoin, "100000000"), model.OrderTradeTypeAptAptos, "1"},
+		{"apt coin event transfer", true, coin, model.OrderTradeTypeAptAptos, "2.5"},
+		{"apt ignored without native orders", false, fa(conf.AptosCoin, "100000000"), "", ""},
+		{"apt coin event ignored without native orders", false, coin, "", ""},
+		{"usdt parsed regardless of native", false, fa(conf.UsdtAptos, "10000000"), model.OrderTradeTypeUsdtAptos, "10"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var a aptos
+			a.native.Store(c.native)
+
+			var transfers = a.parseTransfers(gjson.Parse(c.body))
+			if c.tradeType == "" {
+				if len(transfers) != 0 {
+					t.Fatalf("unexpected transfers %+v", transfers)
+				}
+
+				return
+			}
+			if len(transfers) != 1 {
+				t.Fatalf("got %d transfers, want 1", len(transfers))
+			}
+
+			var tr = transfers[0]
+			if tr.TradeType != c.tradeType || tr.Amount.String() != c.amount || tr.BlockNum != 100 || tr.TxHash != "0xhash" {
+				t.Fatalf("unexpected transfer %s %s block %d hash %s", tr.TradeType, tr.Amount, tr.BlockNum, tr.TxHash)
+			}
+			if tr.FromAddress != "0x"+strings.Repeat("0", 62)+"f1" || tr.RecvAddress != "0x"+strings.Repeat("0", 62)+"e2" {
+				t.Fatalf("unexpected addresses %s => %s", tr.FromAddress, tr.RecvAddress)
+			}
+		})
+	}
+}
@@ -11,8 +11,7 @@ import (
 func arbitrumInit() {
 	ctx := context.Background()
 	arb := evm{
-		Network:  conf.Arbitrum,
-		Endpoint: conf.GetArbitrumRpcEndpoint(),
+		Network: conf.Arbitrum,
 		Block: block{
 			InitStartOffset: -600,
 			ConfirmedOffset: 40,
@@ -17,8 +17,7 @@ func TestArbitrum(t *testing.T) {
 	ctx := context.Background()
 
 	arb := evm{
-		Network:  conf.Arbitrum,
-		Endpoint: conf.GetArbitrumRpcEndpoint(),
+		Network: conf.Arbitrum,
 		Block: block{
 			//InitStartOffset: -600,
 			ConfirmedOffset: 40,
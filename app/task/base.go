@@ -11,8 +11,7 @@ import (
 func baseInit() {
 	ctx := context.Background()
 	base := evm{
-		Network:  conf.Base,
-		Endpoint: conf.GetBaseRpcEndpoint(),
+		Network: conf.Base,
 		Block: block{
 			InitStartOffset: -600,
 			ConfirmedOffset: 40,
@@ -11,8 +11,7 @@ import (
 func bscInit() {
 	ctx := context.Background()
 	bsc := evm{
-		Network:  conf.Bsc,
-		Endpoint: conf.GetBscRpcEndpoint(),
+		Network: conf.Bsc,
 		Block: block{
 			InitStartOffset: -400,
 			ConfirmedOffset: 15,
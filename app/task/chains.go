@@ -0,0 +1,42 @@
+package task
+
+import (
+	"context"
+	"time"
+
+	"github.com/smallnest/chanx"
+	"github.com/v03413/bepusdt/app/conf"
+)
+
+// chainsInit 配置文件 [[chains]] 声明的通用 EVM 网络
+func chainsInit() {
+	for _, c := range conf.GetChains() {
+		chain := newChain(c)
+
+		register(task{callback: chain.blockDispatch})
+		register(task{callback: chain.blockRoll, duration: time.Second * 5})
+		register(task{callback: chain.tradeConfirmHandle, duration: time.Second * 5})
+	}
+}
+
+// newChain 根据配置创建通用 EVM 网络的扫描实例，并登记网络下的交易类型
+func newChain(c conf.Chain) *evm {
+	var tokens = make(map[string]conf.ChainToken)
+	var tradeTypes = make([]string, 0)
+	for _, t := range c.Tokens {
+		tokens[t.Contract] = t
+		tradeTypes = append(tradeTypes, t.TradeType)
+	}
+
+	networkTokenMap[c.Network] = tradeTypes
+
+	return &evm{
+		Network: c.Network,
+		Block: block{
+			InitStartOffset: -400,
+			ConfirmedOffset: c.Confirmations,
+		},
+		tokens:         tokens,
+		blockScanQueue: chanx.NewUnboundedChan[evmBlock](context.Background(), 30),
+	}
+}
@@ -0,0 +1,82 @@
+package task
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/tidwall/gjson"
+	"github.com/v03413/bepusdt/app/conf"
+	"github.com/v03413/bepusdt/app/log"
+)
+
+const testChainContract = "0x94b008aa00579c1307b0ef2c499ad98a8ce58e58"
+
+// setupTestChain 加载声明了测试网络的配置，节点指向 rpc
+func setupTestChain(t *testing.T, rpc string) *evm {
+	t.Helper()
+	_ = log.Init()
+
+	var data = fmt.Sprintf(`
+[bot]
+token = "test"
+admin_id = 1
+
+[[chains]]
+network = "testnet"
+rpc = ["%s"]
+confirmations = 3
+[[chains.tokens]]
+symbol = "USDT"
+contract = "%s"
+decimals = 6
+`, rpc, testChainContract)
+	if err := conf.Load([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	c, ok := conf.GetChain("testnet")
+	if !ok {
+		t.Fatal("test chain not loaded")
+	}
+
+	return newChain(c)
+}
+
+func TestChainTransfer(t *testing.T) {
+	var topic = func(addr string) string {
+
+		return "0x000000000000000000000000" + addr[2:]
+	}
+
+	var rpc = newTestRpc(t, func(method string, params []gjson.Result) string {
+		if method != "eth_getLogs" {
+
+			return "null"
+		}
+
+		var entry = `{"address":"%s","topics":["%s","%s","%s"],"data":"0x%x","blockNumber":"0x64","transactionHash":"0xhash","logIndex":"0x1"}`
+
+		return "[" + fmt.Sprintf(entry, testChainContract, evmTransferEvent, topic("0x1111111111111111111111111111111111111111"), topic("0x2222222222222222222222222222222222222222"), 12500000) + "," +
+			fmt.Sprintf(entry, "0x01bff41798a0bcf287b996046ca68b395dbc1071", evmTransferEvent, topic("0x1111111111111111111111111111111111111111"), topic("0x2222222222222222222222222222222222222222"), 1) + "]"
+	})
+
+	var chain = setupTestChain(t, rpc)
+	if chain.Block.ConfirmedOffset != 3 || len(networkTokenMap["testnet"]) != 1 || networkTokenMap["testnet"][0] != "usdt.testnet" {
+		t.Fatalf("unexpected chain %+v token map %v", chain.Block, networkTokenMap["testnet"])
+	}
+
+	transfers, err := chain.parseBlockTransfer(evmBlock{From: 100, To: 100}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 只识别配置的代币合约，数额按配置的精度换算
+	if len(transfers) != 1 {
+		t.Fatalf("unexpected transfers %+v", transfers)
+	}
+
+	var tr = transfers[0]
+	if tr.TradeType != "usdt.testnet" || tr.Network != "testnet" || tr.Amount.String() != "12.5" || tr.RecvAddress != "0x2222222222222222222222222222222222222222" || tr.BlockNum != 100 {
+		t.Fatalf("unexpected transfer %+v", tr)
+	}
+}
@@ -0,0 +1,152 @@
+package task
+
+import (
+	"sync"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/v03413/bepusdt/app/log"
+	"github.com/v03413/bepusdt/app/model"
+)
+
+const cursorSaveInterval = time.Second * 3 // 游标持久化最小间隔
+
+// scanCursor 区块扫描游标，记录已完整处理的最大区块高度并持久化到 config 表，重启后从此处继续扫描
+type scanCursor struct {
+	key     string
+	mu      sync.Mutex
+	loaded  bool
+	head    int64           // 已入列的最大区块高度
+	saved   int64           // 已持久化的区块高度
+	savedAt time.Time       // 最近一次持久化时间
+	pending map[int64]int64 // 已入列但尚未处理完成的区间 from => to
+}
+
+var cursors sync.Map // map[string]*scanCursor
+
+func getScanCursor(network string) *scanCursor {
+	val, _ := cursors.LoadOrStore(network, &scanCursor{key: "cursor_" + network, pending: make(map[int64]int64)})
+
+	return val.(*scanCursor)
+}
+
+func (c *scanCursor) load() {
+	if c.loaded {
+
+		return
+	}
+
+	c.loaded = true
+	if unitTestMode {
+
+		return
+	}
+
+	c.head = cast.ToInt64(model.GetK(c.key))
+	c.saved = c.head
+}
+
+// scanRange 已入列扫描的区间 [From, To]
+type scanRange struct {
+	From int64
+	To   int64
+}
+
+// next 根据当前链上高度计算本次需要入列扫描的区间，并按 step 拆分后登记为待处理
+//   - 推进游标与登记待处理区间在同一临界区内完成，避免 done 在区间登记前推进并持久化游标
+//   - fresh 为 true 表示不存在扫描进度(首次启动或空闲之后)，调用方需要自行决定回溯扫描
+//   - 与上次进度差值超过 maxDiff 时进入追赶模式，每次最多推进 maxDiff 个区块，不再丢弃中间区块
+func (c *scanCursor) next(now, maxDiff, step int64) (ranges []scanRange, fresh bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.load()
+	if c.head == 0 {
+		c.head = now
+
+		return nil, true
+	}
+
+	if now <= c.head {
+
+		return nil, false
+	}
+
+	var from, to = c.head + 1, now
+	if to-c.head > maxDiff {
+		to = c.head + maxDiff
+
+		log.Debug("区块扫描追赶中", c.key, from, to, now)
+	}
+
+	for start := from; start > 0 && start <= to; start += step {
+		var r = scanRange{From: start, To: min(start+step-1, to)}
+
+		c.pending[r.From] = r.To
+		ranges = append(ranges, r)
+	}
+
+	c.head = to
+
+	return ranges, false
+}
+
+// done 标记区间处理完成，推进并持久化游标
+func (c *scanCursor) done(from int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.pending[from]; !ok {
+
+		return
+	}
+
+	delete(c.pending, from)
+
+	var safe = c.safeHead()
+	if safe <= c.saved || time.Since(c.savedAt) < cursorSaveInterval {
+
+		return
+	}
+
+	c.save(safe)
+}
+
+// safeHead 之前区块均已处理完成的最大高度，调用方需持有锁
+func (c *scanCursor) safeHead() int64 {
+	var safe = c.head
+	for f := range c.pending {
+		if f-1 < safe {
+			safe = f - 1
+		}
+	}
+
+	return safe
+}
+
+// reset 网络空闲(无待支付订单且无监控地址)时清空游标，恢复扫描时重新回溯
+func (c *scanCursor) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.load()
+	if c.head == 0 && c.saved == 0 {
+
+		return
+	}
+
+	c.head = 0
+	c.pending = make(map[int64]int64)
+	c.save(0)
+}
+
+func (c *scanCursor) save(num int64) {
+	c.saved = num
+	c.savedAt = time.Now()
+	if unitTestMode {
+
+		return
+	}
+
+	model.SetK(c.key, cast.ToString(num))
+}
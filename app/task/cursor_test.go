@@ -0,0 +1,110 @@
+package task
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/v03413/bepusdt/app/log"
+)
+
+func TestScanCursor(t *testing.T) {
+	unitTestMode = true
+	_ = log.Init()
+
+	cur := getScanCursor(`cursor_test`)
+	defer cursors.Delete(`cursor_test`)
+
+	if _, fresh := cur.next(100, 50, 10); !fresh {
+		t.Fatal(`expected fresh cursor`)
+	}
+
+	ranges, _ := cur.next(120, 50, 10)
+	if len(ranges) != 2 || ranges[0] != (scanRange{101, 110}) || ranges[1] != (scanRange{111, 120}) {
+		t.Fatalf(`unexpected ranges %v`, ranges)
+	}
+
+	// 追赶模式，每次最多推进 maxDiff
+	ranges, _ = cur.next(1000, 50, 20)
+	if len(ranges) != 3 || ranges[0].From != 121 || ranges[2] != (scanRange{161, 170}) {
+		t.Fatalf(`unexpected catch up ranges %v`, ranges)
+	}
+
+	cur.done(111)
+	if cur.saved != 100 {
+		t.Fatalf(`cursor must not pass pending range, saved %d`, cur.saved)
+	}
+
+	for _, r := range ranges {
+		cur.done(r.From)
+	}
+
+	cur.savedAt = cur.savedAt.Add(-cursorSaveInterval)
+	cur.done(101)
+	if cur.saved != 170 {
+		t.Fatalf(`unexpected saved %d`, cur.saved)
+	}
+
+	cur.reset()
+	if _, fresh := cur.next(2000, 50, 10); !fresh {
+		t.Fatal(`expected fresh cursor after reset`)
+	}
+}
+
+// 入列与处理并发进行时，游标不能越过任何尚未处理完成的区块
+func TestScanCursorConcurrent(t *testing.T) {
+	unitTestMode = true
+	_ = log.Init()
+
+	cur := getScanCursor(`cursor_concurrent_test`)
+	defer cursors.Delete(`cursor_concurrent_test`)
+
+	if _, fresh := cur.next(100, 50, 5); !fresh {
+		t.Fatal(`expected fresh cursor`)
+	}
+
+	var processed sync.Map
+	var queue = make(chan scanRange, 16)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for r := range queue {
+				for n := r.From; n <= r.To; n++ {
+					processed.Store(n, true)
+				}
+
+				cur.done(r.From)
+
+				cur.mu.Lock()
+				var safe = cur.safeHead()
+				cur.mu.Unlock()
+
+				for n := int64(101); n <= safe; n++ {
+					if _, ok := processed.Load(n); !ok {
+						t.Errorf(`cursor passed unprocessed block %d, safe %d`, n, safe)
+
+						break
+					}
+				}
+			}
+		}()
+	}
+
+	for now := int64(101); now <= 1100; now += 7 {
+		ranges, _ := cur.next(now, 50, 5)
+		for _, r := range ranges {
+			queue <- r
+		}
+	}
+
+	close(queue)
+	wg.Wait()
+
+	cur.mu.Lock()
+	defer cur.mu.Unlock()
+	if len(cur.pending) != 0 || cur.safeHead() != cur.head {
+		t.Fatalf(`unexpected pending %v head %d`, cur.pending, cur.head)
+	}
+}
@@ -11,8 +11,7 @@ import (
 func ethInit() {
 	ctx := context.Background()
 	eth := evm{
-		Network:  conf.Ethereum,
-		Endpoint: conf.GetEthereumRpcEndpoint(),
+		Network: conf.Ethereum,
 		Block: block{
 			InitStartOffset: -100,
 			ConfirmedOffset: 12,
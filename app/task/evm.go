@@ -1,16 +1,16 @@
 package task
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"io"
 	"math/big"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/panjf2000/ants/v2"
@@ -25,10 +25,10 @@ import (
 
 const (
 	blockParseMaxNum = 10 // 每次解析区块的最大数量
+	evmReorgMaxDepth = 64 // 区块重组最大回溯深度
 	evmTransferEvent = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
 )
 
-var chainBlockNum sync.Map
 var contractMap = map[string]string{
 	conf.UsdtXlayer:   model.OrderTradeTypeUsdtXlayer,
 	conf.UsdtBep20:    model.OrderTradeTypeUsdtBep20,
@@ -42,15 +42,28 @@ var contractMap = map[string]string{
 	conf.UsdcBep20:    model.OrderTradeTypeUsdcBep20,
 	conf.UsdcBase:     model.OrderTradeTypeUsdcBase,
 }
+var nativeTradeTypeMap = map[string]string{
+	conf.Bsc:      model.OrderTradeTypeBnbBep20,
+	conf.Xlayer:   model.OrderTradeTypeOkbXlayer,
+	conf.Polygon:  model.OrderTradeTypePolPolygon,
+	conf.Arbitrum: model.OrderTradeTypeEthArbitrum,
+	conf.Ethereum: model.OrderTradeTypeEthErc20,
+	conf.Base:     model.OrderTradeTypeEthBase,
+	conf.Solana:   model.OrderTradeTypeSolSolana,
+	conf.Aptos:    model.OrderTradeTypeAptAptos,
+}
 var networkTokenMap = map[string][]string{
-	conf.Bsc:      {model.OrderTradeTypeUsdtBep20, model.OrderTradeTypeUsdcBep20},
-	conf.Xlayer:   {model.OrderTradeTypeUsdtXlayer, model.OrderTradeTypeUsdcXlayer},
-	conf.Polygon:  {model.OrderTradeTypeUsdtPolygon, model.OrderTradeTypeUsdcPolygon},
-	conf.Arbitrum: {model.OrderTradeTypeUsdtArbitrum, model.OrderTradeTypeUsdcArbitrum},
-	conf.Ethereum: {model.OrderTradeTypeUsdtErc20, model.OrderTradeTypeUsdcErc20},
-	conf.Base:     {model.OrderTradeTypeUsdcBase},
-	conf.Solana:   {model.OrderTradeTypeUsdtSolana, model.OrderTradeTypeUsdcSolana},
-	conf.Aptos:    {model.OrderTradeTypeUsdtAptos, model.OrderTradeTypeUsdcAptos},
+	conf.Bsc:      {model.OrderTradeTypeUsdtBep20, model.OrderTradeTypeUsdcBep20, model.OrderTradeTypeBnbBep20},
+	conf.Xlayer:   {model.OrderTradeTypeUsdtXlayer, model.OrderTradeTypeUsdcXlayer, model.OrderTradeTypeOkbXlayer},
+	conf.Polygon:  {model.OrderTradeTypeUsdtPolygon, model.OrderTradeTypeUsdcPolygon, model.OrderTradeTypePolPolygon},
+	conf.Arbitrum: {model.OrderTradeTypeUsdtArbitrum, model.OrderTradeTypeUsdcArbitrum, model.OrderTradeTypeEthArbitrum},
+	conf.Ethereum: {model.OrderTradeTypeUsdtErc20, model.OrderTradeTypeUsdcErc20, model.OrderTradeTypeEthErc20},
+	conf.Base:     {model.OrderTradeTypeUsdcBase, model.OrderTradeTypeEthBase},
+	conf.Solana:   {model.OrderTradeTypeUsdtSolana, model.OrderTradeTypeUsdcSolana, model.OrderTradeTypeSolSolana},
+	conf.Aptos:    {model.OrderTradeTypeUsdtAptos, model.OrderTradeTypeUsdcAptos, model.OrderTradeTypeAptAptos},
+	conf.Ton:      {model.OrderTradeTypeUsdtTon},
+	conf.Bitcoin:  {model.OrderTradeTypeBtcBitcoin},
+	conf.Litecoin: {model.OrderTradeTypeLtcLitecoin},
 }
 var client = &http.Client{Timeout: time.Second * 30}
 var decimals = map[string]int32{
@@ -79,6 +92,8 @@ type evm struct {
 	Network        string
 	Endpoint       string
 	Block          block
+	tokens         map[string]conf.ChainToken // 通用 EVM 网络配置的代币 合约地址 => 代币，为空则使用内置合约
+	native         atomic.Bool                // 是否需要扫描原生币转账
 	blockScanQueue *chanx.UnboundedChan[evmBlock]
 	debug          bool
 }
@@ -93,78 +108,78 @@ func init() {
 }
 
 func (e *evm) blockRoll(ctx context.Context) {
+	var cur = getScanCursor(e.Network)
 	if rollBreak(e.Network) {
+		cur.reset()
 
 		return
 	}
 
-	post := []byte(`{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`)
-	req, err := http.NewRequestWithContext(ctx, "POST", e.Endpoint, bytes.NewBuffer(post))
+	e.native.Store(nativeWatch(e.Network))
+
+	latest, err := e.blockNumber(ctx)
 	if err != nil {
-		log.Warn("Error creating request:", err)
+		log.Warn(e.Network, "blockRoll Error:", err)
 
 		return
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Warn("Error sending request:", err)
+	var now = latest - e.Block.RollDelayOffset
+	if now <= 0 {
 
 		return
 	}
 
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Warn("Error reading response body:", err)
+	if conf.GetTradeIsConfirmed() {
 
-		return
+		now = now - e.Block.ConfirmedOffset
 	}
 
-	e.debugPrintln(`blockRoll`, body)
-	var res = gjson.ParseBytes(body)
-	var now = help.HexStr2Int(res.Get("result").String()).Int64() - e.Block.RollDelayOffset
-	if now <= 0 {
+	ranges, fresh := cur.next(now, conf.BlockHeightMaxDiff, blockParseMaxNum)
+	if fresh {
+		e.blockInitOffset(now, e.Block.InitStartOffset)
 
 		return
 	}
 
-	if conf.GetTradeIsConfirmed() {
-
-		now = now - e.Block.ConfirmedOffset
+	for _, r := range ranges {
+		n := evmBlock{From: r.From, To: r.To}
+		e.debugPrintln(`out`, n)
+		e.blockScanQueue.In <- n
 	}
+}
 
-	var lastBlockNumber int64
-	if v, ok := chainBlockNum.Load(e.Network); ok {
+// blockNumber 获取当前最新区块高度
+func (e *evm) blockNumber(ctx context.Context) (int64, error) {
+	body, err := e.rpcPost(ctx, []byte(`{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`))
+	if err != nil {
 
-		lastBlockNumber = v.(int64)
+		return 0, errors.Join(errors.New("eth_blockNumber error"), err)
 	}
 
-	if now-lastBlockNumber > conf.BlockHeightMaxDiff {
-		lastBlockNumber = e.blockInitOffset(now, e.Block.InitStartOffset) - 1
-	}
+	e.debugPrintln(`blockNumber`, body)
 
-	chainBlockNum.Store(e.Network, now)
-	if now <= lastBlockNumber {
+	return help.HexStr2Int(gjson.GetBytes(body, "result").String()).Int64(), nil
+}
 
-		return
+// rpcPost 选择当前最优节点发送请求，并上报节点请求结果
+func (e *evm) rpcPost(ctx context.Context, post []byte) ([]byte, error) {
+	var ep = conf.PickEndpoint(e.Network)
+	body, err := rpcDo(ctx, ep.URL, post)
+	if err == nil {
+		err = rpcError(body)
 	}
 
-	for from := lastBlockNumber + 1; from <= now; from += blockParseMaxNum {
-		to := from + blockParseMaxNum - 1
-		if to > now {
-			to = now
-		}
+	ep.Done(err)
+	if err != nil {
 
-		n := evmBlock{From: from, To: to}
-		e.debugPrintln(`out`, n)
-		e.blockScanQueue.In <- n
+		return nil, fmt.Errorf("%s %w", ep.URL, err)
 	}
+
+	return body, nil
 }
 
-func (e *evm) blockInitOffset(now, offset int64) int64 {
+func (e *evm) blockInitOffset(now, offset int64) {
 	go func() {
 		ticker := time.NewTicker(time.Second)
 		defer ticker.Stop()
@@ -180,8 +195,6 @@ func (e *evm) blockInitOffset(now, offset int64) int64 {
 			<-ticker.C
 		}
 	}()
-
-	return now
 }
 
 func (e *evm) debugPrintln(name string, v interface{}) {
@@ -197,8 +210,6 @@ func (e *evm) blockDispatch(ctx context.Context) {
 	p, err := ants.NewPoolWithFunc(2, e.getBlockByNumber)
 	if err != nil {
 		panic(err)
-
-		return
 	}
 
 	defer p.Release()
@@ -226,53 +237,62 @@ func (e *evm) getBlockByNumber(a any) {
 		return
 	}
 
+	// 需要扫描原生币转账时获取区块内的完整交易
+	var full = e.native.Load()
+	var timeout = time.Second * 5
+	if full {
+		timeout = time.Second * 15
+	}
+
 	items := make([]string, 0)
 	for i := b.From; i <= b.To; i++ {
-		items = append(items, fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["0x%x",false],"id":%d}`, i, i))
+		items = append(items, fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["0x%x",%t],"id":%d}`, i, full, i))
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, "POST", e.Endpoint, bytes.NewBuffer([]byte(fmt.Sprintf(`[%s]`, strings.Join(items, ",")))))
-	if err != nil {
-		log.Warn("Error creating request:", err)
-
-		return
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := client.Do(req)
+	body, err := e.rpcPost(ctx, []byte(fmt.Sprintf(`[%s]`, strings.Join(items, ","))))
 	if err != nil {
 		conf.SetBlockFail(e.Network)
 		e.blockScanQueue.In <- b
-		log.Warn("eth_getBlockByNumber Error sending request:", err)
+		log.Warn(e.Network, "eth_getBlockByNumber Error:", err)
 
 		return
 	}
 
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		conf.SetBlockFail(e.Network)
-		e.blockScanQueue.In <- b
-		log.Warn("eth_getBlockByNumber Error reading response body:", err)
-
-		return
-	}
 	e.debugPrintln(`getBlockByNumber`, body)
 	timestamp := make(map[string]time.Time)
+	hashes := make([]model.BlockHash, 0)
+	natives := make([]transfer, 0)
 	for _, itm := range gjson.ParseBytes(body).Array() {
-		if itm.Get("error").Exists() {
+		if !itm.Get("result").IsObject() { // 节点区块高度落后，区块尚不存在
 			conf.SetBlockFail(e.Network)
 			e.blockScanQueue.In <- b
-			log.Warnf("%s eth_getBlockByNumber response error %s, header:\n%s\n", e.Network, itm.Get("error").String(), help.AsStringer(resp.Header, help.StringifyMethodList))
+			log.Warn(e.Network, "eth_getBlockByNumber Error: block not found", itm.Get("id").Int())
 
 			return
 		}
 
 		timestamp[itm.Get("result.number").String()] = time.Unix(help.HexStr2Int(itm.Get("result.timestamp").String()).Int64(), 0)
+		hashes = append(hashes, model.BlockHash{
+			Network:    e.Network,
+			Number:     help.HexStr2Int(itm.Get("result.number").String()).Int64(),
+			Hash:       itm.Get("result.hash").String(),
+			ParentHash: itm.Get("result.parentHash").String(),
+		})
+
+		if full {
+			natives = append(natives, e.parseNativeTransfer(itm.Get("result"))...)
+		}
+	}
+
+	if err := e.reorgCheck(hashes); err != nil {
+		conf.SetBlockFail(e.Network)
+		e.blockScanQueue.In <- b
+		log.Warn(e.Network, "reorgCheck Error:", err)
+
+		return
 	}
 
 	transfers, err := e.parseBlockTransfer(b, timestamp)
@@ -284,40 +304,32 @@ func (e *evm) getBlockByNumber(a any) {
 		return
 	}
 
+	transfers = append(transfers, natives...)
 	if len(transfers) >= 0 {
 		e.debugPrintln(`transfers`, transfers)
 		transferQueue.In <- transfers
 	}
 
+	getScanCursor(e.Network).done(b.From)
 	log.Debug("区块扫描完成", b, conf.GetBlockSuccRate(e.Network), e.Network)
 }
 
 func (e *evm) parseBlockTransfer(b evmBlock, timestamp map[string]time.Time) ([]transfer, error) {
 	transfers := make([]transfer, 0)
 	post := []byte(fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_getLogs","params":[{"fromBlock":"0x%x","toBlock":"0x%x","topics":["%s"]}],"id":1}`, b.From, b.To, evmTransferEvent))
-	resp, err := client.Post(e.Endpoint, "application/json", bytes.NewBuffer(post))
-	if err != nil {
-
-		return transfers, errors.Join(errors.New("eth_getLogs Post Error"), err)
-	}
-
-	defer resp.Body.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := e.rpcPost(ctx, post)
 	if err != nil {
 
-		return transfers, errors.Join(errors.New("eth_getLogs ReadAll Error"), err)
+		return transfers, errors.Join(errors.New("eth_getLogs error"), err)
 	}
 
 	data := gjson.ParseBytes(body)
-	if data.Get("error").Exists() {
-
-		return transfers, fmt.Errorf("%s eth_getLogs response error %s", e.Network, data.Get("error").String())
-	}
-
 	for _, itm := range data.Get("result").Array() {
 		to := itm.Get("address").String()
-		tradeType, ok := contractMap[to]
+		tradeType, exp, ok := e.contractToken(to)
 		if !ok {
 
 			continue
@@ -353,9 +365,10 @@ func (e *evm) parseBlockTransfer(b evmBlock, timestamp map[string]time.Time) ([]
 			Network:     e.Network,
 			FromAddress: from,
 			RecvAddress: recv,
-			Amount:      decimal.NewFromBigInt(amount, decimals[to]),
+			Amount:      decimal.NewFromBigInt(amount, exp),
 			TxHash:      itm.Get("transactionHash").String(),
 			BlockNum:    blockNum,
+			LogIndex:    help.HexStr2Int(itm.Get("logIndex").String()).Int64(),
 			Timestamp:   timestamp[itm.Get("blockNumber").String()],
 			TradeType:   tradeType,
 		})
@@ -364,46 +377,92 @@ func (e *evm) parseBlockTransfer(b evmBlock, timestamp map[string]time.Time) ([]
 	return transfers, nil
 }
 
+// parseNativeTransfer 解析区块内的原生币转账，仅识别普通转账，合约调用附带的转账及内部交易不做处理
+func (e *evm) parseNativeTransfer(block gjson.Result) []transfer {
+	var transfers = make([]transfer, 0)
+	var tradeType, ok = nativeTradeTypeMap[e.Network]
+	if !ok {
+
+		return transfers
+	}
+
+	var num = help.HexStr2Int(block.Get("number").String()).Int64()
+	var timestamp = time.Unix(help.HexStr2Int(block.Get("timestamp").String()).Int64(), 0)
+	for _, tx := range block.Get("transactions").Array() {
+		if tx.Get("input").String() != "0x" || tx.Get("to").String() == "" {
+
+			continue
+		}
+
+		value := help.HexStr2Int(tx.Get("value").String())
+		if value.Sign() <= 0 {
+
+			continue
+		}
+
+		transfers = append(transfers, transfer{
+			Network:     e.Network,
+			FromAddress: strings.ToLower(tx.Get("from").String()),
+			RecvAddress: strings.ToLower(tx.Get("to").String()),
+			Amount:      decimal.NewFromBigInt(value, conf.EvmNativeDecimals),
+			TxHash:      tx.Get("hash").String(),
+			BlockNum:    num,
+			Timestamp:   timestamp,
+			TradeType:   tradeType,
+		})
+	}
+
+	return transfers
+}
+
+// contractToken 根据合约地址获取交易类型及精度，通用 EVM 网络只识别其配置的代币
+func (e *evm) contractToken(contract string) (string, int32, bool) {
+	if e.tokens != nil {
+		t, ok := e.tokens[contract]
+
+		return t.TradeType, -t.Decimals, ok
+	}
+
+	tradeType, ok := contractMap[contract]
+
+	return tradeType, decimals[contract], ok
+}
+
 func (e *evm) tradeConfirmHandle(ctx context.Context) {
 	var orders = getConfirmingOrders(networkTokenMap[e.Network])
 	var wg sync.WaitGroup
+	if len(orders) == 0 {
 
-	var handle = func(o model.TradeOrders) {
-		post := []byte(fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_getTransactionReceipt","params":["%s"],"id":1}`, o.TradeHash))
-		req, err := http.NewRequestWithContext(ctx, "POST", e.Endpoint, bytes.NewBuffer(post))
-		if err != nil {
-			log.Warn("evm tradeConfirmHandle Error creating request:", err)
+		return
+	}
 
-			return
-		}
+	latest, err := e.blockNumber(ctx)
+	if err != nil {
+		log.Warn(e.Network, "tradeConfirmHandle Error:", err)
 
-		req.Header.Set("Content-Type", "application/json")
-		resp, err := client.Do(req)
+		return
+	}
+
+	var handle = func(o model.TradeOrders) {
+		receipt, err := e.getTransactionReceipt(ctx, o.TradeHash)
 		if err != nil {
-			log.Warn("evm tradeConfirmHandle Error sending request:", err)
+			log.Warn(e.Network, "tradeConfirmHandle Error:", err)
 
 			return
 		}
 
-		defer resp.Body.Close()
-
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.Warn("evm tradeConfirmHandle Error reading response body:", err)
+		if receipt.Get("status").String() != "0x1" {
 
 			return
 		}
 
-		data := gjson.ParseBytes(body)
-		if data.Get("error").Exists() {
-			log.Warn(fmt.Sprintf("%s eth_getTransactionReceipt response error %s", e.Network, data.Get("error").String()))
+		// 确认数不足，等待足够的区块深度，防止区块重组导致交易消失
+		if latest-help.HexStr2Int(receipt.Get("blockNumber").String()).Int64() < e.Block.ConfirmedOffset {
 
 			return
 		}
 
-		if data.Get("result.status").String() == "0x1" {
-			markFinalConfirmed(o)
-		}
+		markFinalConfirmed(o)
 	}
 
 	for _, order := range orders {
@@ -417,8 +476,191 @@ func (e *evm) tradeConfirmHandle(ctx context.Context) {
 	wg.Wait()
 }
 
+// getTransactionReceipt 获取交易回执，交易不存在(未打包或已被重组移除)时返回的 result 为 null
+func (e *evm) getTransactionReceipt(ctx context.Context, hash string) (gjson.Result, error) {
+	post := []byte(fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_getTransactionReceipt","params":["%s"],"id":1}`, hash))
+	body, err := e.rpcPost(ctx, post)
+	if err != nil {
+
+		return gjson.Result{}, errors.Join(errors.New("eth_getTransactionReceipt error"), err)
+	}
+
+	return gjson.GetBytes(body, "result"), nil
+}
+
+// getBlockHashes 批量获取区间 [from, to] 内主链上的区块哈希
+func (e *evm) getBlockHashes(ctx context.Context, from, to int64) (map[int64]model.BlockHash, error) {
+	var data = make(map[int64]model.BlockHash)
+	for start := from; start <= to; start += blockParseMaxNum {
+		items := make([]string, 0)
+		for i := start; i <= min(start+blockParseMaxNum-1, to); i++ {
+			items = append(items, fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["0x%x",false],"id":%d}`, i, i))
+		}
+
+		body, err := e.rpcPost(ctx, []byte(fmt.Sprintf(`[%s]`, strings.Join(items, ","))))
+		if err != nil {
+
+			return nil, errors.Join(errors.New("eth_getBlockByNumber error"), err)
+		}
+
+		for _, itm := range gjson.ParseBytes(body).Array() {
+			if !itm.Get("result").IsObject() {
+
+				return nil, fmt.Errorf("eth_getBlockByNumber block %d not found", itm.Get("id").Int())
+			}
+
+			num := help.HexStr2Int(itm.Get("result.number").String()).Int64()
+			data[num] = model.BlockHash{
+				Network:    e.Network,
+				Number:     num,
+				Hash:       itm.Get("result.hash").String(),
+				ParentHash: itm.Get("result.parentHash").String(),
+			}
+		}
+	}
+
+	return data, nil
+}
+
+// reorgCheck 将本批区块的父哈希与已记录的区块哈希比对，不一致说明发生了区块重组
+func (e *evm) reorgCheck(blocks []model.BlockHash) error {
+	if unitTestMode || len(blocks) == 0 {
+
+		return nil
+	}
+
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].Number < blocks[j].Number
+	})
+
+	var fork int64
+	var known = model.GetBlockHashes(e.Network, blocks[0].Number-1, blocks[len(blocks)-1].Number)
+	for _, b := range blocks {
+		if fork == 0 {
+			if hash, ok := known[b.Number-1]; ok && hash != b.ParentHash {
+				fork = b.Number - 1
+			} else if hash, ok := known[b.Number]; ok && hash != b.Hash {
+				fork = b.Number
+			}
+		}
+
+		known[b.Number] = b.Hash
+	}
+
+	if fork > 0 {
+		if err := e.reorgHandle(fork); err != nil {
+
+			return err
+		}
+	}
+
+	return model.SaveBlockHashes(e.Network, blocks)
+}
+
+// reorgHandle 从分叉高度向下回溯找出被替换的区块，重新扫描这些区块并回滚受影响的订单
+func (e *evm) reorgHandle(fork int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	var low = max(fork-evmReorgMaxDepth, 1)
+	var stored = model.GetBlockHashes(e.Network, low, fork)
+	canonical, err := e.getBlockHashes(ctx, low, fork)
+	if err != nil {
+
+		return err
+	}
+
+	var start = fork
+	for n := fork; n >= low; n-- {
+		hash, ok := stored[n]
+		if ok && hash == canonical[n].Hash {
+
+			break
+		}
+
+		start = n
+	}
+
+	log.Warn(fmt.Sprintf("%s 检测到区块重组，区块 %d - %d 已被替换，重新扫描", e.Network, start, fork))
+
+	var rows = make([]model.BlockHash, 0)
+	for n := start; n <= fork; n++ {
+		rows = append(rows, canonical[n])
+	}
+
+	if err := model.SaveBlockHashes(e.Network, rows); err != nil {
+
+		return err
+	}
+
+	for from := start; from <= fork; from += blockParseMaxNum {
+		e.blockScanQueue.In <- evmBlock{From: from, To: min(from+blockParseMaxNum-1, fork)}
+	}
+
+	e.reorgRollback(ctx, start, fork)
+
+	return nil
+}
+
+// reorgRollback 被重组区块中的确认中订单，若交易已不在主链上则回滚至等待支付
+func (e *evm) reorgRollback(ctx context.Context, from, to int64) {
+	var orders []model.TradeOrders
+	model.DB.Where("status = ? and trade_type in (?) and ref_block_num >= ? and ref_block_num <= ?",
+		model.OrderStatusConfirming, networkTokenMap[e.Network], from, to).Find(&orders)
+
+	for _, o := range orders {
+		receipt, err := e.getTransactionReceipt(ctx, o.TradeHash)
+		if err != nil {
+			log.Warn(e.Network, "reorgRollback Error:", err)
+
+			continue
+		}
+
+		if receipt.Exists() && receipt.Type != gjson.Null {
+			// 交易被重新打包进新的区块，继续等待确认
+			if err := o.SetRefBlockNum(help.HexStr2Int(receipt.Get("blockNumber").String()).Int64()); err != nil {
+				log.Warn(e.Network, "reorgRollback Error:", err)
+			}
+
+			continue
+		}
+
+		var hash = o.TradeHash
+		if err := o.RollbackWaiting(); err != nil {
+			log.Warn(e.Network, "reorgRollback Error:", err)
+
+			continue
+		}
+
+		log.Warn(fmt.Sprintf("%s 区块重组，订单 %s 交易 %s 已不在主链，回滚至等待支付", e.Network, o.TradeId, hash))
+	}
+}
+
 var unitTestMode bool
 
+// nativeWatch 网络原生币存在等待支付订单、检测时限内的过期订单或开启了非订单监控的地址时，才需要解析区块内的原生币转账
+func nativeWatch(network string) bool {
+	tradeType, ok := nativeTradeTypeMap[network]
+	if !ok || unitTestMode {
+
+		return false
+	}
+
+	var count int64 = 0
+	model.DB.Model(&model.TradeOrders{}).Where("trade_type = ?", tradeType).
+		Where(model.DB.Where("status in (?)", model.OrderOpenStatus).
+			Or("status = ? and trade_hash = trade_id and expired_at > ?", model.OrderStatusExpired, time.Now().Add(-conf.GetLatePaidWindow()))).
+		Count(&count)
+	if count > 0 {
+
+		return true
+	}
+
+	model.DB.Model(&model.WalletAddress{}).Where("other_notify = ? and trade_type = ?", model.OtherNotifyEnable, tradeType).Count(&count)
+
+	return count > 0
+}
+
 func rollBreak(network string) bool {
 	if unitTestMode {
 		return false
@@ -430,7 +672,7 @@ func rollBreak(network string) bool {
 	}
 
 	var count int64 = 0
-	model.DB.Model(&model.TradeOrders{}).Where("status = ? and trade_type in (?)", model.OrderStatusWaiting, token).Count(&count)
+	model.DB.Model(&model.TradeOrders{}).Where("status in (?) and trade_type in (?)", model.OrderOpenStatus, token).Count(&count)
 	if count > 0 {
 
 		return false
@@ -0,0 +1,182 @@
+package task
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/tidwall/gjson"
+	"github.com/v03413/bepusdt/app/conf"
+	"github.com/v03413/bepusdt/app/help"
+	"github.com/v03413/bepusdt/app/model"
+	"github.com/v03413/bepusdt/app/model/modeltest"
+)
+
+// 区块 101 及之后被替换，已确认中的订单交易若不在主链上则回滚
+func TestReorgRollback(t *testing.T) {
+	var cases = []struct {
+		name     string
+		receipt  string
+		payments []model.TradePayment
+		status   int
+		paid     string
+		refBlock int64
+	}{
+		{"transaction dropped", "null", []model.TradePayment{{TxHash: "0xtx", Amount: "10"}}, model.OrderStatusWaiting, "0", 0},
+		{"partial payments kept", "null", []model.TradePayment{{TxHash: "0xtx0", Amount: "4"}, {TxHash: "0xtx", Amount: "6"}}, model.OrderStatusPartial, "4", 0},
+		{"transaction included again", `{"blockNumber":"0x67","status":"0x1"}`, []model.TradePayment{{TxHash: "0xtx", Amount: "10"}}, model.OrderStatusConfirming, "10", 103},
+	}
+
+	defer func(mode bool) { unitTestMode = mode }(unitTestMode)
+	unitTestMode = false
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			modeltest.Setup(t)
+
+			var hash = func(n int64) string {
+				if n >= 101 {
+
+					return fmt.Sprintf("0xb%d", n)
+				}
+
+				return fmt.Sprintf("0xa%d", n)
+			}
+
+			var rpc = newTestRpc(t, func(method string, params []gjson.Result) string {
+				switch method {
+				case "eth_getBlockByNumber":
+					var n = help.HexStr2Int(params[0].String()).Int64()
+
+					return fmt.Sprintf(`{"number":"0x%x","hash":"%s","parentHash":"%s"}`, n, hash(n), hash(n-1))
+				case "eth_getTransactionReceipt":
+
+					return c.receipt
+				}
+
+				return "null"
+			})
+
+			var chain = setupTestChain(t, rpc)
+			var stored = make([]model.BlockHash, 0)
+			for n := int64(100); n <= 102; n++ {
+				stored = append(stored, model.BlockHash{Network: chain.Network, Number: n, Hash: fmt.Sprintf("0xa%d", n), ParentHash: fmt.Sprintf("0xa%d", n-1)})
+			}
+			if err := model.SaveBlockHashes(chain.Network, stored); err != nil {
+				t.Fatal(err)
+			}
+
+			var o = model.TradeOrders{OrderId: "o1", TradeId: "t1", TradeHash: "0xtx", TradeType: "usdt.testnet", Amount: "10", PaidAmount: "10",
+				Address: "0xaddr", Status: model.OrderStatusConfirming, RefBlockNum: 101, ExpiredAt: time.Now().Add(-time.Minute)}
+			if err := model.DB.Create(&o).Error; err != nil {
+				t.Fatal(err)
+			}
+			for _, p := range c.payments {
+				p.TradeId, p.PaidAt = "t1", time.Now()
+				model.DB.Create(&p)
+			}
+
+			// 新区块的父哈希与已记录的区块 102 不一致
+			if err := chain.reorgCheck([]model.BlockHash{{Network: chain.Network, Number: 103, Hash: hash(103), ParentHash: hash(102)}}); err != nil {
+				t.Fatal(err)
+			}
+
+			saved, _ := model.GetTradeOrder("t1")
+			if saved.Status != c.status || saved.PaidAmount != c.paid || saved.RefBlockNum != c.refBlock {
+				t.Fatalf("unexpected order status %s paid %s block %d", model.OrderStatusName(saved.Status), saved.PaidAmount, saved.RefBlockNum)
+			}
+
+			var count int64
+			model.DB.Model(&model.TradePayment{}).Where("trade_id = ? and tx_hash = ?", "t1", "0xtx").Count(&count)
+			if (count == 0) != (c.status != model.OrderStatusConfirming) {
+				t.Fatalf("unexpected payment rows %d", count)
+			}
+
+			// 被替换的区块重新入列扫描，记录的区块哈希更新为主链哈希
+			if known := model.GetBlockHashes(chain.Network, 100, 103); known[100] != hash(100) || known[101] != hash(101) || known[103] != hash(103) {
+				t.Fatalf("unexpected block hashes %v", known)
+			}
+			if n := <-chain.blockScanQueue.Out; n.From != 101 || n.To != 102 {
+				t.Fatalf("unexpected rescan range %+v", n)
+			}
+		})
+	}
+}
+
+// 过期订单在检测时限内仍可能收到支付，需要继续解析原生币转账
+func TestNativeWatch(t *testing.T) {
+	var cases = []struct {
+		name  string
+		order *model.TradeOrders
+		want  bool
+	}{
+		{"no orders", nil, false},
+		{"waiting order", &model.TradeOrders{Status: model.OrderStatusWaiting, ExpiredAt: time.Now().Add(time.Minute)}, true},
+		{"recently expired order", &model.TradeOrders{Status: model.OrderStatusExpired, ExpiredAt: time.Now().Add(-time.Hour)}, true},
+		{"expired beyond late paid window", &model.TradeOrders{Status: model.OrderStatusExpired, ExpiredAt: time.Now().Add(-time.Hour * 48)}, false},
+		{"expired order already matched", &model.TradeOrders{Status: model.OrderStatusExpired, TradeHash: "0xpaid", ExpiredAt: time.Now().Add(-time.Hour)}, false},
+		{"other trade type", &model.TradeOrders{Status: model.OrderStatusWaiting, TradeType: model.OrderTradeTypeUsdtErc20, ExpiredAt: time.Now().Add(time.Minute)}, false},
+	}
+
+	defer func(mode bool) { unitTestMode = mode }(unitTestMode)
+	unitTestMode = false
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			modeltest.Setup(t)
+			if c.order != nil {
+				var o = *c.order
+				o.TradeId, o.OrderId, o.Amount, o.Address = "t1", "o1", "0.01", "0xaddr"
+				if o.TradeType == "" {
+					o.TradeType = model.OrderTradeTypeEthErc20
+				}
+				if o.TradeHash == "" {
+					o.TradeHash = o.TradeId
+				}
+				model.DB.Create(&o)
+			}
+
+			if got := nativeWatch(conf.Ethereum); got != c.want {
+				t.Fatalf("nativeWatch = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseNativeTransfer(t *testing.T) {
+	var block = gjson.Parse(`{"number":"0x64","timestamp":"0x65f00000","transactions":[
+		{"hash":"0x01","from":"0xFROM","to":"0xRECV","value":"0xde0b6b3a7640000","input":"0x"},
+		{"hash":"0x02","from":"0xfrom","to":"0xrecv","value":"0xde0b6b3a7640000","input":"0xa9059cbb"},
+		{"hash":"0x03","from":"0xfrom","to":"0xrecv","value":"0x0","input":"0x"},
+		{"hash":"0x04","from":"0xfrom","to":null,"value":"0xde0b6b3a7640000","input":"0x"}
+	]}`)
+
+	var cases = []struct {
+		name    string
+		network string
+		hashes  []string
+	}{
+		{"plain transfers only", conf.Ethereum, []string{"0x01"}},
+		{"network without native token", "unknown", []string{}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var e = evm{Network: c.network}
+			var transfers = e.parseNativeTransfer(block)
+			if len(transfers) != len(c.hashes) {
+				t.Fatalf("got %d transfers, want %v", len(transfers), c.hashes)
+			}
+
+			for i, tr := range transfers {
+				if tr.TxHash != c.hashes[i] || tr.RecvAddress != "0xrecv" || tr.FromAddress != "0xfrom" {
+					t.Fatalf("unexpected transfer %+v", tr)
+				}
+				if !tr.Amount.Equal(decimal.NewFromInt(1)) || tr.BlockNum != 100 || tr.TradeType != model.OrderTradeTypeEthErc20 {
+					t.Fatalf("unexpected transfer amount %s block %d type %s", tr.Amount, tr.BlockNum, tr.TradeType)
+				}
+			}
+		})
+	}
+}
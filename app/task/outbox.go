@@ -0,0 +1,138 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	bot2 "github.com/v03413/bepusdt/app/bot"
+	"github.com/v03413/bepusdt/app/log"
+	"github.com/v03413/bepusdt/app/model"
+	"github.com/v03413/bepusdt/app/web/notify"
+)
+
+func init() {
+	register(task{duration: time.Second * 3, callback: outboxRoll})
+}
+
+const outboxConcurrency = 8 // 同时投递的目标数量上限
+
+// outboxRoll 投递发件箱中到期的通知，失败的按退避时间重试，进程重启后继续投递；
+// 不同目标并发投递，同一目标按写入顺序依次投递，避免单个目标超时阻塞其它通知
+func outboxRoll(context.Context) {
+	var keys = make([]string, 0)
+	var groups = make(map[string][]model.Outbox)
+	for _, b := range model.GetDueOutboxes(100) {
+		var o, err = b.GetOrder()
+		if err != nil {
+			outboxFail(b, err)
+
+			continue
+		}
+
+		var key = b.Destination(o)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+
+		groups[key] = append(groups[key], b)
+	}
+
+	var wg sync.WaitGroup
+	var sem = make(chan struct{}, outboxConcurrency)
+	for _, key := range keys {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(rows []model.Outbox) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			for _, b := range rows {
+				outboxDeliver(b)
+			}
+		}(groups[key])
+	}
+
+	wg.Wait()
+}
+
+func outboxDeliver(b model.Outbox) {
+	if err := outboxHandle(b); err != nil {
+		outboxFail(b, err)
+
+		return
+	}
+
+	b.SetSucc()
+}
+
+func outboxFail(b model.Outbox, err error) {
+	log.Warn("通知投递失败：", b.Kind, b.Event, b.TradeId, err)
+	b.SetFail(err.Error())
+}
+
+func outboxHandle(b model.Outbox) error {
+	var o, err = b.GetOrder()
+	if err != nil {
+
+		return err
+	}
+
+	switch b.Kind {
+	case model.OutboxKindWebhook:
+
+		return webhookPost(model.GetMerchant(o.MerchantId).WebhookUrl, b.Event, b.Snapshot)
+	case model.OutboxKindNotify:
+		if o.Status != model.OrderStatusSuccess {
+
+			return notify.BepusdtSync(o)
+		}
+
+		// 回调失败由发件箱按退避时间重试，已手动标记回调成功的订单不再回调
+		cur, ok := model.GetTradeOrder(o.TradeId)
+		if !ok || cur.NotifyState == model.OrderNotifyStateSucc {
+
+			return nil
+		}
+
+		return notify.Handle(cur)
+	case model.OutboxKindBot:
+
+		return outboxBotHandle(b, o)
+	}
+
+	return fmt.Errorf("未知的通知类型：%s", b.Kind)
+}
+
+func outboxBotHandle(b model.Outbox, o model.TradeOrders) error {
+	switch b.Event {
+	case model.BotMsgTradeSucc:
+
+		return bot2.SendTradeSuccMsg(o)
+	case model.BotMsgLatePaid:
+
+		return bot2.SendLatePaidMsg(o)
+	case model.BotMsgRollback:
+		var args model.RollbackArgs
+		if err := json.Unmarshal(b.Args, &args); err != nil {
+
+			return err
+		}
+
+		return bot2.SendOrderRollbackMsg(o, args.Hash, args.BlockNum)
+	case model.BotMsgNotifyFail:
+		var args model.NotifyFailArgs
+		if err := json.Unmarshal(b.Args, &args); err != nil {
+
+			return err
+		}
+
+		return bot2.SendNotifyFailed(o, args.Reason)
+	}
+
+	return fmt.Errorf("未知的机器人消息：%s", b.Event)
+}
@@ -0,0 +1,235 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/v03413/bepusdt/app/model"
+	"github.com/v03413/bepusdt/app/model/modeltest"
+)
+
+func TestOutboxRollNotify(t *testing.T) {
+	var cases = []struct {
+		name     string
+		status   int
+		body     string
+		marked   bool
+		hits     int32
+		outbox   int8
+		num      int
+		notified int
+		alerts   int64
+	}{
+		{"delivered", http.StatusOK, "ok", false, 1, model.OutboxStatusSucc, 1, model.OrderNotifyStateSucc, 0},
+		{"failed and retried by outbox", http.StatusInternalServerError, "", false, 1, model.OutboxStatusWait, 1, model.OrderNotifyStateFail, 1},
+		{"unexpected body", http.StatusOK, "fail", false, 1, model.OutboxStatusWait, 1, model.OrderNotifyStateFail, 1},
+		{"manually marked as notified", http.StatusOK, "ok", true, 0, model.OutboxStatusSucc, 1, model.OrderNotifyStateSucc, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			modeltest.Setup(t)
+
+			var hits atomic.Int32
+			var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				w.WriteHeader(c.status)
+				_, _ = w.Write([]byte(c.body))
+			}))
+			defer srv.Close()
+
+			var o = createOutboxOrder(t, "t1", srv.URL, 0)
+			if err := o.Transition(model.OrderStatusSuccess, model.ActorScanner, "test", model.NotifyEffect()); err != nil {
+				t.Fatal(err)
+			}
+			if c.marked {
+				model.DB.Model(&model.TradeOrders{}).Where("trade_id = ?", "t1").Update("notify_state", model.OrderNotifyStateSucc)
+			}
+
+			outboxRoll(context.Background())
+
+			// 回调只由发件箱投递，失败后按退避时间重试，不会在同一轮被重复投递
+			if n := hits.Load(); n != c.hits {
+				t.Fatalf("notify requests = %d, want %d", n, c.hits)
+			}
+
+			var b model.Outbox
+			model.DB.Where("trade_id = ?", "t1").First(&b)
+			if b.Status != c.outbox || b.Num != c.num {
+				t.Fatalf("unexpected outbox status %d num %d", b.Status, b.Num)
+			}
+			if c.outbox == model.OutboxStatusWait && !b.NextAt.After(time.Now()) {
+				t.Fatalf("failed outbox not delayed, next_at %s", b.NextAt)
+			}
+
+			if order, _ := model.GetTradeOrder("t1"); order.NotifyState != c.notified {
+				t.Fatalf("unexpected notify state %d", order.NotifyState)
+			}
+
+			// 回调失败的机器人提醒与回调状态在同一事务中写入发件箱
+			var alerts int64
+			model.DB.Model(&model.Outbox{}).Where("trade_id = ? and kind = ? and event = ?", "t1", model.OutboxKindBot, model.BotMsgNotifyFail).Count(&alerts)
+			if alerts != c.alerts {
+				t.Fatalf("notify failure alerts = %d, want %d", alerts, c.alerts)
+			}
+
+			outboxRoll(context.Background())
+			if n := hits.Load(); n != c.hits {
+				t.Fatalf("outbox delivered again before next_at, requests = %d", n)
+			}
+		})
+	}
+}
+
+func createOutboxOrder(t *testing.T, tradeId, notifyUrl string, merchantId int64) model.TradeOrders {
+	t.Helper()
+
+	var o = model.TradeOrders{
+		OrderId:    "o" + tradeId,
+		TradeId:    tradeId,
+		TradeHash:  "hash" + tradeId,
+		TradeType:  model.OrderTradeTypeUsdtTrc20,
+		Amount:     "10",
+		Address:    "TAddr",
+		ApiType:    model.OrderApiTypeEpusdt,
+		NotifyUrl:  notifyUrl,
+		MerchantId: merchantId,
+		Status:     model.OrderStatusConfirming,
+		ExpiredAt:  time.Now().Add(time.Minute),
+	}
+	if err := model.DB.Create(&o).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return o
+}
+
+func TestOutboxRollWebhook(t *testing.T) {
+	var cases = []struct {
+		name   string
+		status int
+		outbox int8
+		delay  time.Duration
+	}{
+		{"delivered", http.StatusOK, model.OutboxStatusSucc, 0},
+		{"failed and retried by outbox", http.StatusInternalServerError, model.OutboxStatusWait, model.NotifyRetryDelay(1)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			modeltest.Setup(t)
+
+			var posted = make(chan map[string]any, 1)
+			var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]any
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				posted <- body
+				w.WriteHeader(c.status)
+			}))
+			defer srv.Close()
+
+			var m = model.Merchant{Pid: "1001", Secret: "s1", WebhookUrl: srv.URL}
+			if err := model.DB.Create(&m).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			var o = createOutboxOrder(t, "t1", "", m.ID)
+			if err := o.Transition(model.OrderStatusSuccess, model.ActorScanner, "test", model.WebhookEffect(model.WebhookEventOrderPaid)); err != nil {
+				t.Fatal(err)
+			}
+
+			outboxRoll(context.Background())
+
+			// Webhook 由发件箱直接推送，失败后与商户回调相同按分钟间隔重试
+			select {
+			case body := <-posted:
+				var data, _ = body["data"].(map[string]any)
+				if body["event"] != model.WebhookEventOrderPaid || data["TradeId"] != "t1" {
+					t.Fatalf("unexpected webhook body %v", body)
+				}
+			default:
+				t.Fatal("webhook not delivered")
+			}
+
+			var b model.Outbox
+			model.DB.Where("trade_id = ? and kind = ?", "t1", model.OutboxKindWebhook).First(&b)
+			if b.Status != c.outbox || b.Num != 1 {
+				t.Fatalf("unexpected outbox status %d num %d", b.Status, b.Num)
+			}
+			if c.delay > 0 && b.NextAt.Before(time.Now().Add(c.delay-time.Minute)) {
+				t.Fatalf("failed webhook retried too early, next_at %s", b.NextAt)
+			}
+		})
+	}
+}
+
+// 不同目标并发投递，单个目标阻塞不影响其它目标；同一目标仍按写入顺序依次投递
+func TestOutboxRollConcurrent(t *testing.T) {
+	modeltest.Setup(t)
+
+	var fast = make(chan struct{})
+	var slow = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-fast:
+		case <-time.After(5 * time.Second):
+			w.WriteHeader(http.StatusGatewayTimeout)
+
+			return
+		}
+
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer slow.Close()
+
+	var once sync.Once
+	var inflight atomic.Int32
+	var overlapped atomic.Bool
+	var delivered = make(chan string, 2)
+	var shared = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if inflight.Add(1) > 1 {
+			overlapped.Store(true)
+		}
+		defer inflight.Add(-1)
+
+		var body struct {
+			TradeId string `json:"trade_id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		delivered <- body.TradeId
+		once.Do(func() { close(fast) })
+
+		time.Sleep(50 * time.Millisecond)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer shared.Close()
+
+	for _, o := range []model.TradeOrders{
+		createOutboxOrder(t, "t1", slow.URL, 0),
+		createOutboxOrder(t, "t2", shared.URL, 0),
+		createOutboxOrder(t, "t3", shared.URL, 0),
+	} {
+		if err := o.Transition(model.OrderStatusSuccess, model.ActorScanner, "test", model.NotifyEffect()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outboxRoll(context.Background())
+
+	var succ int64
+	model.DB.Model(&model.Outbox{}).Where("status = ?", model.OutboxStatusSucc).Count(&succ)
+	if succ != 3 {
+		t.Fatalf("delivered outboxes = %d, want 3", succ)
+	}
+	if overlapped.Load() {
+		t.Fatal("same destination delivered concurrently")
+	}
+	if first, second := <-delivered, <-delivered; first != "t2" || second != "t3" {
+		t.Fatalf("same destination delivered out of order: %s %s", first, second)
+	}
+}
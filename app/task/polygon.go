@@ -11,8 +11,7 @@ import (
 func polygonInit() {
 	ctx := context.Background()
 	pol := evm{
-		Network:  conf.Polygon,
-		Endpoint: conf.GetPolygonRpcEndpoint(),
+		Network: conf.Polygon,
 		Block: block{
 			InitStartOffset: -600,
 			ConfirmedOffset: 40,
@@ -0,0 +1,138 @@
+package task
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/v03413/bepusdt/app/conf"
+	"github.com/v03413/bepusdt/app/log"
+	"github.com/v03413/bepusdt/app/model"
+	"github.com/v03413/bepusdt/app/task/rate"
+)
+
+var rateCoins = []model.TokenType{model.TokenTypeETH, model.TokenTypeBNB, model.TokenTypePOL, model.TokenTypeOKB, model.TokenTypeSOL, model.TokenTypeAPT, model.TokenTypeBTC, model.TokenTypeLTC}
+
+func init() {
+	d := getExchangeRateUpdateInterval()
+	register(task{duration: d, callback: UsdtRateStart})
+	register(task{duration: d, callback: UsdcRateStart})
+	register(task{duration: d, callback: TrxRateStart})
+	for _, coin := range rateCoins {
+		register(task{duration: d, callback: func(ctx context.Context) { CoinRateStart(ctx, string(coin)) }})
+	}
+}
+
+// rateInit 法币汇率任务依赖配置文件，需在配置加载后注册
+func rateInit() {
+	d := getExchangeRateUpdateInterval()
+	for _, fiat := range conf.GetCurrencies() {
+		register(task{duration: d, callback: func(ctx context.Context) { UsdtFiatRateStart(ctx, fiat) }})
+	}
+
+	rateSeed()
+}
+
+// rateSeed 以历史记录中最近一次采纳的汇率恢复波动校验基准，避免重启后首个异常读数不经校验即被采纳
+func rateSeed() {
+	var pairs = [][2]string{{"USDT", rate.FiatCNY}, {"USDC", rate.FiatCNY}, {"TRX", rate.FiatCNY}}
+	for _, coin := range rateCoins {
+		pairs = append(pairs, [2]string{string(coin), rate.FiatCNY})
+	}
+	for _, fiat := range conf.GetCurrencies() {
+		pairs = append(pairs, [2]string{"USDT", fiat})
+	}
+
+	for _, p := range pairs {
+		if rows := model.GetRecentRates(p[0], p[1], 1); len(rows) > 0 {
+			rate.Seed(p[0], p[1], rows[0].Rate)
+		}
+	}
+}
+
+func getExchangeRateUpdateInterval() time.Duration {
+	var exchangeRateUpdateInterval time.Duration
+	v := os.Getenv(`BEPUSDT_EXCHANGE_RATE_UPDATE_INTERVAL`)
+	if len(v) > 0 {
+		var err error
+		exchangeRateUpdateInterval, err = time.ParseDuration(v)
+		if err != nil {
+			log.Errorf(`解析环境变量 BEPUSDT_EXCHANGE_RATE_UPDATE_INTERVAL 的值“%s”失败: %v`, v, err)
+		}
+	}
+	if exchangeRateUpdateInterval <= 0 {
+		exchangeRateUpdateInterval = time.Minute * 30
+	}
+	return exchangeRateUpdateInterval
+}
+
+// UsdtRateStart USDT_CNY 汇率监控
+func UsdtRateStart(ctx context.Context) {
+	if rawRate, ok := fetchRate(ctx, "USDT", rate.FiatCNY); ok {
+		rate.SetUsdtCnyRate(conf.GetUsdtRate(), rawRate)
+	}
+
+	log.Debug("当前 USDT_CNY 计算汇率：", rate.GetUsdtCalcRate())
+}
+
+// UsdcRateStart USDC_CNY 汇率监控
+func UsdcRateStart(ctx context.Context) {
+	if rawRate, ok := fetchRate(ctx, "USDC", rate.FiatCNY); ok {
+		rate.SetUsdcCnyRate(conf.GetUsdcRate(), rawRate)
+	}
+
+	log.Debug("当前 USDC_CNY 计算汇率：", rate.GetUsdcCalcRate())
+}
+
+// TrxRateStart TRX_CNY 汇率监控
+func TrxRateStart(ctx context.Context) {
+	if price, ok := fetchRate(ctx, "TRX", rate.FiatCNY); ok {
+		rate.SetTrxCnyRate(conf.GetTrxRate(), price)
+	}
+
+	log.Debug("当前 TRX_CNY 计算汇率：", rate.GetTrxCalcRate())
+}
+
+// CoinRateStart 原生币(ETH BNB SOL APT 等)_CNY 汇率监控
+func CoinRateStart(ctx context.Context, coin string) {
+	if price, ok := fetchRate(ctx, coin, rate.FiatCNY); ok {
+		rate.SetCoinCnyRate(coin, conf.GetCoinRate(coin), price)
+	}
+
+	log.Debug("当前 "+coin+"_CNY 计算汇率：", rate.GetCoinCalcRate(coin))
+}
+
+// UsdtFiatRateStart USDT_法币(USD EUR RUB 等) 汇率监控，用于非 CNY 计价订单的换算
+func UsdtFiatRateStart(ctx context.Context, fiat string) {
+	if rawRate, ok := fetchRate(ctx, "USDT", fiat); ok {
+		rate.SetUsdtFiatRate(fiat, rawRate)
+	}
+
+	log.Debug("当前 USDT_"+fiat+" 原始汇率：", rate.GetUsdtFiatRawRate(fiat))
+}
+
+// fetchRate 获取汇率并记录各来源报价，波动超出允许范围的汇率不予采纳
+func fetchRate(ctx context.Context, token, fiat string) (float64, bool) {
+	raw, quotes, err := rate.Fetch(ctx, token, fiat)
+	if err != nil {
+		log.Error(token+"_"+fiat+" 汇率获取失败", err)
+
+		return 0, false
+	}
+
+	var sources = make([]string, 0, len(quotes))
+	for _, q := range quotes {
+		sources = append(sources, q.Source)
+	}
+
+	if err = rate.Accept(token, fiat, raw, sources); err != nil {
+		log.Warn(err.Error())
+		model.SaveRateHistory(token, fiat, quotes, raw, false)
+
+		return 0, false
+	}
+
+	model.SaveRateHistory(token, fiat, quotes, raw, true)
+
+	return raw, true
+}
@@ -0,0 +1,83 @@
+package rate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/v03413/bepusdt/app/conf"
+)
+
+// binance 稳定币取 P2P 出售广告价格，其它代币以现货 代币/USDT 价格乘以 USDT 的 P2P 价格折算
+type binance struct{}
+
+func (binance) Name() string {
+
+	return conf.RateSourceBinance
+}
+
+func (b binance) Price(ctx context.Context, token, fiat string) (float64, error) {
+	if token == "USDT" || token == "USDC" {
+
+		return b.p2pPrice(ctx, token, fiat)
+	}
+
+	spot, err := b.spotPrice(ctx, token)
+	if err != nil {
+
+		return 0, err
+	}
+
+	usdt, err := b.p2pPrice(ctx, "USDT", fiat)
+	if err != nil {
+
+		return 0, err
+	}
+
+	return spot * usdt, nil
+}
+
+// p2pPrice P2P 出售广告前 5 条价格的中位数，避免单条广告异常报价
+func (binance) p2pPrice(ctx context.Context, asset, fiat string) (float64, error) {
+	var body = fmt.Sprintf(`{"asset":"%s","fiat":"%s","tradeType":"SELL","page":1,"rows":5,"payTypes":[],"publisherType":null}`, asset, fiat)
+
+	all, err := httpGet(ctx, "POST", "https://p2p.binance.com/bapi/c2c/v2/friendly/c2c/adv/search", strings.NewReader(body), map[string]string{
+		"Content-Type": "application/json",
+	})
+	if err != nil {
+
+		return 0, errors.New("binance " + err.Error())
+	}
+
+	var prices = make([]float64, 0)
+	for _, v := range gjson.GetBytes(all, "data.#.adv.price").Array() {
+		if p := v.Float(); p > 0 {
+			prices = append(prices, p)
+		}
+	}
+
+	if len(prices) == 0 {
+
+		return 0, errors.New("binance resp json data.adv.price not found")
+	}
+
+	return median(prices), nil
+}
+
+func (binance) spotPrice(ctx context.Context, coin string) (float64, error) {
+	all, err := httpGet(ctx, "GET", "https://api.binance.com/api/v3/ticker/price?symbol="+coin+"USDT", nil, nil)
+	if err != nil {
+
+		return 0, errors.New("binance " + err.Error())
+	}
+
+	var price = gjson.GetBytes(all, "price")
+	if !price.Exists() {
+
+		return 0, errors.New("binance resp json price not found")
+	}
+
+	return price.Float(), nil
+}
@@ -0,0 +1,57 @@
+package rate
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/v03413/bepusdt/app/conf"
+)
+
+// coingeckoIds 代币对应的 CoinGecko 资产ID
+var coingeckoIds = map[string]string{
+	"USDT": "tether",
+	"USDC": "usd-coin",
+	"TRX":  "tron",
+	"ETH":  "ethereum",
+	"BNB":  "binancecoin",
+	"POL":  "polygon-ecosystem-token",
+	"OKB":  "okb",
+	"SOL":  "solana",
+	"APT":  "aptos",
+	"BTC":  "bitcoin",
+	"LTC":  "litecoin",
+}
+
+// coingecko 现货价格接口 /simple/price，接口地址可替换为兼容的自建或付费接口
+type coingecko struct{}
+
+func (coingecko) Name() string {
+
+	return conf.RateSourceCoingecko
+}
+
+func (coingecko) Price(ctx context.Context, token, fiat string) (float64, error) {
+	var id, ok = coingeckoIds[token]
+	if !ok {
+
+		return 0, errors.New("coingecko unsupported token:" + token)
+	}
+
+	var api = strings.TrimRight(conf.GetCoingeckoApi(), "/") + "/simple/price?ids=" + id + "&vs_currencies=" + strings.ToLower(fiat)
+
+	all, err := httpGet(ctx, "GET", api, nil, map[string]string{"accept": "application/json"})
+	if err != nil {
+
+		return 0, errors.New("coingecko " + err.Error())
+	}
+
+	var price = gjson.GetBytes(all, id+"."+strings.ToLower(fiat))
+	if !price.Exists() {
+
+		return 0, errors.New("coingecko resp json price not found")
+	}
+
+	return price.Float(), nil
+}
@@ -0,0 +1,25 @@
+package rate
+
+import (
+	"context"
+	"errors"
+
+	"github.com/v03413/bepusdt/app/conf"
+)
+
+// fixed 配置文件中的固定价格，通常与其它来源一起配置作为兜底
+type fixed struct{}
+
+func (fixed) Name() string {
+
+	return conf.RateSourceFixed
+}
+
+func (fixed) Price(_ context.Context, token, fiat string) (float64, error) {
+	if v := conf.GetRateFixed(token, fiat); v > 0 {
+
+		return v, nil
+	}
+
+	return 0, errors.New("fixed price not configured")
+}
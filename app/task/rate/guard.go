@@ -0,0 +1,115 @@
+package rate
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/v03413/bepusdt/app/conf"
+)
+
+// Snapshot 最近一次被采纳的原始汇率
+type Snapshot struct {
+	Raw       float64   `json:"raw"`
+	Sources   []string  `json:"sources"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// rejected 被拒绝采纳的汇率读数及连续一致的次数
+type rejected struct {
+	Raw   float64
+	Count int
+}
+
+var snapshots sync.Map // TOKEN_FIAT => Snapshot
+var pendings sync.Map  // TOKEN_FIAT => rejected
+var baselines sync.Map // TOKEN_FIAT => float64 启动时由历史记录恢复的基准
+
+// Accept 校验新获取的汇率相对上次采纳值的波动，超出 max_deviation 时拒绝采纳，原汇率保持不变直至过期；
+// 被拒绝的读数连续多次彼此一致时视为行情已真实变化，予以采纳，避免基准永远停留在旧值
+func Accept(token, fiat string, raw float64, sources []string) error {
+	var key = snapshotKey(token, fiat)
+	if prev, ok := baseline(key); ok {
+		if prev > 0 && deviation(prev, raw) > conf.GetRateMaxDeviation() {
+			var p = rejected{Raw: raw, Count: 1}
+			if v, ok := pendings.Load(key); ok && deviation(v.(rejected).Raw, raw) <= conf.GetRateMaxDeviation() {
+				p.Count = v.(rejected).Count + 1
+			}
+
+			if p.Count < conf.RateDeviationConfirms {
+				pendings.Store(key, p)
+
+				return fmt.Errorf("%s 汇率波动异常：%v => %v，超出允许范围 %v，连续 %d/%d 次", key, prev, raw, conf.GetRateMaxDeviation(), p.Count, conf.RateDeviationConfirms)
+			}
+		}
+	}
+
+	pendings.Delete(key)
+	snapshots.Store(key, Snapshot{Raw: raw, Sources: sources, UpdatedAt: time.Now()})
+
+	return nil
+}
+
+// Seed 以重启前最近一次采纳的汇率作为波动校验基准，重启后的首个读数同样需要通过校验；
+// 基准仅用于校验，不视为已获取成功的汇率
+func Seed(token, fiat string, raw float64) {
+	if raw > 0 {
+		baselines.Store(snapshotKey(token, fiat), raw)
+	}
+}
+
+// baseline 波动校验基准，优先使用本次运行采纳的汇率
+func baseline(key string) (float64, bool) {
+	if v, ok := snapshots.Load(key); ok {
+
+		return v.(Snapshot).Raw, true
+	}
+	if v, ok := baselines.Load(key); ok {
+
+		return v.(float64), true
+	}
+
+	return 0, false
+}
+
+func deviation(base, val float64) float64 {
+	if base <= 0 {
+
+		return math.Inf(1)
+	}
+
+	return math.Abs(val-base) / base
+}
+
+// GetSnapshot 最近一次被采纳的汇率，尚未获取成功时返回 false
+func GetSnapshot(token, fiat string) (Snapshot, bool) {
+	if v, ok := snapshots.Load(snapshotKey(token, fiat)); ok {
+
+		return v.(Snapshot), true
+	}
+
+	return Snapshot{}, false
+}
+
+// CheckFresh 汇率尚未获取成功或超过 max_age 未更新时返回错误
+func CheckFresh(token, fiat string) error {
+	var s, ok = GetSnapshot(token, fiat)
+	if !ok {
+
+		return fmt.Errorf("%s 汇率尚未获取成功", snapshotKey(token, fiat))
+	}
+
+	if time.Since(s.UpdatedAt) > conf.GetRateMaxAge() {
+
+		return fmt.Errorf("%s 汇率已过期，最后更新于 %s", snapshotKey(token, fiat), s.UpdatedAt.Format(time.DateTime))
+	}
+
+	return nil
+}
+
+func snapshotKey(token, fiat string) string {
+
+	return strings.ToUpper(token) + "_" + strings.ToUpper(fiat)
+}
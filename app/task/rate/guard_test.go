@@ -0,0 +1,73 @@
+package rate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/v03413/bepusdt/app/conf"
+)
+
+func TestAccept(t *testing.T) {
+	var cases = []struct {
+		name     string
+		seed     float64
+		readings []float64
+		accepted []bool
+		want     float64
+	}{
+		{"first reading", 0, []float64{7.2}, []bool{true}, 7.2},
+		{"within deviation", 0, []float64{7.2, 7.5}, []bool{true, true}, 7.5},
+		{"single spike rejected", 0, []float64{7.2, 72, 7.3}, []bool{true, false, true}, 7.3},
+		{"consistent readings accepted", 0, []float64{7.2, 10, 10.1, 10.2}, []bool{true, false, false, true}, 10.2},
+		{"inconsistent spikes rejected", 0, []float64{7.2, 10, 72, 10, 72}, []bool{true, false, false, false, false}, 7.2},
+		{"spike count reset by normal reading", 0, []float64{7.2, 10, 10, 7.2, 10}, []bool{true, false, false, true, false}, 7.2},
+		{"first reading checked against seed", 7.2, []float64{72, 7.3}, []bool{false, true}, 7.3},
+		{"seed replaced by accepted reading", 7.2, []float64{7.5, 8}, []bool{true, true}, 8},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var token = "TEST" + c.name
+			defer snapshots.Delete(snapshotKey(token, FiatCNY))
+			defer pendings.Delete(snapshotKey(token, FiatCNY))
+			defer baselines.Delete(snapshotKey(token, FiatCNY))
+
+			Seed(token, FiatCNY, c.seed)
+
+			for i, raw := range c.readings {
+				if err := Accept(token, FiatCNY, raw, nil); (err == nil) != c.accepted[i] {
+					t.Fatalf("reading %d (%v): err = %v", i, raw, err)
+				}
+			}
+
+			if s, _ := GetSnapshot(token, FiatCNY); s.Raw != c.want {
+				t.Fatalf("accepted rate = %v, want %v", s.Raw, c.want)
+			}
+		})
+	}
+}
+
+func TestCheckFresh(t *testing.T) {
+	var cases = []struct {
+		name     string
+		snapshot *Snapshot
+		err      bool
+	}{
+		{"never fetched", nil, true},
+		{"fresh", &Snapshot{Raw: 7.2, UpdatedAt: time.Now()}, false},
+		{"stale", &Snapshot{Raw: 7.2, UpdatedAt: time.Now().Add(-conf.GetRateMaxAge() - time.Minute)}, true},
+	}
+
+	for _, c := range cases {
+		var token = "FRESH" + c.name
+		if c.snapshot != nil {
+			snapshots.Store(snapshotKey(token, FiatCNY), *c.snapshot)
+		}
+
+		if err := CheckFresh(token, FiatCNY); (err != nil) != c.err {
+			t.Errorf("%s: err = %v", c.name, err)
+		}
+
+		snapshots.Delete(snapshotKey(token, FiatCNY))
+	}
+}
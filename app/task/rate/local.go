@@ -0,0 +1,57 @@
+package rate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/v03413/bepusdt/app/conf"
+)
+
+// local 本地 JSON 文件或自建 HTTP 接口，内容格式：{"USDT": {"CNY": 7.2, "USD": 1}, "TRX": {"CNY": 2.1}}，键不区分大小写
+type local struct{}
+
+func (local) Name() string {
+
+	return conf.RateSourceLocal
+}
+
+func (local) Price(ctx context.Context, token, fiat string) (float64, error) {
+	var src = conf.GetRateLocal()
+	var data []byte
+	var err error
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		data, err = httpGet(ctx, "GET", src, nil, map[string]string{"accept": "application/json"})
+	} else {
+		data, err = os.ReadFile(src)
+	}
+
+	if err != nil {
+
+		return 0, errors.New("local " + err.Error())
+	}
+
+	var table map[string]map[string]float64
+	if err = json.Unmarshal(data, &table); err != nil {
+
+		return 0, errors.New("local json parse error:" + err.Error())
+	}
+
+	for t, prices := range table {
+		if !strings.EqualFold(t, token) {
+
+			continue
+		}
+
+		for f, price := range prices {
+			if strings.EqualFold(f, fiat) {
+
+				return price, nil
+			}
+		}
+	}
+
+	return 0, errors.New("local price not found")
+}
@@ -0,0 +1,89 @@
+package rate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"github.com/v03413/bepusdt/app/conf"
+)
+
+// okx 稳定币取 C2C快捷交易 出售价格，其它代币以现货 代币/USDT 价格乘以 USDT 的 C2C 价格折算
+type okx struct{}
+
+func (okx) Name() string {
+
+	return conf.RateSourceOkx
+}
+
+func (o okx) Price(ctx context.Context, token, fiat string) (float64, error) {
+	if token == "USDT" || token == "USDC" {
+
+		return o.c2cSellPrice(ctx, token, fiat)
+	}
+
+	spot, err := o.spotPrice(ctx, token)
+	if err != nil {
+
+		return 0, err
+	}
+
+	usdt, err := o.c2cSellPrice(ctx, "USDT", fiat)
+	if err != nil {
+
+		return 0, err
+	}
+
+	return spot * usdt, nil
+}
+
+// c2cSellPrice  Okx  C2C快捷交易 USDT出售 实时汇率
+func (okx) c2cSellPrice(ctx context.Context, crypto, fiat string) (float64, error) {
+	var t = strconv.Itoa(int(time.Now().Unix()))
+	var api = fmt.Sprintf("https://www.okx.com/v4/c2c/express/price?crypto=%s&fiat=%s&side=sell&t=%s", crypto, fiat, t)
+
+	all, err := httpGet(ctx, "GET", api, nil, nil)
+	if err != nil {
+
+		return 0, errors.New("okx " + err.Error())
+	}
+
+	result := gjson.ParseBytes(all)
+	if result.Get("error_code").Int() != 0 {
+
+		return 0, errors.New("json parse error:" + result.Get("error_message").String())
+	}
+
+	if !result.Get("data.price").Exists() {
+
+		return 0, errors.New("okx resp json data.price not found")
+	}
+
+	return result.Get("data.price").Float(), nil
+}
+
+// spotPrice 公开行情接口 代币/USDT 最新成交价 https://www.okx.com/docs-v5/zh/#public-data-rest-api-get-ticker
+func (okx) spotPrice(ctx context.Context, coin string) (float64, error) {
+	all, err := httpGet(ctx, "GET", "https://www.okx.com/api/v5/market/ticker?instId="+coin+"-USDT", nil, nil)
+	if err != nil {
+
+		return 0, errors.New("okx " + err.Error())
+	}
+
+	result := gjson.ParseBytes(all)
+	if result.Get("code").String() != "0" {
+
+		return 0, errors.New("okx resp error:" + result.Get("msg").String())
+	}
+
+	var last = result.Get("data.0.last")
+	if !last.Exists() {
+
+		return 0, errors.New("okx resp json data.last not found")
+	}
+
+	return last.Float(), nil
+}
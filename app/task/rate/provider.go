@@ -0,0 +1,137 @@
+package rate
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/v03413/bepusdt/app"
+	"github.com/v03413/bepusdt/app/conf"
+	"github.com/v03413/bepusdt/app/log"
+)
+
+// Provider 汇率来源，返回 1 个代币以指定法币计价的价格；token 及 fiat 均为大写，如 USDT CNY
+type Provider interface {
+	Name() string
+	Price(ctx context.Context, token, fiat string) (float64, error)
+}
+
+var providers = make(map[string]Provider)
+
+func init() {
+	for _, p := range []Provider{okx{}, binance{}, coingecko{}, fixed{}, local{}} {
+		providers[p.Name()] = p
+	}
+}
+
+// Quote 单个汇率来源的报价
+type Quote struct {
+	Source string
+	Price  float64
+}
+
+// Fetch 并发请求代币配置的所有汇率来源，取成功结果的中位数，同时返回各来源的报价
+func Fetch(ctx context.Context, token, fiat string) (float64, []Quote, error) {
+	token, fiat = strings.ToUpper(token), strings.ToUpper(fiat)
+
+	var names = conf.GetRateSources(token)
+	var quotes = make([]Quote, 0, len(names))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range names {
+		p, ok := providers[name]
+		if !ok {
+			log.Warn("汇率来源不存在：", name)
+
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			price, err := p.Price(ctx, token, fiat)
+			if err == nil && price <= 0 {
+				err = errors.New("price <= 0")
+			}
+			if err != nil {
+				log.Warn(fmt.Sprintf("汇率来源 %s %s_%s 获取失败：%v", name, token, fiat, err))
+
+				return
+			}
+
+			mu.Lock()
+			quotes = append(quotes, Quote{Source: name, Price: price})
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+	if len(quotes) == 0 {
+
+		return 0, quotes, fmt.Errorf("%s_%s 所有汇率来源均获取失败：%s", token, fiat, strings.Join(names, ","))
+	}
+
+	var prices = make([]float64, 0, len(quotes))
+	for _, q := range quotes {
+		prices = append(prices, q.Price)
+	}
+
+	return median(prices), quotes, nil
+}
+
+func median(list []float64) float64 {
+	sort.Float64s(list)
+
+	var n = len(list)
+	if n%2 == 1 {
+
+		return list[n/2]
+	}
+
+	return (list[n/2-1] + list[n/2]) / 2
+}
+
+// httpGet 汇率来源通用请求，非 200 状态码视为失败
+func httpGet(ctx context.Context, method, url string, body io.Reader, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+
+		return nil, fmt.Errorf("creating request error: %v", err)
+	}
+
+	req.Header.Set("User-Agent", "BEpusdt/"+app.Version)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	var c = &http.Client{Timeout: time.Second * 30, Transport: &http.Transport{TLSClientConfig: &tls.Config{NextProtos: []string{"http/1.1"}}}}
+
+	resp, err := c.Do(req)
+	if err != nil {
+
+		return nil, errors.New("resp error:" + err.Error())
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+
+		return nil, errors.New("resp status code:" + strconv.Itoa(resp.StatusCode))
+	}
+
+	all, err := io.ReadAll(resp.Body)
+	if err != nil {
+
+		return nil, errors.New("resp read error:" + err.Error())
+	}
+
+	return all, nil
+}
@@ -0,0 +1,78 @@
+package rate
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/v03413/bepusdt/app/conf"
+	"github.com/v03413/bepusdt/app/log"
+)
+
+type stubProvider struct {
+	price float64
+	err   error
+}
+
+func (stubProvider) Name() string {
+
+	return "stub"
+}
+
+func (s stubProvider) Price(context.Context, string, string) (float64, error) {
+
+	return s.price, s.err
+}
+
+func TestMedian(t *testing.T) {
+	var cases = []struct {
+		name string
+		list []float64
+		want float64
+	}{
+		{"single source", []float64{7.1}, 7.1},
+		{"odd sources", []float64{7.3, 7.1, 7.2}, 7.2},
+		{"even sources", []float64{7.4, 7.1, 7.2, 7.3}, 7.25},
+		{"outlier ignored", []float64{7.1, 70, 7.2}, 7.2},
+	}
+
+	for _, c := range cases {
+		if got := median(c.list); got != c.want {
+			t.Errorf("%s: median = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	_ = log.Init()
+
+	var cases = []struct {
+		name     string
+		provider stubProvider
+		want     float64
+		err      bool
+	}{
+		{"source succeeded", stubProvider{price: 7.2}, 7.2, false},
+		{"source failed", stubProvider{err: errors.New("timeout")}, 0, true},
+		{"non-positive price rejected", stubProvider{price: 0}, 0, true},
+	}
+
+	// 未配置汇率来源时默认仅使用 okx
+	var origin = providers[conf.RateSourceOkx]
+	defer func() { providers[conf.RateSourceOkx] = origin }()
+
+	for _, c := range cases {
+		providers[conf.RateSourceOkx] = c.provider
+
+		price, quotes, err := Fetch(context.Background(), "usdt", "cny")
+		if (err != nil) != c.err {
+			t.Fatalf("%s: err = %v", c.name, err)
+		}
+		if price != c.want {
+			t.Fatalf("%s: price = %v, want %v", c.name, price, c.want)
+		}
+		if !c.err && (len(quotes) != 1 || quotes[0].Source != conf.RateSourceOkx) {
+			t.Fatalf("%s: unexpected quotes %+v", c.name, quotes)
+		}
+	}
+}
@@ -8,65 +8,152 @@ import (
 	"github.com/v03413/bepusdt/app/log"
 	"math"
 	"regexp"
+	"sync"
 )
 
-var okxTrxCnyCalcRate = 0.0
-var okxUsdtCnyCalcRate = 0.0
-var okxUsdcCnyCalcRate = 0.0
-var okxUsdtCnyRawRate = conf.DefaultUsdtCnyRate // okx 交易所 usdt/cny 原始汇率；6.4是初始默认值值，后续但凡有新的汇率数据更新都会覆盖这个值
-var okxUsdcCnyRawRate = conf.DefaultUsdcCnyRate // okx 交易所 usdc/cny 原始汇率；6.4是初始默认值值，后续但凡有新的汇率数据更新都会覆盖这个值
-var okxTrxCnyRawRate = conf.DefaultTrxCnyRate   // okx 交易所 trx/cny 原始汇率
-var okxRatePrecision = 2                        // 汇率保留位数，强迫症，另一方面两位小数足以覆盖大部分CNY使用场景
+var trxCnyCalcRate = 0.0
+var usdtCnyCalcRate = 0.0
+var usdcCnyCalcRate = 0.0
+var usdtCnyRawRate = conf.DefaultUsdtCnyRate // 各汇率来源聚合的 usdt/cny 原始汇率；6.4是初始默认值值，后续但凡有新的汇率数据更新都会覆盖这个值
+var usdcCnyRawRate = conf.DefaultUsdcCnyRate // 各汇率来源聚合的 usdc/cny 原始汇率；6.4是初始默认值值，后续但凡有新的汇率数据更新都会覆盖这个值
+var trxCnyRawRate = conf.DefaultTrxCnyRate   // 各汇率来源聚合的 trx/cny 原始汇率
+var ratePrecision = 2                        // 汇率保留位数，强迫症，另一方面两位小数足以覆盖大部分CNY使用场景
+var coinCnyRawRate sync.Map                  // 各汇率来源聚合的 原生币(ETH BNB 等)/cny 原始汇率 coin => float64
+var coinCnyCalcRate sync.Map                 // 原生币(ETH BNB 等)/cny 计算汇率 coin => float64
+var usdtFiatRawRate sync.Map                 // 各汇率来源聚合的 usdt/法币(USD EUR 等) 原始汇率 fiat => float64
+
+const FiatCNY = "CNY" // 基准法币，各代币汇率均以 CNY 计价
 
 func GetTrxCalcRate() float64 {
 
-	return okxTrxCnyCalcRate
+	return trxCnyCalcRate
 }
 
 func GetUsdtCalcRate() float64 {
 
-	return okxUsdtCnyCalcRate
+	return usdtCnyCalcRate
 }
 
 func GetUsdcCalcRate() float64 {
 
-	return okxUsdcCnyCalcRate
+	return usdcCnyCalcRate
+}
+
+func GetUsdtRawRate() float64 {
+
+	return usdtCnyRawRate
 }
 
-func GetOkxUsdtRawRate() float64 {
+func GetUsdcRawRate() float64 {
+
+	return usdcCnyRawRate
+}
+
+func GetTrxRawRate() float64 {
+
+	return trxCnyRawRate
+}
+
+// GetCoinCalcRate 原生币计算汇率，尚未获取到汇率时返回 0
+func GetCoinCalcRate(coin string) float64 {
+	if v, ok := coinCnyCalcRate.Load(coin); ok {
+
+		return v.(float64)
+	}
 
-	return okxUsdtCnyRawRate
+	return 0
 }
 
-func GetOkxUsdcRawRate() float64 {
+func GetCoinRawRate(coin string) float64 {
+	if v, ok := coinCnyRawRate.Load(coin); ok {
+
+		return v.(float64)
+	}
 
-	return okxUsdcCnyRawRate
+	return 0
 }
 
-func GetOkxTrxRawRate() float64 {
+func SetCoinCnyRate(coin, syntax string, rawRate float64) {
+	rawRate = round(rawRate, ratePrecision)
+	coinCnyRawRate.Store(coin, rawRate)
+	coinCnyCalcRate.Store(coin, ParseFloatRate(syntax, rawRate))
+}
 
-	return okxTrxCnyRawRate
+func SetTrxCnyRate(syntax string, rawRate float64) {
+	rawRate = round(rawRate, ratePrecision)
+	trxCnyRawRate = rawRate
+	trxCnyCalcRate = ParseFloatRate(syntax, rawRate)
 }
 
-func SetOkxTrxCnyRate(syntax string, rawRate float64) {
-	rawRate = round(rawRate, okxRatePrecision)
-	okxTrxCnyRawRate = rawRate
-	okxTrxCnyCalcRate = ParseFloatRate(syntax, rawRate)
+func SetUsdtCnyRate(syntax string, rawRate float64) {
+	rawRate = round(rawRate, ratePrecision)
+	usdtCnyRawRate = rawRate
+	usdtCnyCalcRate = ParseFloatRate(syntax, rawRate)
 }
 
-func SetOkxUsdtCnyRate(syntax string, rawRate float64) {
-	rawRate = round(rawRate, okxRatePrecision)
-	okxUsdtCnyRawRate = rawRate
-	okxUsdtCnyCalcRate = ParseFloatRate(syntax, rawRate)
+func SetUsdcCnyRate(syntax string, rawRate float64) {
+	rawRate = round(rawRate, ratePrecision)
+	usdcCnyRawRate = rawRate
+	usdcCnyCalcRate = ParseFloatRate(syntax, rawRate)
 }
 
-func SetOkxUsdcCnyRate(syntax string, rawRate float64) {
-	rawRate = round(rawRate, okxRatePrecision)
-	okxUsdcCnyRawRate = rawRate
-	okxUsdcCnyCalcRate = ParseFloatRate(syntax, rawRate)
+func SetUsdtFiatRate(fiat string, rawRate float64) {
+	usdtFiatRawRate.Store(fiat, rawRate)
+}
+
+// GetUsdtFiatRawRate usdt/法币 原始汇率，尚未获取到汇率时返回 0
+func GetUsdtFiatRawRate(fiat string) float64 {
+	if fiat == FiatCNY {
+
+		return usdtCnyRawRate
+	}
+
+	if v, ok := usdtFiatRawRate.Load(fiat); ok {
+
+		return v.(float64)
+	}
+
+	return 0
+}
+
+// GetFiatFactor CNY 计价汇率折算为指定法币计价的系数，以 usdt 两边的汇率交叉换算；尚未获取到汇率时返回 0
+func GetFiatFactor(fiat string) float64 {
+	if fiat == FiatCNY {
+
+		return 1
+	}
+
+	var usdtFiat, usdtCny = GetUsdtFiatRawRate(fiat), usdtCnyRawRate
+	if usdtFiat <= 0 || usdtCny <= 0 {
+
+		return 0
+	}
+
+	return usdtFiat / usdtCny
+}
+
+// RoundFiatRate 法币计价汇率保留位数，单价低于 1 的保留更多小数位
+func RoundFiatRate(val float64) float64 {
+	if val < 1 {
+
+		return round(val, 6)
+	}
+
+	return round(val, ratePrecision)
 }
 
 func ParseFloatRate(syntax string, rawVal float64) float64 {
+
+	return parseRate(syntax, rawVal, func(v float64) float64 { return round(v, ratePrecision) })
+}
+
+// ParseFiatRate 同 ParseFloatRate，原始汇率及固定数值均以指定法币计价，按法币计价规则保留位数
+func ParseFiatRate(syntax string, rawVal float64) float64 {
+
+	return parseRate(syntax, rawVal, RoundFiatRate)
+}
+
+func parseRate(syntax string, rawVal float64, roundFn func(float64) float64) float64 {
 	if syntax == "" {
 
 		return rawVal
@@ -98,7 +185,7 @@ func ParseFloatRate(syntax string, rawVal float64) float64 {
 		result = raw.Sub(base).InexactFloat64()
 	}
 
-	return round(result, okxRatePrecision)
+	return roundFn(result)
 }
 
 func round(val float64, precision int) float64 {
@@ -0,0 +1,50 @@
+package rate
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGetFiatFactor(t *testing.T) {
+	SetUsdtCnyRate("", 7)
+	SetUsdtFiatRate("USD", 1)
+	SetUsdtFiatRate("RUB", 91)
+	defer usdtFiatRawRate.Delete("USD")
+	defer usdtFiatRawRate.Delete("RUB")
+
+	var cases = []struct {
+		fiat string
+		want float64
+	}{
+		{FiatCNY, 1},
+		{"USD", 1.0 / 7},
+		{"RUB", 13},
+		{"EUR", 0},
+	}
+
+	for _, c := range cases {
+		if got := GetFiatFactor(c.fiat); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("GetFiatFactor(%s) = %v, want %v", c.fiat, got, c.want)
+		}
+	}
+}
+
+func TestParseFiatRate(t *testing.T) {
+	var cases = []struct {
+		syntax string
+		raw    float64
+		want   float64
+	}{
+		{"", 0.9234567, 0.9234567},
+		{"0.95", 0.92, 0.95},
+		{"~1.02", 0.92, 0.9384},
+		{"+0.01", 0.9234567, 0.933457},
+		{"-5", 91.234, 86.23},
+	}
+
+	for _, c := range cases {
+		if got := ParseFiatRate(c.syntax, c.raw); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("ParseFiatRate(%q, %v) = %v, want %v", c.syntax, c.raw, got, c.want)
+		}
+	}
+}
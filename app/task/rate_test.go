@@ -0,0 +1,45 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/v03413/bepusdt/app/model"
+	"github.com/v03413/bepusdt/app/model/modeltest"
+	"github.com/v03413/bepusdt/app/task/rate"
+)
+
+// 重启后以历史记录中最近一次采纳的汇率作为基准，首个异常读数同样被拒绝
+func TestRateSeed(t *testing.T) {
+	var cases = []struct {
+		name     string
+		coin     string
+		history  []model.RateHistory
+		reading  float64
+		accepted bool
+	}{
+		{"spike after restart rejected", "ETH", []model.RateHistory{{Rate: 20000, Accepted: true}}, 200000, false},
+		{"normal reading after restart accepted", "BNB", []model.RateHistory{{Rate: 4000, Accepted: true}}, 4100, true},
+		{"rejected history ignored", "SOL", []model.RateHistory{{Rate: 1000, Accepted: true}, {Rate: 10000, Accepted: false}}, 10000, false},
+		{"no history", "BTC", nil, 700000, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			modeltest.Setup(t)
+			for _, h := range c.history {
+				model.SaveRateHistory(c.coin, rate.FiatCNY, nil, h.Rate, h.Accepted)
+			}
+
+			rateSeed()
+
+			if err := rate.Accept(c.coin, rate.FiatCNY, c.reading, nil); (err == nil) != c.accepted {
+				t.Fatalf("reading %v: err = %v", c.reading, err)
+			}
+
+			// 基准仅用于校验，重启后未获取成功前汇率仍视为不可用
+			if _, ok := rate.GetSnapshot(c.coin, rate.FiatCNY); ok != c.accepted {
+				t.Fatalf("snapshot available = %v", ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/tidwall/gjson"
+	"github.com/v03413/bepusdt/app/conf"
+)
+
+// errRpcNotFound 接口返回 404，对于 REST 风格接口通常表示查询的数据不存在，而非节点异常
+var errRpcNotFound = errors.New("response status code 404")
+
+// rpcCall 选择网络当前最优节点发送请求，并上报节点请求结果；path 拼接在节点地址之后，post 为空时使用 GET 请求
+func rpcCall(ctx context.Context, net, path string, post []byte) ([]byte, error) {
+	var ep = conf.PickEndpoint(net)
+	body, err := rpcDo(ctx, ep.URL+path, post)
+	if errors.Is(err, errRpcNotFound) {
+		ep.Done(nil)
+	} else {
+		ep.Done(err)
+	}
+	if err != nil {
+
+		return nil, fmt.Errorf("%s %w", ep.URL, err)
+	}
+
+	return body, nil
+}
+
+// rpcDo 向指定地址发送请求，网络错误或响应状态码异常均返回错误
+func rpcDo(ctx context.Context, url string, post []byte) ([]byte, error) {
+	var method = "GET"
+	var reader io.Reader
+	if len(post) > 0 {
+		method = "POST"
+		reader = bytes.NewBuffer(post)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+
+		return nil, errors.Join(errors.New("creating request error"), err)
+	}
+
+	if len(post) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+
+		return nil, errors.Join(errors.New("sending request error"), err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+
+		return nil, errRpcNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+
+		return nil, fmt.Errorf("response status code %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+
+		return nil, errors.Join(errors.New("reading response body error"), err)
+	}
+
+	return body, nil
+}
+
+// rpcError 检查 JSON-RPC 响应(含批量请求)中是否包含 error
+func rpcError(body []byte) error {
+	var res = gjson.ParseBytes(body)
+	if res.Get("error").Exists() {
+
+		return fmt.Errorf("response error %s", res.Get("error").String())
+	}
+
+	if items := res.Get("#.error").Array(); len(items) > 0 {
+
+		return fmt.Errorf("response error %s", items[0].String())
+	}
+
+	return nil
+}
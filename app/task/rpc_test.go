@@ -0,0 +1,44 @@
+package task
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+// newTestRpc 模拟 EVM 节点的 JSON-RPC 接口，支持批量请求；handle 返回请求对应的 result 字段 JSON
+func newTestRpc(t *testing.T, handle func(method string, params []gjson.Result) string) string {
+	t.Helper()
+
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		var reply = func(req gjson.Result) string {
+
+			return fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":%s}`, req.Get("id").Raw, handle(req.Get("method").String(), req.Get("params").Array()))
+		}
+
+		var req = gjson.ParseBytes(body)
+		if !req.IsArray() {
+			_, _ = w.Write([]byte(reply(req)))
+
+			return
+		}
+
+		var items = make([]string, 0)
+		for _, itm := range req.Array() {
+			items = append(items, reply(itm))
+		}
+
+		_, _ = w.Write([]byte("[" + strings.Join(items, ",") + "]"))
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv.URL
+}
@@ -1,14 +1,12 @@
 package task
 
 import (
-	"bytes"
 	"context"
 	"encoding/binary"
 	"fmt"
-	"io"
 	"math/big"
-	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/btcsuite/btcd/btcutil/base58"
@@ -28,8 +26,8 @@ import (
 type solana struct {
 	slotConfirmedOffset int64
 	slotInitStartOffset int64
-	lastSlotNum         int64
 	slotQueue           *chanx.UnboundedChan[int64]
+	native              atomic.Bool // 是否解析 SOL 原生币转账
 }
 
 type solanaTokenOwner struct {
@@ -55,43 +53,23 @@ func newSolana() solana {
 	return solana{
 		slotConfirmedOffset: 60,
 		slotInitStartOffset: -600,
-		lastSlotNum:         0,
 		slotQueue:           chanx.NewUnboundedChan[int64](context.Background(), 30),
 	}
 }
 
 func (s *solana) slotRoll(ctx context.Context) {
+	var cur = getScanCursor(conf.Solana)
 	if rollBreak(conf.Solana) {
+		cur.reset()
 
 		return
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", conf.GetSolanaRpcEndpoint(), bytes.NewBuffer([]byte(`{"jsonrpc":"2.0","id":1,"method":"getSlot"}`)))
-	if err != nil {
-		log.Warn("slotRoll Error creating request:", err)
+	s.native.Store(nativeWatch(conf.Solana))
 
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := client.Do(req)
+	body, err := rpcCall(ctx, conf.Solana, "", []byte(`{"jsonrpc":"2.0","id":1,"method":"getSlot"}`))
 	if err != nil {
-		log.Warn("slotRoll Error sending request:", err)
-
-		return
-	}
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		log.Warn("slotRoll Error response status code:", resp.StatusCode)
-
-		return
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Warn("slotRoll Error reading response body:", err)
+		log.Warn("slotRoll Error:", err)
 
 		return
 	}
@@ -108,31 +86,24 @@ func (s *solana) slotRoll(ctx context.Context) {
 		now = now - s.slotConfirmedOffset
 	}
 
-	if now-s.lastSlotNum > conf.BlockHeightMaxDiff { // 区块高度变化过大，强制丢块重扫
-		s.lastSlotNum = now
+	ranges, fresh := cur.next(now, conf.BlockHeightMaxDiff, 1)
+	if fresh { // 无扫描进度，回溯扫描
 		s.slotInitOffset(now)
-	}
-
-	if now == s.lastSlotNum { // 区块高度没有变化
 
 		return
 	}
 
-	for n := s.lastSlotNum + 1; n <= now; n++ {
+	for _, r := range ranges {
 		// 待扫描区块入列
 
-		s.slotQueue.In <- n
+		s.slotQueue.In <- r.From
 	}
-
-	s.lastSlotNum = now
 }
 
 func (s *solana) slotDispatch(ctx context.Context) {
 	p, err := ants.NewPoolWithFunc(3, s.slotParse)
 	if err != nil {
 		panic(err)
-
-		return
 	}
 
 	defer p.Release()
@@ -155,7 +126,7 @@ func (s *solana) slotDispatch(ctx context.Context) {
 }
 
 func (s *solana) slotInitOffset(now int64) {
-	if now == 0 || s.lastSlotNum != 0 {
+	if now == 0 {
 
 		return
 	}
@@ -183,27 +154,14 @@ func (s *solana) slotParse(n any) {
 	network := conf.Solana
 
 	conf.SetBlockTotal(network)
-	resp, err := client.Post(conf.GetSolanaRpcEndpoint(), "application/json", bytes.NewBuffer(post))
-	if err != nil {
-		conf.SetBlockFail(network)
-		log.Warn("slotParse Error sending request:", err)
-
-		return
-	}
-
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		conf.SetBlockFail(network)
-		log.Warn("slotParse Error response status code:", resp.StatusCode)
-
-		return
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := rpcCall(ctx, network, "", post)
 	if err != nil {
 		conf.SetBlockFail(network)
 		s.slotQueue.In <- slot
-		log.Warn("slotParse Error reading response body:", err)
+		log.Warn("slotParse Error:", err)
 
 		return
 	}
@@ -224,6 +182,9 @@ func (s *solana) slotParse(n any) {
 			}
 		}
 
+		// 解析 SOL 原生币转账
+		transArr := s.parseNativeTransfer(trans, accountKeys)
+
 		// 查找SPL Token索引
 		splTokenIndex := int64(-1)
 		for i, v := range accountKeys {
@@ -235,7 +196,7 @@ func (s *solana) slotParse(n any) {
 		}
 
 		// SPL Token的Mint地址，即不包含 Token 交易信息
-		if splTokenIndex == -1 {
+		if splTokenIndex == -1 && len(transArr) == 0 {
 
 			continue
 		}
@@ -257,8 +218,6 @@ func (s *solana) slotParse(n any) {
 			}
 		}
 
-		transArr := make([]transfer, 0)
-
 		// 解析外部指令
 		for _, instr := range trans.Get("transaction.message.instructions").Array() {
 			if instr.Get("programIdIndex").Int() != splTokenIndex {
@@ -302,6 +261,7 @@ func (s *solana) slotParse(n any) {
 		}
 	}
 
+	getScanCursor(network).done(slot)
 	log.Debug("区块扫描完成", slot, conf.GetBlockSuccRate(network), network)
 }
 
@@ -355,36 +315,73 @@ func (s *solana) parseTransfer(instr gjson.Result, accountKeys []string, tokenAc
 	return trans
 }
 
-func (s *solana) tradeConfirmHandle(ctx context.Context) {
-	var orders = getConfirmingOrders(networkTokenMap[conf.Solana])
-	var wg sync.WaitGroup
+// parseNativeTransfer 解析 System Program 转账指令(包含内部指令)，即 SOL 原生币转账
+func (s *solana) parseNativeTransfer(trans gjson.Result, accountKeys []string) []transfer {
+	var result = make([]transfer, 0)
+	if !s.native.Load() || trans.Get("meta.err").Type != gjson.Null { // 失败交易不做处理
 
-	var handle = func(o model.TradeOrders) {
-		post := []byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"getSignatureStatuses","params":[["%s"],{"searchTransactionHistory":true}]}`, o.TradeHash))
-		req, err := http.NewRequestWithContext(ctx, "POST", conf.GetSolanaRpcEndpoint(), bytes.NewBuffer(post))
-		if err != nil {
-			log.Warn("solana tradeConfirmHandle Error creating request:", err)
+		return result
+	}
 
-			return
+	systemIndex := int64(-1)
+	for i, v := range accountKeys {
+		if v == conf.SolSystem {
+			systemIndex = int64(i)
+
+			break
 		}
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Warn("solana tradeConfirmHandle Error sending request:", err)
+	}
 
-			return
+	if systemIndex == -1 {
+
+		return result
+	}
+
+	instrs := trans.Get("transaction.message.instructions").Array()
+	for _, itm := range trans.Get("meta.innerInstructions").Array() {
+		instrs = append(instrs, itm.Get("instructions").Array()...)
+	}
+
+	for _, instr := range instrs {
+		if instr.Get("programIdIndex").Int() != systemIndex {
+
+			continue
 		}
 
-		defer resp.Body.Close()
+		// Transfer 指令：4字节指令序号(2) + 8字节 lamports，账户依次为 from to
+		data := base58.Decode(instr.Get("data").String())
+		if len(data) != 12 || binary.LittleEndian.Uint32(data[0:4]) != 2 {
 
-		if resp.StatusCode != 200 {
-			log.Warn("solana tradeConfirmHandle Error response status code:", resp.StatusCode)
+			continue
+		}
 
-			return
+		accounts := instr.Get("accounts").Array()
+		if len(accounts) < 2 || accounts[0].Int() >= int64(len(accountKeys)) || accounts[1].Int() >= int64(len(accountKeys)) {
+
+			continue
 		}
 
-		body, err := io.ReadAll(resp.Body)
+		lamports := new(big.Int).SetUint64(binary.LittleEndian.Uint64(data[4:12]))
+		result = append(result, transfer{
+			FromAddress: accountKeys[accounts[0].Int()],
+			RecvAddress: accountKeys[accounts[1].Int()],
+			Amount:      decimal.NewFromBigInt(lamports, conf.SolDecimals),
+			TradeType:   model.OrderTradeTypeSolSolana,
+		})
+	}
+
+	return result
+}
+
+func (s *solana) tradeConfirmHandle(ctx context.Context) {
+	var orders = getConfirmingOrders(networkTokenMap[conf.Solana])
+	var wg sync.WaitGroup
+
+	var handle = func(o model.TradeOrders) {
+		post := []byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"getSignatureStatuses","params":[["%s"],{"searchTransactionHistory":true}]}`, o.TradeHash))
+		body, err := rpcCall(ctx, conf.Solana, "", post)
 		if err != nil {
-			log.Warn("solana tradeConfirmHandle Error reading response body:", err)
+			log.Warn("solana tradeConfirmHandle Error:", err)
 
 			return
 		}
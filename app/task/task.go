@@ -7,6 +7,8 @@ import (
 
 	"github.com/shopspring/decimal"
 	"github.com/v03413/bepusdt/app/conf"
+	"github.com/v03413/bepusdt/app/model"
+	"github.com/v03413/bepusdt/app/task/rate"
 )
 
 type task struct {
@@ -26,6 +28,8 @@ func Init() error {
 	arbitrumInit()
 	xlayerInit()
 	baseInit()
+	chainsInit()
+	rateInit()
 
 	return nil
 }
@@ -42,7 +46,15 @@ func register(t task) {
 	tasks = append(tasks, t)
 }
 
-func inAmountRange(payAmount decimal.Decimal) bool {
+func inAmountRange(payAmount decimal.Decimal, tradeType string) bool {
+	// 原生币(ETH BNB 等)按汇率折算为 USDT 数额后再比较
+	if token, err := model.GetTokenType(tradeType); err == nil {
+		var coin, usdt = rate.GetCoinCalcRate(string(token)), rate.GetUsdtCalcRate()
+		if coin > 0 && usdt > 0 {
+			payAmount = payAmount.Mul(decimal.NewFromFloat(coin / usdt))
+		}
+	}
+
 	if payAmount.GreaterThan(conf.GetPaymentAmountMax()) {
 
 		return false
@@ -0,0 +1,36 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/v03413/bepusdt/app/model"
+	"github.com/v03413/bepusdt/app/task/rate"
+)
+
+// 原生币按汇率折算为 USDT 数额后再与支付数额范围比较，默认范围 0.01 ~ 99999
+func TestInAmountRange(t *testing.T) {
+	rate.SetUsdtCnyRate("", 7)
+	rate.SetCoinCnyRate(string(model.TokenTypeETH), "", 21000)
+
+	var cases = []struct {
+		name      string
+		amount    string
+		tradeType string
+		want      bool
+	}{
+		{"usdt within range", "10", model.OrderTradeTypeUsdtTrc20, true},
+		{"usdt below min", "0.001", model.OrderTradeTypeUsdtTrc20, false},
+		{"usdt above max", "100000", model.OrderTradeTypeUsdtTrc20, false},
+		{"eth converted within range", "0.001", model.OrderTradeTypeEthErc20, true},
+		{"eth converted below min", "0.000001", model.OrderTradeTypeEthErc20, false},
+		{"eth converted above max", "100", model.OrderTradeTypeEthErc20, false},
+		{"coin without rate compared as is", "0.001", model.OrderTradeTypeBnbBep20, false},
+	}
+
+	for _, c := range cases {
+		if got := inAmountRange(decimal.RequireFromString(c.amount), c.tradeType); got != c.want {
+			t.Errorf("%s: inAmountRange(%s) = %v, want %v", c.name, c.amount, got, c.want)
+		}
+	}
+}
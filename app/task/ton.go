@@ -0,0 +1,290 @@
+package task
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/panjf2000/ants/v2"
+	"github.com/shopspring/decimal"
+	"github.com/smallnest/chanx"
+	"github.com/tidwall/gjson"
+	"github.com/v03413/bepusdt/app/conf"
+	"github.com/v03413/bepusdt/app/help"
+	"github.com/v03413/bepusdt/app/log"
+	"github.com/v03413/bepusdt/app/model"
+)
+
+// 参考文档
+//  - https://toncenter.com/api/v3/index.html
+//  - https://docs.ton.org/v3/guidelines/dapps/asset-processing/jettons
+
+// TON 网络没有全局统一的区块高度，这里以 masterchain 区块时间(秒)作为扫描进度，按时间区间查询 Jetton 转账
+
+type ton struct {
+	api               string // 接口地址，为空则使用配置节点；单元测试时可替换为本地接口
+	utimeChunkSize    int64  // 每次查询的时间区间(秒)
+	utimeInitOffset   int64  // 首次启动回溯时间(秒)
+	utimeMaxDiff      int64
+	transferPageLimit int
+	utimeQueue        *chanx.UnboundedChan[version]
+}
+
+var tn ton
+
+func init() {
+	tn = newTon()
+	register(task{callback: tn.utimeDispatch})
+	register(task{callback: tn.utimeRoll, duration: time.Second * 5})
+	register(task{callback: tn.tradeConfirmHandle, duration: time.Second * 5})
+}
+
+func newTon() ton {
+	return ton{
+		utimeChunkSize:    30,
+		utimeInitOffset:   -600,
+		utimeMaxDiff:      3600,
+		transferPageLimit: 500,
+		utimeQueue:        chanx.NewUnboundedChan[version](context.Background(), 30),
+	}
+}
+
+func (t *ton) call(ctx context.Context, path string) ([]byte, error) {
+	if t.api != "" {
+
+		return rpcDo(ctx, t.api+path, nil)
+	}
+
+	return rpcCall(ctx, conf.Ton, path, nil)
+}
+
+func (t *ton) utimeRoll(ctx context.Context) {
+	var cur = getScanCursor(conf.Ton)
+	if rollBreak(conf.Ton) {
+		cur.reset()
+
+		return
+	}
+
+	body, err := t.call(ctx, "masterchainInfo")
+	if err != nil {
+		log.Warn("ton utimeRoll Error:", err)
+
+		return
+	}
+
+	now := gjson.GetBytes(body, "last.gen_utime").Int()
+	if now <= 0 {
+		log.Warn("ton utimeRoll Error: invalid gen_utime:", now)
+
+		return
+	}
+
+	ranges, fresh := cur.next(now, t.utimeMaxDiff, t.utimeChunkSize)
+	if fresh {
+		t.utimeInitStart(now)
+
+		return
+	}
+
+	for _, r := range ranges {
+		t.utimeQueue.In <- version{Start: r.From, Limit: r.To - r.From + 1}
+	}
+}
+
+func (t *ton) utimeDispatch(ctx context.Context) {
+	p, err := ants.NewPoolWithFunc(2, t.utimeParse)
+	if err != nil {
+		panic(err)
+	}
+
+	defer p.Release()
+
+	for {
+		select {
+		case n := <-t.utimeQueue.Out:
+			if err := p.Invoke(n); err != nil {
+				t.utimeQueue.In <- n
+				log.Warn("ton utimeDispatch Error invoking process:", err)
+			}
+		case <-ctx.Done():
+			if err := ctx.Err(); err != nil {
+				log.Warn("ton utimeDispatch context done:", err)
+			}
+
+			return
+		}
+	}
+}
+
+func (t *ton) utimeInitStart(now int64) {
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		var end = now + t.utimeInitOffset
+		for s := now - t.utimeChunkSize + 1; s+t.utimeChunkSize > end; s -= t.utimeChunkSize {
+			if rollBreak(conf.Ton) {
+
+				return
+			}
+
+			t.utimeQueue.In <- version{Start: s, Limit: t.utimeChunkSize}
+
+			<-ticker.C
+		}
+	}()
+}
+
+func (t *ton) utimeParse(n any) {
+	p := n.(version)
+
+	var net = conf.Ton
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+	defer cancel()
+
+	conf.SetBlockTotal(net)
+
+	var watch = tonWatchAddress()
+	var transfers = make([]transfer, 0)
+	for offset := 0; ; offset += t.transferPageLimit {
+		body, err := t.call(ctx, fmt.Sprintf("jetton/transfers?jetton_master=%s&start_utime=%d&end_utime=%d&limit=%d&offset=%d&sort=asc",
+			conf.UsdtTon, p.Start, p.Start+p.Limit-1, t.transferPageLimit, offset))
+		if err == nil && !gjson.ValidBytes(body) {
+			err = fmt.Errorf("invalid JSON response body")
+		}
+		if err != nil {
+			conf.SetBlockFail(net)
+			t.utimeQueue.In <- p
+			log.Warn("ton utimeParse Error:", err)
+
+			return
+		}
+
+		transfers = append(transfers, t.parseTransfer(body, watch)...)
+		if len(gjson.GetBytes(body, "jetton_transfers").Array()) < t.transferPageLimit {
+
+			break
+		}
+	}
+
+	if len(transfers) > 0 {
+
+		transferQueue.In <- transfers
+	}
+
+	getScanCursor(net).done(p.Start)
+	log.Debug("区块扫描完成", fmt.Sprintf("%d.%d", p.Start, p.Limit), conf.GetBlockSuccRate(net), net)
+}
+
+func (t *ton) parseTransfer(body []byte, watch map[string]string) []transfer {
+	var data = gjson.ParseBytes(body)
+	var book = data.Get("address_book").Map()
+	var result = make([]transfer, 0)
+	for _, trans := range data.Get("jetton_transfers").Array() {
+		if trans.Get("transaction_aborted").Bool() {
+
+			continue
+		}
+
+		amount, ok := new(big.Int).SetString(trans.Get("amount").String(), 10)
+		if !ok || amount.Sign() <= 0 {
+
+			continue
+		}
+
+		hash, err := base64.StdEncoding.DecodeString(trans.Get("transaction_hash").String())
+		if err != nil {
+
+			continue
+		}
+
+		src := trans.Get("source").String()
+		dst := trans.Get("destination").String()
+		utime := trans.Get("transaction_now").Int()
+		result = append(result, transfer{
+			Network:     conf.Ton,
+			TxHash:      hex.EncodeToString(hash),
+			Amount:      decimal.NewFromBigInt(amount, conf.UsdtTonDecimals),
+			FromAddress: tonAddress(src, book[src].Get("user_friendly").String(), watch),
+			RecvAddress: tonAddress(dst, book[dst].Get("user_friendly").String(), watch),
+			Timestamp:   time.Unix(utime, 0),
+			TradeType:   model.OrderTradeTypeUsdtTon,
+			BlockNum:    utime,
+		})
+	}
+
+	return result
+}
+
+// tonAddress 交易中的地址为 raw 格式，若为监控地址则还原为添加时的格式，否则使用用户友好格式
+func tonAddress(raw, friendly string, watch map[string]string) string {
+	if key, _ := help.TonRawAddress(raw); key != "" && watch[key] != "" {
+
+		return watch[key]
+	}
+
+	if friendly != "" {
+
+		return friendly
+	}
+
+	return raw
+}
+
+// tonWatchAddress 收款地址及等待支付订单地址 raw 格式 => 原始格式
+func tonWatchAddress() map[string]string {
+	var result = make(map[string]string)
+	if unitTestMode {
+
+		return result
+	}
+
+	var addrs = make([]string, 0)
+	var more = make([]string, 0)
+	model.DB.Model(&model.WalletAddress{}).Where("trade_type = ?", model.OrderTradeTypeUsdtTon).Pluck("address", &addrs)
+	model.DB.Model(&model.TradeOrders{}).Where("status in (?) and trade_type = ?", model.OrderOpenStatus, model.OrderTradeTypeUsdtTon).Pluck("address", &more)
+	for _, addr := range append(addrs, more...) {
+		if raw, ok := help.TonRawAddress(addr); ok {
+			result[raw] = addr
+		}
+	}
+
+	return result
+}
+
+func (t *ton) tradeConfirmHandle(ctx context.Context) {
+	var orders = getConfirmingOrders(networkTokenMap[conf.Ton])
+	var wg sync.WaitGroup
+
+	var handle = func(o model.TradeOrders) {
+		body, err := t.call(ctx, "transactions?limit=1&hash="+o.TradeHash)
+		if err != nil {
+			log.Warn("ton tradeConfirmHandle Error:", err)
+
+			return
+		}
+
+		// toncenter 仅索引已最终确认的区块，能查询到交易且未中止即视为确认
+		trans := gjson.GetBytes(body, "transactions.0")
+		if trans.Exists() && !trans.Get("description.aborted").Bool() {
+
+			markFinalConfirmed(o)
+		}
+	}
+
+	for _, order := range orders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			handle(order)
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,95 @@
+package task
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/v03413/bepusdt/app/conf"
+	"github.com/v03413/bepusdt/app/log"
+	"github.com/v03413/bepusdt/app/model"
+)
+
+const tonStubTransfers = `{
+  "jetton_transfers": [
+    {
+      "source": "0:6A5AE5E5C5B3E4B8F3F3D3B2C9B7A4F0B4E3D2C1B0A9F8E7D6C5B4A3F2E1D0C9",
+      "destination": "0:B113A994B5024A16719F69139328EB759596C38A25F59028B146FECDC3621DFE",
+      "amount": "12340000",
+      "transaction_hash": "3q2+7w==",
+      "transaction_now": 1700000000,
+      "transaction_aborted": false
+    },
+    {
+      "source": "0:6A5AE5E5C5B3E4B8F3F3D3B2C9B7A4F0B4E3D2C1B0A9F8E7D6C5B4A3F2E1D0C9",
+      "destination": "0:B113A994B5024A16719F69139328EB759596C38A25F59028B146FECDC3621DFE",
+      "amount": "1000000",
+      "transaction_hash": "AAAA",
+      "transaction_now": 1700000001,
+      "transaction_aborted": true
+    }
+  ],
+  "address_book": {
+    "0:B113A994B5024A16719F69139328EB759596C38A25F59028B146FECDC3621DFE": {"user_friendly": "UQCxE6mUtQJKFnGfaROTKOt1lZbDiiX1kCixRv7Nw2Id_p0p"}
+  }
+}`
+
+func TestTon(t *testing.T) {
+	os.Setenv(`BEPUSDT_LOG_OUTPUT_CONSOLE`, `1`)
+	log.Init()
+	unitTestMode = true
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/masterchainInfo":
+			w.Write([]byte(`{"last":{"seqno":1,"gen_utime":"1700000030"}}`))
+		case "/jetton/transfers":
+			w.Write([]byte(tonStubTransfers))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	tt := newTon()
+	tt.api = srv.URL + "/"
+
+	body, err := tt.call(context.Background(), "masterchainInfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(body) == "" {
+		t.Fatal(`empty masterchainInfo`)
+	}
+
+	tt.utimeParse(version{Start: 1700000000, Limit: 30})
+
+	select {
+	case transfers := <-transferQueue.Out:
+		if len(transfers) != 1 {
+			t.Fatalf(`unexpected transfers %d`, len(transfers))
+		}
+
+		tr := transfers[0]
+		if tr.Amount.String() != "12.34" || tr.TradeType != model.OrderTradeTypeUsdtTon || tr.Network != conf.Ton {
+			t.Fatalf(`unexpected transfer %+v`, tr)
+		}
+
+		if tr.RecvAddress != "UQCxE6mUtQJKFnGfaROTKOt1lZbDiiX1kCixRv7Nw2Id_p0p" || tr.TxHash != "deadbeef" {
+			t.Fatalf(`unexpected transfer %+v`, tr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal(`transfer not found`)
+	}
+
+	// 监控地址还原为添加时的格式
+	watch := map[string]string{"0:b113a994b5024a16719f69139328eb759596c38a25f59028b146fecdc3621dfe": "EQCxE6mUtQJKFnGfaROTKOt1lZbDiiX1kCixRv7Nw2Id_sDs"}
+	res := tt.parseTransfer([]byte(tonStubTransfers), watch)
+	if len(res) != 1 || res[0].RecvAddress != "EQCxE6mUtQJKFnGfaROTKOt1lZbDiiX1kCixRv7Nw2Id_sDs" {
+		t.Fatalf(`unexpected watch transfer %+v`, res)
+	}
+}
@@ -14,8 +14,8 @@ import (
 	bot2 "github.com/v03413/bepusdt/app/bot"
 	"github.com/v03413/bepusdt/app/conf"
 	"github.com/v03413/bepusdt/app/help"
+	"github.com/v03413/bepusdt/app/log"
 	"github.com/v03413/bepusdt/app/model"
-	"github.com/v03413/bepusdt/app/web/notify"
 	"github.com/v03413/tronprotocol/core"
 )
 
@@ -28,6 +28,7 @@ type transfer struct {
 	Timestamp   time.Time
 	TradeType   string
 	BlockNum    int64
+	LogIndex    int64 // 交易内的日志序号，仅 EVM 代币转账有效，用于区分同一交易内的多笔转账
 }
 
 type resource struct {
@@ -38,6 +39,7 @@ type resource struct {
 	RecvAddress  string
 	Timestamp    time.Time
 	ResourceCode core.ResourceCode
+	BlockNum     int64
 }
 
 var resourceQueue = chanx.NewUnboundedChan[[]resource](context.Background(), 30) // 资源队列
@@ -50,51 +52,316 @@ func init() {
 	register(task{callback: tronResourceHandle})
 }
 
+// markFinalConfirmed 订单最终确认，商户回调、Webhook 及机器人消息随状态变更写入发件箱
 func markFinalConfirmed(o model.TradeOrders) {
-	model.PushWebhookEvent(model.WebhookEventOrderPaid, o)
-
-	o.SetSuccess()
-
-	go notify.Handle(o)         // 通知订单支付成功
-	go bot2.SendTradeSuccMsg(o) // TG发送订单信息
+	if err := o.SetSuccess(); err != nil {
+		log.Warn("订单最终确认失败：", o.TradeId, err)
+	}
 }
 
 func orderTransferHandle(context.Context) {
 	for transfers := range transferQueue.Out {
-		var other = make([]transfer, 0)
-		var orders = getAllWaitingOrders()
-		for _, t := range transfers {
-			// debug
-			//if t.TradeType == model.OrderTradeTypeUsdcBep20 {
-			//	fmt.Println(t.TradeType, t.TxHash, t.FromAddress, "=>", t.RecvAddress, t.Amount.String())
-			//}
+		handleOrderTransfers(transfers)
+	}
+}
+
+// handleOrderTransfers 将一批转账匹配到订单，未匹配的转入非订单队列；匹配后未能计入订单的交易记录为未匹配收款
+func handleOrderTransfers(transfers []transfer) {
+	var other = make([]transfer, 0)
+	var unmatched = make([]transfer, 0)
+	var orders = getAllWaitingOrders()
+	var derived = model.GetDerivedWaitingOrders()
+	var open = model.GetOpenAmountWaitingOrders()
+	var late = model.GetLateOrders(earliestTransfer(transfers))
+	var partial model.PartialOrders
+	if conf.GetPartialPayment() {
+		partial = model.GetPartialOrders()
+	}
+
+	saveTransferLedger(transfers)
 
-			// 判断金额是否在允许范围内
-			if !inAmountRange(t.Amount) {
+	for _, t := range transfers {
+		// debug
+		//if t.TradeType == model.OrderTradeTypeUsdcBep20 {
+		//	fmt.Println(t.TradeType, t.TxHash, t.FromAddress, "=>", t.RecvAddress, t.Amount.String())
+		//}
+
+		// 判断金额是否在允许范围内
+		if !inAmountRange(t.Amount, t.TradeType) {
+			unmatched = append(unmatched, t)
+
+			continue
+		}
+
+		// 判断是否存在对应订单
+		o, ok := orders[fmt.Sprintf("%s%v%s", t.RecvAddress, t.Amount.String(), t.TradeType)]
+		if !ok {
+			// 不定额订单独占收款地址，转入任意数额即完成
+			o, ok = open[t.RecvAddress+t.TradeType]
+		}
+		if !ok {
+			// 派生地址每个订单独立，仅按收款地址匹配，支付数额不低于订单数额即可
+			o, ok = derived[t.RecvAddress+t.TradeType]
+			ok = ok && o.IsPaidEnough(t.Amount)
+		}
+		if !ok {
+			// 多付：支付数额超出订单数额但在允许比例内
+			o, ok = getOverpaidOrder(orders, t)
+		}
+		if !ok {
+			// 追赶扫描历史区块时，订单可能已经被标记过期，交易时间在有效期内依然视为正常支付
+			o, ok = late.MatchExpired(t.RecvAddress, t.Amount.String(), t.TradeType, t.Timestamp)
+		}
+		if !ok {
+			// 订单过期后才收到支付，按过期后支付处理
+			o, ok = late.Match(t.RecvAddress, t.Amount.String(), t.TradeType, t.Timestamp)
+		}
+		if !ok && partial != nil {
+			// 部分支付：转入订单地址的交易累计到已支付数额
+			if o, ok = partial.Match(t.RecvAddress, t.TradeType); ok {
+				o, ok = partialPaymentHandle(o, t)
+				partial.Update(o)
+				if !ok {
+					unmatched = append(unmatched, t)
+				}
 
 				continue
 			}
+		}
+		if !ok {
+			other = append(other, t)
+
+			continue
+		}
 
-			// 判断是否存在对应订单
-			o, ok := orders[fmt.Sprintf("%s%v%s", t.RecvAddress, t.Amount.String(), t.TradeType)]
-			if !ok {
-				other = append(other, t)
+		// 有效期检测
+		if !o.CreatedAt.Before(t.Timestamp) {
+			unmatched = append(unmatched, t)
 
-				continue
+			continue
+		}
+		if !o.ExpiredAt.After(t.Timestamp) {
+			// 不定额订单过期后不再结算，收款记为未匹配，由管理员手动关联
+			if o.OpenAmount || !latePaidHandle(o, t) {
+				unmatched = append(unmatched, t)
 			}
 
-			// 有效期检测
-			if !o.CreatedAt.Before(t.Timestamp) || !o.ExpiredAt.After(t.Timestamp) {
+			continue
+		}
+		if o.OpenAmount {
+			if err := o.SettleOpenAmount(t.Amount); err != nil {
+				log.Warn("不定额订单结算失败：", o.TradeId, err)
+				unmatched = append(unmatched, t)
 
 				continue
 			}
+		}
 
-			// 进入确认状态
-			o.MarkConfirming(t.BlockNum, t.FromAddress, t.TxHash, t.Timestamp)
+		// 进入确认状态
+		o.PaidAmount = t.Amount.String()
+		if err := o.MarkConfirming(t.BlockNum, t.FromAddress, t.TxHash, t.Timestamp); err != nil {
+			log.Warn("订单进入确认状态失败：", o.TradeId, err)
+			unmatched = append(unmatched, t)
+
+			continue
 		}
 
-		if len(other) > 0 {
-			notOrderQueue.In <- other
+		model.LinkLedger(t.TxHash, t.RecvAddress, o.TradeId)
+	}
+
+	if len(unmatched) > 0 {
+		saveUnmatchedPayments(unmatched)
+	}
+
+	if len(other) > 0 {
+		notOrderQueue.In <- other
+	}
+}
+
+// earliestTransfer 本批交易中最早的交易时间
+func earliestTransfer(transfers []transfer) time.Time {
+	var at = time.Now()
+	for _, t := range transfers {
+		if t.Timestamp.Before(at) {
+			at = t.Timestamp
+		}
+	}
+
+	return at
+}
+
+// getOverpaidOrder 支付数额超出订单数额且不超过允许多付比例，匹配数额最接近的等待支付订单
+func getOverpaidOrder(orders map[string]model.TradeOrders, t transfer) (model.TradeOrders, bool) {
+	var tolerance = conf.GetOverpayTolerance()
+	if tolerance.IsZero() {
+
+		return model.TradeOrders{}, false
+	}
+
+	var result model.TradeOrders
+	var best = decimal.Zero
+	for _, o := range orders {
+		if o.TradeType != t.TradeType || o.Address != t.RecvAddress {
+
+			continue
+		}
+
+		amount, err := decimal.NewFromString(o.Amount)
+		if err != nil || !amount.LessThan(t.Amount) || t.Amount.GreaterThan(amount.Add(amount.Mul(tolerance))) {
+
+			continue
+		}
+
+		if amount.GreaterThan(best) {
+			best = amount
+			result = o
+		}
+	}
+
+	return result, best.IsPositive()
+}
+
+// partialPaymentHandle 累计订单已支付数额，达到订单数额(含允许差额)后进入确认状态，返回变更后的订单及交易是否已计入订单
+func partialPaymentHandle(o model.TradeOrders, t transfer) (model.TradeOrders, bool) {
+	if !o.CreatedAt.Before(t.Timestamp) || !o.ExpiredAt.After(t.Timestamp) {
+
+		return o, false
+	}
+
+	// 交易已记录过，重复扫描无需处理
+	if !o.AddPayment(t.Amount, t.FromAddress, t.TxHash, t.BlockNum, t.Timestamp) {
+
+		return o, true
+	}
+
+	model.LinkLedger(t.TxHash, t.RecvAddress, o.TradeId)
+
+	paid, _ := decimal.NewFromString(o.PaidAmount)
+	if o.IsPaidEnough(paid) {
+		if err := o.MarkConfirming(t.BlockNum, t.FromAddress, t.TxHash, t.Timestamp); err != nil {
+			log.Warn("订单进入确认状态失败：", o.TradeId, err)
+		}
+
+		return o, true
+	}
+
+	if err := o.SetPartial(); err != nil {
+		log.Warn("订单部分支付标记失败：", o.TradeId, err)
+	}
+
+	return o, true
+}
+
+// latePaidHandle 订单过期后才收到支付，通知商户及管理员，由管理员确认是否收款；返回交易是否已计入订单
+func latePaidHandle(o model.TradeOrders, t transfer) bool {
+	o.PaidAmount = t.Amount.String()
+	if err := o.MarkLatePaid(t.BlockNum, t.FromAddress, t.TxHash, t.Timestamp); err != nil {
+		log.Warn("订单过期后支付标记失败：", o.TradeId, err)
+
+		return false
+	}
+
+	model.LinkLedger(t.TxHash, t.RecvAddress, o.TradeId)
+
+	return true
+}
+
+// getManagedAddress 全部收款钱包地址
+func getManagedAddress() map[string]bool {
+	var addrs []string
+	var managed = make(map[string]bool)
+
+	model.DB.Model(&model.WalletAddress{}).Pluck("address", &addrs)
+	for _, addr := range addrs {
+		managed[addr] = true
+		managed[strings.ToLower(addr)] = true // EVM 交易解析出的地址均为小写
+	}
+
+	return managed
+}
+
+// saveTransferLedger 收款钱包相关的转账写入流水
+func saveTransferLedger(transfers []transfer) {
+	var rows = make([]model.Ledger, 0)
+	var managed = getManagedAddress()
+	for _, t := range transfers {
+		if !managed[t.RecvAddress] && !managed[t.FromAddress] {
+
+			continue
+		}
+
+		rows = append(rows, model.Ledger{
+			Network:     t.Network,
+			Type:        model.LedgerTypeTransfer,
+			TxHash:      t.TxHash,
+			LogIndex:    t.LogIndex,
+			FromAddress: t.FromAddress,
+			RecvAddress: t.RecvAddress,
+			Token:       t.TradeType,
+			Amount:      t.Amount.String(),
+			BlockNum:    t.BlockNum,
+			Timestamp:   t.Timestamp,
+		})
+	}
+
+	if err := model.SaveLedgers(rows); err != nil {
+		log.Warn("转账流水写入失败：", err)
+	}
+}
+
+// saveResourceLedger 收款钱包相关的 Tron 资源代理及回收写入流水
+func saveResourceLedger(resources []resource) {
+	var rows = make([]model.Ledger, 0)
+	var managed = getManagedAddress()
+	for _, r := range resources {
+		if !managed[r.RecvAddress] && !managed[r.FromAddress] {
+
+			continue
+		}
+
+		var typ = model.LedgerTypeDelegate
+		if r.Type == core.Transaction_Contract_UnDelegateResourceContract {
+			typ = model.LedgerTypeUndelegate
+		}
+
+		rows = append(rows, model.Ledger{
+			Network:     conf.Tron,
+			Type:        typ,
+			TxHash:      r.ID,
+			FromAddress: r.FromAddress,
+			RecvAddress: r.RecvAddress,
+			Token:       r.ResourceCode.String(),
+			Amount:      decimal.New(r.Balance, -6).String(), // 质押的 TRX 数量
+			BlockNum:    r.BlockNum,
+			Timestamp:   r.Timestamp,
+		})
+	}
+
+	if err := model.SaveLedgers(rows); err != nil {
+		log.Warn("资源流水写入失败：", err)
+	}
+}
+
+// saveUnmatchedPayments 转入收款地址但未匹配到订单的交易，记录到未匹配收款等待处理
+func saveUnmatchedPayments(transfers []transfer) {
+	var managed = getManagedAddress()
+	for _, t := range transfers {
+		if !managed[t.RecvAddress] {
+
+			continue
+		}
+
+		err := model.SaveUnmatchedPayment(model.UnmatchedPayment{
+			TradeType:   t.TradeType,
+			TxHash:      t.TxHash,
+			Amount:      t.Amount.String(),
+			FromAddress: t.FromAddress,
+			RecvAddress: t.RecvAddress,
+			BlockNum:    t.BlockNum,
+			PaidAt:      t.Timestamp,
+		})
+		if err != nil {
+			log.Warn("未匹配收款记录失败：", t.TxHash, err)
 		}
 	}
 }
@@ -103,6 +370,8 @@ func notOrderTransferHandle(context.Context) {
 	for transfers := range notOrderQueue.Out {
 		var was []model.WalletAddress
 
+		saveUnmatchedPayments(transfers)
+
 		model.DB.Where("other_notify = ?", model.OtherNotifyEnable).Find(&was)
 
 		for _, wa := range was {
@@ -112,7 +381,7 @@ func notOrderTransferHandle(context.Context) {
 					continue
 				}
 
-				if !inAmountRange(t.Amount) {
+				if !inAmountRange(t.Amount, t.TradeType) {
 
 					continue
 				}
@@ -159,6 +428,8 @@ func notOrderTransferHandle(context.Context) {
 func tronResourceHandle(context.Context) {
 	for resources := range resourceQueue.Out {
 		var was []model.WalletAddress
+
+		saveResourceLedger(resources)
 		var types = []string{model.OrderTradeTypeTronTrx, model.OrderTradeTypeUsdtTrc20}
 
 		model.DB.Where("status = ? and other_notify = ? and trade_type in (?)", model.StatusEnable, model.OtherNotifyEnable, types).Find(&was)
@@ -219,8 +490,6 @@ func getAllWaitingOrders() map[string]model.TradeOrders {
 	for _, order := range tradeOrders {
 		if time.Now().Unix() >= order.ExpiredAt.Unix() { // 订单过期
 			order.SetExpired()
-			notify.Bepusdt(order)
-			model.PushWebhookEvent(model.WebhookEventOrderTimeout, order)
 
 			continue
 		}
@@ -233,6 +502,12 @@ func getAllWaitingOrders() map[string]model.TradeOrders {
 		data[order.Address+order.Amount+order.TradeType] = order
 	}
 
+	for _, order := range model.GetOrderByStatus(model.OrderStatusPartial) {
+		if time.Now().Unix() >= order.ExpiredAt.Unix() { // 部分支付订单过期，已支付数额保留
+			order.SetExpired()
+		}
+	}
+
 	return data
 }
 
@@ -249,8 +524,6 @@ func getConfirmingOrders(tradeType []string) []model.TradeOrders {
 	for _, order := range orders {
 		if time.Now().Unix() >= order.ExpiredAt.Unix() {
 			order.SetFailed()
-			notify.Bepusdt(order)
-			model.PushWebhookEvent(model.WebhookEventOrderFailed, order)
 
 			continue
 		}
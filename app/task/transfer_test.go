@@ -0,0 +1,260 @@
+package task
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/v03413/bepusdt/app/conf"
+	"github.com/v03413/bepusdt/app/model"
+	"github.com/v03413/bepusdt/app/model/modeltest"
+	"github.com/v03413/bepusdt/app/task/rate"
+)
+
+func TestCatchUpRecoverExpiredOrder(t *testing.T) {
+	var now = time.Now()
+	var cases = []struct {
+		name   string
+		paidAt time.Time
+		status int
+	}{
+		{"paid within original window", now.Add(-20 * time.Minute), model.OrderStatusConfirming},
+		{"paid after expiration", now.Add(-5 * time.Minute), model.OrderStatusLatePaid},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			modeltest.Setup(t)
+
+			var o = model.TradeOrders{
+				OrderId:   "o1",
+				TradeId:   "t1",
+				TradeHash: "t1",
+				TradeType: model.OrderTradeTypeUsdtTrc20,
+				Amount:    "10",
+				Address:   "TAddr",
+				Status:    model.OrderStatusExpired,
+				CreatedAt: now.Add(-30 * time.Minute),
+				ExpiredAt: now.Add(-10 * time.Minute),
+			}
+			if err := model.DB.Create(&o).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			handleOrderTransfers([]transfer{{
+				TxHash:      "hash1",
+				Amount:      decimal.NewFromInt(10),
+				RecvAddress: "TAddr",
+				FromAddress: "TFrom",
+				Timestamp:   c.paidAt,
+				TradeType:   model.OrderTradeTypeUsdtTrc20,
+				BlockNum:    100,
+			}})
+
+			// 确认任务扫描时，恢复的订单不能因原有效期已过被判定为确认超时
+			getConfirmingOrders(nil)
+
+			order, _ := model.GetTradeOrder("t1")
+			if order.Status != c.status {
+				t.Fatalf("unexpected status %s", model.OrderStatusName(order.Status))
+			}
+			if order.TradeHash != "hash1" {
+				t.Fatalf("unexpected trade hash %s", order.TradeHash)
+			}
+		})
+	}
+}
+
+func TestDroppedTransfersRecordedAsUnmatched(t *testing.T) {
+	var now = time.Now()
+	var cases = []struct {
+		name   string
+		amount decimal.Decimal
+		paidAt time.Time
+	}{
+		{"amount below payment_amount_min", decimal.RequireFromString("0.001"), now},
+		{"paid before order creation", decimal.NewFromInt(10), now.Add(-time.Hour)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			modeltest.Setup(t)
+			model.DB.Create(&model.WalletAddress{TradeType: model.OrderTradeTypeUsdtTrc20, Address: "TAddr", Status: model.StatusEnable})
+			model.DB.Create(&model.TradeOrders{
+				OrderId:   "o1",
+				TradeId:   "t1",
+				TradeHash: "t1",
+				TradeType: model.OrderTradeTypeUsdtTrc20,
+				Amount:    "10",
+				Address:   "TAddr",
+				Status:    model.OrderStatusWaiting,
+				CreatedAt: now.Add(-time.Minute),
+				ExpiredAt: now.Add(10 * time.Minute),
+			})
+
+			handleOrderTransfers([]transfer{{
+				TxHash:      "hash1",
+				Amount:      c.amount,
+				RecvAddress: "TAddr",
+				Timestamp:   c.paidAt,
+				TradeType:   model.OrderTradeTypeUsdtTrc20,
+			}})
+
+			if rows, total := model.GetUnmatchedPayments(1, 10); total != 1 || rows[0].TxHash != "hash1" {
+				t.Fatalf("transfer not recorded as unmatched, total %d", total)
+			}
+
+			if order, _ := model.GetTradeOrder("t1"); order.Status != model.OrderStatusWaiting {
+				t.Fatalf("unexpected status %s", model.OrderStatusName(order.Status))
+			}
+		})
+	}
+}
+
+func TestOpenAmountTransfer(t *testing.T) {
+	var now = time.Now()
+	var cases = []struct {
+		name      string
+		currency  string
+		expiredAt time.Time
+		paidAt    time.Time
+		status    int
+		money     float64
+		unmatched int64
+	}{
+		{"settled at current rate", "CNY", now.Add(10 * time.Minute), now, model.OrderStatusConfirming, 87.5, 0},
+		{"paid after expiration not settled", "CNY", now.Add(time.Minute), now.Add(2 * time.Minute), model.OrderStatusWaiting, 0, 1},
+		{"settle failure recorded as unmatched", "EUR", now.Add(10 * time.Minute), now, model.OrderStatusWaiting, 0, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			modeltest.Setup(t)
+			rate.SetUsdtCnyRate("", 7)
+
+			model.DB.Create(&model.WalletAddress{TradeType: model.OrderTradeTypeUsdtTrc20, Address: "TAddr", Status: model.StatusEnable})
+			model.DB.Create(&model.TradeOrders{
+				OrderId:    "o1",
+				TradeId:    "t1",
+				TradeHash:  "t1",
+				TradeType:  model.OrderTradeTypeUsdtTrc20,
+				Amount:     "0",
+				Currency:   c.currency,
+				Address:    "TAddr",
+				OpenAmount: true,
+				Status:     model.OrderStatusWaiting,
+				CreatedAt:  now.Add(-30 * time.Minute),
+				ExpiredAt:  c.expiredAt,
+			})
+
+			handleOrderTransfers([]transfer{{
+				TxHash:      "hash1",
+				Amount:      decimal.RequireFromString("12.5"),
+				RecvAddress: "TAddr",
+				Timestamp:   c.paidAt,
+				TradeType:   model.OrderTradeTypeUsdtTrc20,
+			}})
+
+			order, _ := model.GetTradeOrder("t1")
+			if order.Status != c.status || order.Money != c.money {
+				t.Fatalf("unexpected order status %s money %v", model.OrderStatusName(order.Status), order.Money)
+			}
+			if c.status == model.OrderStatusConfirming && order.Amount != "12.5" {
+				t.Fatalf("unexpected settled amount %s", order.Amount)
+			}
+
+			if _, total := model.GetUnmatchedPayments(1, 10); total != c.unmatched {
+				t.Fatalf("unmatched payments = %d, want %d", total, c.unmatched)
+			}
+		})
+	}
+}
+
+// setupOverpayTolerance 加载允许多付比例的配置，测试结束后恢复为不匹配多付
+func setupOverpayTolerance(t *testing.T, tolerance float64) {
+	t.Helper()
+
+	var load = func(tolerance float64) error {
+
+		return conf.Load([]byte(fmt.Sprintf("[bot]\ntoken = \"test\"\nadmin_id = 1\n\n[pay]\noverpay_tolerance = %v\n", tolerance)))
+	}
+	if err := load(tolerance); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = load(0) })
+}
+
+func TestGetOverpaidOrder(t *testing.T) {
+	var orders = map[string]model.TradeOrders{
+		"a": {TradeId: "t10", Amount: "10", Address: "TAddr", TradeType: model.OrderTradeTypeUsdtTrc20},
+		"b": {TradeId: "t10.5", Amount: "10.5", Address: "TAddr", TradeType: model.OrderTradeTypeUsdtTrc20},
+		"c": {TradeId: "other address", Amount: "10.9", Address: "TOther", TradeType: model.OrderTradeTypeUsdtTrc20},
+		"d": {TradeId: "other type", Amount: "10.9", Address: "TAddr", TradeType: model.OrderTradeTypeUsdcTrc20},
+	}
+
+	var cases = []struct {
+		name      string
+		tolerance float64
+		amount    string
+		want      string
+	}{
+		{"closest order below payment", 0.1, "10.6", "t10.5"},
+		{"within tolerance of larger order", 0.1, "11.55", "t10.5"},
+		{"only smaller order within tolerance", 0.1, "10.4", "t10"},
+		{"beyond tolerance", 0.1, "11.6", ""},
+		{"exact amount not overpaid", 0.1, "10", ""},
+		{"underpaid", 0.1, "9.9", ""},
+		{"overpay disabled", 0, "10.6", ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setupOverpayTolerance(t, c.tolerance)
+
+			o, ok := getOverpaidOrder(orders, transfer{RecvAddress: "TAddr", Amount: decimal.RequireFromString(c.amount), TradeType: model.OrderTradeTypeUsdtTrc20})
+			if ok != (c.want != "") || o.TradeId != c.want {
+				t.Fatalf("matched %q %v, want %q", o.TradeId, ok, c.want)
+			}
+		})
+	}
+}
+
+// 多付的订单按实际支付数额记录收款，订单数额保持不变
+func TestOverpaidTransfer(t *testing.T) {
+	modeltest.Setup(t)
+	setupOverpayTolerance(t, 0.1)
+
+	var now = time.Now()
+	model.DB.Create(&model.WalletAddress{TradeType: model.OrderTradeTypeUsdtTrc20, Address: "TAddr", Status: model.StatusEnable})
+	model.DB.Create(&model.TradeOrders{
+		OrderId:   "o1",
+		TradeId:   "t1",
+		TradeHash: "t1",
+		TradeType: model.OrderTradeTypeUsdtTrc20,
+		Amount:    "10",
+		Address:   "TAddr",
+		Status:    model.OrderStatusWaiting,
+		CreatedAt: now.Add(-time.Minute),
+		ExpiredAt: now.Add(10 * time.Minute),
+	})
+
+	handleOrderTransfers([]transfer{{
+		TxHash:      "hash1",
+		Amount:      decimal.RequireFromString("10.5"),
+		RecvAddress: "TAddr",
+		FromAddress: "TFrom",
+		Timestamp:   now,
+		TradeType:   model.OrderTradeTypeUsdtTrc20,
+		BlockNum:    100,
+	}})
+
+	order, _ := model.GetTradeOrder("t1")
+	if order.Status != model.OrderStatusConfirming || order.TradeHash != "hash1" {
+		t.Fatalf("unexpected status %s hash %s", model.OrderStatusName(order.Status), order.TradeHash)
+	}
+	if order.Amount != "10" || order.PaidAmount != "10.5" || order.ActualAmount != "10.5" {
+		t.Fatalf("unexpected amount %s paid %s actual %s", order.Amount, order.PaidAmount, order.ActualAmount)
+	}
+}
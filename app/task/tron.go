@@ -41,7 +41,6 @@ var grpcParams = grpc.ConnectParams{
 type tron struct {
 	blockConfirmedOffset int64
 	blockInitStartOffset int64
-	lastBlockNum         int64
 	blockScanQueue       *chanx.UnboundedChan[int64]
 }
 
@@ -58,19 +57,22 @@ func newTron() tron {
 	return tron{
 		blockConfirmedOffset: 30,   // 区块确认偏移量
 		blockInitStartOffset: -400, // 大概为过去20分钟的区块高度
-		lastBlockNum:         0,
 		blockScanQueue:       chanx.NewUnboundedChan[int64](context.Background(), 30),
 	}
 }
 
 func (t *tron) blockRoll(context.Context) {
+	var cur = getScanCursor(conf.Tron)
 	if t.rollBreak() {
+		cur.reset()
 
 		return
 	}
 
-	conn, err := grpc.NewClient(conf.GetTronGrpcNode(), grpc.WithConnectParams(grpcParams), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	var ep = conf.PickEndpoint(conf.Tron)
+	conn, err := grpc.NewClient(ep.URL, grpc.WithConnectParams(grpcParams), grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
+		ep.Done(err)
 		log.Error("grpc.NewClient", err)
 
 		return
@@ -84,6 +86,7 @@ func (t *tron) blockRoll(context.Context) {
 	block, err1 := client.GetNowBlock2(ctx, nil)
 	defer cancel()
 
+	ep.Done(err1)
 	if err1 != nil {
 		log.Warn("GetNowBlock2 超时：", err1)
 
@@ -95,33 +98,24 @@ func (t *tron) blockRoll(context.Context) {
 		now = now - t.blockConfirmedOffset
 	}
 
-	// 区块高度变化过大，强制丢块重扫
-	if now-t.lastBlockNum > conf.BlockHeightMaxDiff {
+	// 无扫描进度，回溯扫描；差值过大时进入追赶模式
+	ranges, fresh := cur.next(now, conf.BlockHeightMaxDiff, 1)
+	if fresh {
 		t.blockInitOffset(now)
-		t.lastBlockNum = now - 1
-	}
-
-	// 区块高度没有变化
-	if now == t.lastBlockNum {
 
 		return
 	}
 
 	// 待扫描区块入列
-	for n := t.lastBlockNum + 1; n <= now; n++ {
-
-		t.blockScanQueue.In <- n
+	for _, r := range ranges {
+		t.blockScanQueue.In <- r.From
 	}
-
-	t.lastBlockNum = now
 }
 
 func (t *tron) blockDispatch(context.Context) {
 	p, err := ants.NewPoolWithFunc(3, t.blockParse)
 	if err != nil {
 		panic(err)
-
-		return
 	}
 
 	defer p.Release()
@@ -137,10 +131,12 @@ func (t *tron) blockDispatch(context.Context) {
 
 func (t *tron) blockParse(n any) {
 	var num = n.(int64)
-	var node = conf.GetTronGrpcNode()
+	var ep = conf.PickEndpoint(conf.Tron)
 	var conn *grpc.ClientConn
 	var err error
-	if conn, err = grpc.NewClient(node, grpc.WithConnectParams(grpcParams), grpc.WithTransportCredentials(insecure.NewCredentials())); err != nil {
+	if conn, err = grpc.NewClient(ep.URL, grpc.WithConnectParams(grpcParams), grpc.WithTransportCredentials(insecure.NewCredentials())); err != nil {
+		ep.Done(err)
+		t.blockScanQueue.In <- num
 		log.Error("grpc.NewClient", err)
 
 		return
@@ -154,6 +150,7 @@ func (t *tron) blockParse(n any) {
 	var ctx, cancel = context.WithTimeout(context.Background(), time.Second*5)
 	bok, err2 := client.GetBlockByNum2(ctx, &api.NumberMessage{Num: num})
 	cancel()
+	ep.Done(err2)
 	if err2 != nil {
 		conf.SetBlockFail(conf.Tron)
 		t.blockScanQueue.In <- num
@@ -191,6 +188,7 @@ func (t *tron) blockParse(n any) {
 					FromAddress:  t.base58CheckEncode(foo.OwnerAddress),
 					RecvAddress:  t.base58CheckEncode(foo.ReceiverAddress),
 					Timestamp:    timestamp,
+					BlockNum:     num,
 				})
 			}
 
@@ -211,6 +209,7 @@ func (t *tron) blockParse(n any) {
 					FromAddress:  t.base58CheckEncode(foo.OwnerAddress),
 					RecvAddress:  t.base58CheckEncode(foo.ReceiverAddress),
 					Timestamp:    timestamp,
+					BlockNum:     num,
 				})
 			}
 
@@ -318,11 +317,12 @@ func (t *tron) blockParse(n any) {
 		resourceQueue.In <- resources
 	}
 
+	getScanCursor(conf.Tron).done(num)
 	log.Debug("区块扫描完成", num, conf.GetBlockSuccRate(conf.Tron), conf.Tron)
 }
 
 func (t *tron) blockInitOffset(now int64) {
-	if now == 0 || t.lastBlockNum != 0 {
+	if now == 0 {
 
 		return
 	}
@@ -405,7 +405,7 @@ func (t *tron) tradeConfirmHandle(ctx context.Context) {
 	var wg sync.WaitGroup
 
 	var handle = func(o model.TradeOrders) {
-		conn, err := grpc.NewClient(conf.GetTronGrpcNode(), grpc.WithConnectParams(grpcParams), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		conn, err := grpc.NewClient(conf.GetRpcEndpoint(conf.Tron), grpc.WithConnectParams(grpcParams), grpc.WithTransportCredentials(insecure.NewCredentials()))
 		if err != nil {
 			log.Error("grpc.NewClient", err)
 
@@ -474,7 +474,7 @@ func (t *tron) base58CheckEncode(input []byte) string {
 func (t *tron) rollBreak() bool {
 	var count int64 = 0
 	trade := []string{model.OrderTradeTypeTronTrx, model.OrderTradeTypeUsdtTrc20, model.OrderTradeTypeUsdcTrc20}
-	model.DB.Model(&model.TradeOrders{}).Where("status = ? and trade_type in (?)", model.OrderStatusWaiting, trade).Count(&count)
+	model.DB.Model(&model.TradeOrders{}).Where("status in (?) and trade_type in (?)", model.OrderOpenStatus, trade).Count(&count)
 	if count > 0 {
 
 		return false
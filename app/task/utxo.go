@@ -0,0 +1,227 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cast"
+	"github.com/tidwall/gjson"
+	"github.com/v03413/bepusdt/app/conf"
+	"github.com/v03413/bepusdt/app/log"
+	"github.com/v03413/bepusdt/app/model"
+)
+
+// 参考文档
+//  - https://github.com/Blockstream/esplora/blob/master/API.md
+
+// BTC LTC 等 UTXO 网络区块数据量大且地址格式繁多，这里不扫描区块，而是通过 Esplora 接口轮询监控地址的最近交易
+
+const (
+	utxoTxMaxAge      = time.Hour * 24   // 超过此时间的历史交易不再处理
+	utxoDroppedExpire = time.Minute * 30 // 内存池交易超过此时间仍查询不到，视为被替换或丢弃
+)
+
+type utxo struct {
+	Network   string
+	TradeType string
+	api       string   // 接口地址，为空则使用配置节点；单元测试时可替换为本地接口
+	seen      sync.Map // 已处理的交易 txid:address => time.Time
+}
+
+func init() {
+	for _, u := range []*utxo{
+		{Network: conf.Bitcoin, TradeType: model.OrderTradeTypeBtcBitcoin},
+		{Network: conf.Litecoin, TradeType: model.OrderTradeTypeLtcLitecoin},
+	} {
+		register(task{callback: u.addressRoll, duration: time.Second * 15})
+		register(task{callback: u.tradeConfirmHandle, duration: time.Second * 30})
+	}
+}
+
+func (u *utxo) call(ctx context.Context, path string) ([]byte, error) {
+	if u.api != "" {
+
+		return rpcDo(ctx, u.api+path, nil)
+	}
+
+	return rpcCall(ctx, u.Network, path, nil)
+}
+
+func (u *utxo) addressRoll(ctx context.Context) {
+	if rollBreak(u.Network) {
+
+		return
+	}
+
+	u.seen.Range(func(k, v any) bool {
+		if time.Since(v.(time.Time)) > utxoTxMaxAge {
+			u.seen.Delete(k)
+		}
+
+		return true
+	})
+
+	var transfers = make([]transfer, 0)
+	for _, addr := range utxoWatchAddress(u.TradeType) {
+		conf.SetBlockTotal(u.Network)
+		body, err := u.call(ctx, "address/"+addr+"/txs")
+		if err != nil {
+			conf.SetBlockFail(u.Network)
+			log.Warn(u.Network, "addressRoll Error:", err)
+
+			continue
+		}
+
+		transfers = append(transfers, u.parseTransfer(addr, body)...)
+	}
+
+	if len(transfers) > 0 {
+
+		transferQueue.In <- transfers
+	}
+
+	log.Debug("地址交易查询完成", conf.GetBlockSuccRate(u.Network), u.Network)
+}
+
+// parseTransfer 解析地址最近交易(内存池交易 + 最近已确认交易)，仅处理转入该地址的交易
+func (u *utxo) parseTransfer(addr string, body []byte) []transfer {
+	var result = make([]transfer, 0)
+	for _, tx := range gjson.ParseBytes(body).Array() {
+		txid := tx.Get("txid").String()
+		key := txid + ":" + addr
+		if _, ok := u.seen.Load(key); ok {
+
+			continue
+		}
+
+		var num int64 = 0
+		var timestamp = time.Now()
+		if tx.Get("status.confirmed").Bool() {
+			num = tx.Get("status.block_height").Int()
+			timestamp = time.Unix(tx.Get("status.block_time").Int(), 0)
+		}
+
+		if time.Since(timestamp) > utxoTxMaxAge {
+
+			continue
+		}
+
+		var sats int64 = 0
+		for _, out := range tx.Get("vout").Array() {
+			if out.Get("scriptpubkey_address").String() == addr {
+				sats += out.Get("value").Int()
+			}
+		}
+
+		u.seen.Store(key, time.Now())
+		if sats <= 0 {
+
+			continue
+		}
+
+		result = append(result, transfer{
+			Network:     u.Network,
+			TxHash:      txid,
+			Amount:      decimal.New(sats, conf.UtxoDecimals),
+			FromAddress: tx.Get("vin.0.prevout.scriptpubkey_address").String(),
+			RecvAddress: addr,
+			Timestamp:   timestamp,
+			TradeType:   u.TradeType,
+			BlockNum:    num,
+		})
+	}
+
+	return result
+}
+
+// utxoWatchAddress 收款地址及等待支付订单的地址
+func utxoWatchAddress(tradeType string) []string {
+	var addrs = make([]string, 0)
+	var more = make([]string, 0)
+	model.DB.Model(&model.WalletAddress{}).Where("trade_type = ?", tradeType).Pluck("address", &addrs)
+	model.DB.Model(&model.TradeOrders{}).Where("status in (?) and trade_type = ?", model.OrderOpenStatus, tradeType).Pluck("address", &more)
+
+	var exists = make(map[string]bool)
+	var result = make([]string, 0)
+	for _, addr := range append(addrs, more...) {
+		if !exists[addr] {
+			exists[addr] = true
+			result = append(result, addr)
+		}
+	}
+
+	return result
+}
+
+// tradeConfirmHandle 出块较慢，确认耗时通常超过订单有效期，所以不使用 getConfirmingOrders 的超时失败逻辑，交易丢失时回滚订单
+func (u *utxo) tradeConfirmHandle(ctx context.Context) {
+	var orders []model.TradeOrders
+	model.DB.Where("status = ? and trade_type = ?", model.OrderStatusConfirming, u.TradeType).Find(&orders)
+	if len(orders) == 0 {
+
+		return
+	}
+
+	body, err := u.call(ctx, "blocks/tip/height")
+	if err != nil {
+		log.Warn(u.Network, "tradeConfirmHandle Error:", err)
+
+		return
+	}
+
+	var tip = cast.ToInt64(string(body))
+	for _, o := range orders {
+		status, err := u.call(ctx, "tx/"+o.TradeHash+"/status")
+		if errors.Is(err, errRpcNotFound) {
+			u.dropped(o)
+
+			continue
+		}
+
+		if err != nil {
+			log.Warn(u.Network, "tradeConfirmHandle Error:", err)
+
+			continue
+		}
+
+		if !gjson.GetBytes(status, "confirmed").Bool() {
+
+			continue
+		}
+
+		height := gjson.GetBytes(status, "block_height").Int()
+		if o.RefBlockNum != height {
+			if err := o.SetRefBlockNum(height); err != nil {
+				log.Warn(u.Network, "订单区块高度更新失败：", o.TradeId, err)
+
+				continue
+			}
+		}
+
+		if tip-height+1 >= conf.GetUtxoConfirmations(u.Network) {
+
+			markFinalConfirmed(o)
+		}
+	}
+}
+
+// dropped 内存池交易被替换(RBF)或丢弃，订单回滚至等待支付
+func (u *utxo) dropped(o model.TradeOrders) {
+	if time.Since(o.ConfirmedAt) < utxoDroppedExpire {
+
+		return
+	}
+
+	var hash = o.TradeHash
+	if err := o.RollbackWaiting(); err != nil {
+		log.Warn(u.Network, "tradeConfirmHandle Error:", err)
+
+		return
+	}
+
+	log.Warn(fmt.Sprintf("%s 订单 %s 交易 %s 已被替换或丢弃，回滚至等待支付", u.Network, o.TradeId, hash))
+}
@@ -0,0 +1,37 @@
+package task
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/v03413/bepusdt/app/conf"
+	"github.com/v03413/bepusdt/app/model"
+)
+
+func TestUtxoParseTransfer(t *testing.T) {
+	var addr = "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"
+	var body = []byte(fmt.Sprintf(`[
+  {"txid":"aa01","status":{"confirmed":false},"vin":[{"prevout":{"scriptpubkey_address":"1BoatSLRHtKNngkdXEeobR76b53LETtpyT"}}],
+   "vout":[{"scriptpubkey_address":"%s","value":125000},{"scriptpubkey_address":"1BoatSLRHtKNngkdXEeobR76b53LETtpyT","value":9000}]},
+  {"txid":"aa02","status":{"confirmed":true,"block_height":800000,"block_time":%d},"vin":[{"prevout":{"scriptpubkey_address":"%s"}}],
+   "vout":[{"scriptpubkey_address":"1BoatSLRHtKNngkdXEeobR76b53LETtpyT","value":5000}]},
+  {"txid":"aa03","status":{"confirmed":true,"block_height":700000,"block_time":1600000000},"vin":[],
+   "vout":[{"scriptpubkey_address":"%s","value":5000}]}
+]`, addr, time.Now().Unix(), addr, addr))
+
+	u := utxo{Network: conf.Bitcoin, TradeType: model.OrderTradeTypeBtcBitcoin}
+	res := u.parseTransfer(addr, body)
+	if len(res) != 1 {
+		t.Fatalf(`unexpected transfers %d`, len(res))
+	}
+
+	if res[0].Amount.String() != "0.00125" || res[0].TxHash != "aa01" || res[0].BlockNum != 0 || res[0].RecvAddress != addr {
+		t.Fatalf(`unexpected transfer %+v`, res[0])
+	}
+
+	// 同一交易不重复处理
+	if res = u.parseTransfer(addr, body); len(res) != 0 {
+		t.Fatalf(`duplicate transfers %d`, len(res))
+	}
+}
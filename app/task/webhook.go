@@ -1,41 +1,37 @@
 package task
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/v03413/bepusdt/app"
 	"github.com/v03413/bepusdt/app/log"
-	"github.com/v03413/bepusdt/app/model"
 )
 
-func init() {
-	register(task{callback: webhookRoll})
-}
+// webhookPost 推送 Webhook 事件，响应状态码非 200 视为失败，由发件箱按退避时间重试；未配置地址时直接忽略
+func webhookPost(url, event string, data json.RawMessage) error {
+	if url == "" {
 
-func webhookRoll(ctx context.Context) {
-	var w model.Webhook
-	var ticker = time.NewTicker(time.Minute)
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			model.ListWaitWebhooks()
-		case w = <-model.WebhookHandleQueue.Out:
-			go webhookHandle(w)
-		}
+		return nil
 	}
-}
 
-func webhookHandle(w model.Webhook) {
-	var req, err = http.NewRequest("POST", w.Url, strings.NewReader(w.PostData()))
+	body, err := json.Marshal(map[string]any{"event": event, "data": data})
+	if err != nil {
+
+		return err
+	}
+
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
 
-		return
+		return err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -43,21 +39,18 @@ func webhookHandle(w model.Webhook) {
 	req.Header.Set("User-Agent", "BEpusdt/"+app.Version)
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Warn("Webhook request failed:", err.Error())
 
-		return
+		return err
 	}
 
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		w.SetStatus(model.WebhookStatusFail)
-		log.Warn("Webhook request failed with status code:", resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
 
-		return
+		return fmt.Errorf("webhook 响应状态码：%d", resp.StatusCode)
 	}
 
-	w.SetStatus(model.WebhookStatusSucc)
+	log.Info("Webhook 推送成功：", event, url)
 
-	log.Info("Webhook request success:", w.Event, "to", w.Url)
+	return nil
 }
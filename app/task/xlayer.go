@@ -11,8 +11,7 @@ import (
 func xlayerInit() {
 	ctx := context.Background()
 	xlayer := evm{
-		Network:  conf.Xlayer,
-		Endpoint: conf.GetXlayerRpcEndpoint(),
+		Network: conf.Xlayer,
 		Block: block{
 			InitStartOffset: -600,
 			RollDelayOffset: 3,
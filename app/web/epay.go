@@ -8,6 +8,7 @@ import (
 	"github.com/spf13/cast"
 	"github.com/v03413/bepusdt/app/conf"
 	"github.com/v03413/bepusdt/app/model"
+	"github.com/v03413/bepusdt/app/task/rate"
 	"github.com/v03413/bepusdt/app/web/epay"
 )
 
@@ -35,13 +36,14 @@ func epaySubmit(ctx *gin.Context) {
 		data[k] = v[0]
 	}
 
-	if data["pid"] != epay.Pid {
-		ctx.String(200, conf.GetAppName()+" 易支付兼容模式，商户号【PID】必须固定为"+epay.Pid)
+	merchant, ok := model.GetMerchantByPid(data["pid"])
+	if !ok || data["pid"] == "" {
+		ctx.String(200, conf.GetAppName()+" 易支付兼容模式，商户号【PID】不存在")
 
 		return
 	}
 
-	if epay.Sign(data, conf.GetAuthToken()) != data["sign"] {
+	if epay.Sign(data, merchant.Secret) != data["sign"] {
 		ctx.String(200, "签名错误")
 
 		return
@@ -53,8 +55,15 @@ func epaySubmit(ctx *gin.Context) {
 		tradeType = cast.ToString(v)
 	}
 
+	if tradeType != model.OrderTradeTypeAny && !merchant.AllowTradeType(tradeType) {
+		ctx.String(200, fmt.Sprintf("交易类型(%s)不支持", tradeType))
+
+		return
+	}
+
 	var params = orderParams{
 		Money:       cast.ToFloat64(data["money"]),
+		Currency:    rate.FiatCNY,
 		ApiType:     model.OrderApiTypeEpay,
 		PayAddress:  "",
 		OrderId:     data["out_trade_no"],
@@ -62,6 +71,8 @@ func epaySubmit(ctx *gin.Context) {
 		RedirectUrl: data["return_url"],
 		NotifyUrl:   data["notify_url"],
 		Name:        data["name"],
+		MerchantId:  merchant.ID,
+		TokenAmount: cast.ToFloat64(data["token_amount"]),
 	}
 
 	var order, err = buildOrder(params)
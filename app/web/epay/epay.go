@@ -5,15 +5,12 @@ import (
 	"encoding/hex"
 	"fmt"
 	"github.com/spf13/cast"
-	"github.com/v03413/bepusdt/app/conf"
 	"github.com/v03413/bepusdt/app/help"
 	"github.com/v03413/bepusdt/app/model"
 	"net/url"
 	"sort"
 )
 
-const Pid = "1000" // 固定商户号
-
 func Sign(params map[string]string, key string) string {
 	// 提取 keys 并排序
 	var keys = make([]string, 0, len(params))
@@ -42,10 +39,11 @@ func Sign(params map[string]string, key string) string {
 }
 
 func BuildNotifyParams(order model.TradeOrders) string {
+	var merchant = model.GetMerchant(order.MerchantId)
 	var sign = help.Md5String(fmt.Sprintf("money=%s&name=%s&out_trade_no=%s&pid=%s&trade_no=%s&trade_status=TRADE_SUCCESS&type=%s",
-		cast.ToString(order.Money), order.Name, order.OrderId, Pid, order.TradeId, order.TradeType) + conf.GetAuthToken())
+		cast.ToString(order.Money), order.Name, order.OrderId, merchant.Pid, order.TradeId, order.TradeType) + merchant.Secret)
 	var params = fmt.Sprintf("money=%s&name=%s&out_trade_no=%s&pid=%s&trade_no=%s&trade_status=TRADE_SUCCESS&type=%s",
-		cast.ToString(order.Money), url.QueryEscape(order.Name), url.QueryEscape(order.OrderId), Pid, order.TradeId, order.TradeType)
+		cast.ToString(order.Money), url.QueryEscape(order.Name), url.QueryEscape(order.OrderId), merchant.Pid, order.TradeId, order.TradeType)
 
 	return fmt.Sprintf("%s&sign=%s", params, sign)
 }
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -13,6 +15,7 @@ import (
 	"github.com/v03413/bepusdt/app/help"
 	"github.com/v03413/bepusdt/app/log"
 	"github.com/v03413/bepusdt/app/model"
+	"github.com/v03413/bepusdt/app/task/rate"
 	"github.com/v03413/bepusdt/app/web/epay"
 )
 
@@ -44,10 +47,19 @@ func signVerify(ctx *gin.Context) {
 		return
 	}
 
-	gens := help.EpusdtSign(m, conf.GetAuthToken())
+	merchant, ok := model.GetMerchantByPid(cast.ToString(m["pid"]))
+	if !ok {
+		log.Warnf("商户不存在 %#v", m)
+		ctx.JSON(400, gin.H{"error": "商户不存在"})
+		ctx.Abort()
+
+		return
+	}
+
+	gens := help.EpusdtSign(m, merchant.Secret)
 	if gens != sign {
 		log.Warnf("签名错误 %#v", m)
-		//log.Warnf("%s(token=%q) != %s", gens, conf.GetAuthToken(), sign)
+		//log.Warnf("%s(token=%q) != %s", gens, merchant.Secret, sign)
 		ctx.JSON(400, gin.H{"error": "签名错误"})
 		ctx.Abort()
 
@@ -55,13 +67,40 @@ func signVerify(ctx *gin.Context) {
 	}
 
 	ctx.Set("data", m)
+	ctx.Set("merchant", merchant)
+}
+
+// adminVerify 仅允许默认商户调用的管理接口
+func adminVerify(ctx *gin.Context) {
+	if !getMerchant(ctx).IsDefault() {
+		ctx.JSON(403, gin.H{"error": "无权访问"})
+		ctx.Abort()
+
+		return
+	}
+}
+
+func getMerchant(ctx *gin.Context) model.Merchant {
+
+	return ctx.MustGet("merchant").(model.Merchant)
+}
+
+// getMerchantOrder 查询当前商户的订单，其它商户的订单视为不存在
+func getMerchantOrder(ctx *gin.Context, tradeId string) (model.TradeOrders, bool) {
+	order, ok := model.GetTradeOrder(tradeId)
+	if !ok || order.MerchantId != getMerchant(ctx).ID {
+
+		return model.TradeOrders{}, false
+	}
+
+	return order, true
 }
 
 func createTransaction(ctx *gin.Context) {
 	var address string
 	data := ctx.GetStringMap("data")
 	var timeout uint64 = 0
-	for _, key := range []string{"order_id", "amount", "notify_url", "redirect_url"} {
+	for _, key := range []string{"order_id", "notify_url", "redirect_url"} {
 		if _, ok := data[key]; !ok {
 			log.Warn(fmt.Sprintf("参数 %s 不存在", key), data)
 			ctx.JSON(200, respFailJson(fmt.Sprintf("参数 %s 不存在", key)))
@@ -70,6 +109,17 @@ func createTransaction(ctx *gin.Context) {
 		}
 	}
 
+	// amount 法币金额与 token_amount 代币数额二选一，不定额订单无需金额
+	_, hasAmount := data["amount"]
+	tokenAmount := cast.ToFloat64(data["token_amount"])
+	openAmount := cast.ToBool(data["open_amount"])
+	if !hasAmount && tokenAmount <= 0 && !openAmount {
+		log.Warn("参数 amount 不存在", data)
+		ctx.JSON(200, respFailJson("参数 amount 不存在"))
+
+		return
+	}
+
 	//log.Infof(`post: %#[1]v %[2]v(%[2]T)`, data, data["timestamp"])
 	if v, ok := data["timestamp"]; ok {
 		timestamp := cast.ToInt64(fmt.Sprintf(`%0.f`, v))
@@ -86,20 +136,36 @@ func createTransaction(ctx *gin.Context) {
 		tradeType = model.OrderTradeTypeUsdtTrc20 // 默认 USDT TRC20
 	}
 
-	if !help.InStrings(tradeType, model.SupportTradeTypes) {
+	// any 由用户在收银台选择网络
+	if tradeType != model.OrderTradeTypeAny && (!help.InStrings(tradeType, model.SupportTradeTypes) || !getMerchant(ctx).AllowTradeType(tradeType)) {
 		ctx.JSON(200, respFailJson(fmt.Sprintf("交易类型(%s)不支持", tradeType)))
 
 		return
 	}
 
+	// 代币计价订单的法币即代币本身，忽略 currency 参数
+	var err error
+	var currency = rate.FiatCNY
+	if tokenAmount <= 0 {
+		if currency, err = parseCurrency(data); err != nil {
+			ctx.JSON(200, respFailJson(err.Error()))
+
+			return
+		}
+	}
+
 	if v, ok := data["timeout"]; ok {
 		timeout = cast.ToUint64(v)
 	}
-	if address, ok := data["address"].(string); ok && address != "" {
+	if v, ok := data["address"].(string); ok && v != "" {
+		address = v
 		if !help.IsValidTronAddress(address) &&
 			!help.IsValidEvmAddress(address) &&
 			!help.IsValidSolanaAddress(address) &&
-			!help.IsValidAptosAddress(address) {
+			!help.IsValidAptosAddress(address) &&
+			!help.IsValidTonAddress(address) &&
+			!help.IsValidBitcoinAddress(address) &&
+			!help.IsValidLitecoinAddress(address) {
 			ctx.JSON(200, respFailJson(fmt.Sprintf("收款钱包地址(%s)不合法", address)))
 
 			return
@@ -115,6 +181,7 @@ func createTransaction(ctx *gin.Context) {
 	orderId := cast.ToString(data["order_id"])
 	params := orderParams{
 		Money:       cast.ToFloat64(data["amount"]),
+		Currency:    currency,
 		ApiType:     model.OrderApiTypeEpusdt,
 		PayAddress:  address,
 		OrderId:     orderId,
@@ -124,6 +191,32 @@ func createTransaction(ctx *gin.Context) {
 		Name:        orderId,
 		Timeout:     timeout,
 		Rate:        cast.ToString(data["rate"]),
+		MerchantId:  getMerchant(ctx).ID,
+		TokenAmount: tokenAmount,
+		OpenAmount:  openAmount,
+	}
+
+	// 凭报价下单，按报价锁定的汇率计算；代币计价订单无需汇率
+	if quoteId := cast.ToString(data["quote_id"]); quoteId != "" {
+		if tokenAmount > 0 {
+			ctx.JSON(200, respFailJson("代币计价订单不支持报价"))
+
+			return
+		}
+
+		if openAmount {
+			ctx.JSON(200, respFailJson("不定额订单不支持报价"))
+
+			return
+		}
+
+		if params.Rate, err = getQuoteRate(quoteId, params); err != nil {
+			ctx.JSON(200, respFailJson(err.Error()))
+
+			return
+		}
+
+		params.QuoteId = quoteId
 	}
 
 	order, err := buildOrder(params)
@@ -139,7 +232,9 @@ func createTransaction(ctx *gin.Context) {
 		"order_id":        order.OrderId,
 		"status":          order.Status,
 		"amount":          order.Money,
+		"currency":        order.Currency,
 		"token_amount":    help.Atof(order.Amount),
+		"open_amount":     order.OpenAmount,
 		"token":           order.Address,
 		"expiration_time": uint64(time.Until(order.ExpiredAt).Seconds()),
 		"payment_url":     fmt.Sprintf("%s/pay/checkout-counter/%s", conf.GetAppUri(host), order.TradeId),
@@ -156,7 +251,7 @@ func cancelTransaction(ctx *gin.Context) {
 		return
 	}
 
-	order, ok2 := model.GetTradeOrder(tradeId)
+	order, ok2 := getMerchantOrder(ctx, tradeId)
 	if !ok2 {
 		ctx.JSON(200, respFailJson("订单不存在"))
 
@@ -175,11 +270,35 @@ func cancelTransaction(ctx *gin.Context) {
 		return
 	}
 
-	model.PushWebhookEvent(model.WebhookEventOrderCancel, order)
-
 	ctx.JSON(200, respSuccJson(gin.H{"trade_id": tradeId}))
 }
 
+// acceptLatePayment 确认订单过期后收到的支付
+func acceptLatePayment(ctx *gin.Context) {
+	data := ctx.GetStringMap("data")
+	tradeId, ok := data["trade_id"].(string)
+	if !ok {
+		ctx.JSON(200, respFailJson("参数 trade_id 不存在"))
+
+		return
+	}
+
+	order, ok2 := getMerchantOrder(ctx, tradeId)
+	if !ok2 {
+		ctx.JSON(200, respFailJson("订单不存在"))
+
+		return
+	}
+
+	if err := order.AcceptLatePaid(model.ActorApi); err != nil {
+		ctx.JSON(200, respFailJson(fmt.Sprintf("订单(%s)确认收款失败：%s", tradeId, err.Error())))
+
+		return
+	}
+
+	ctx.JSON(200, respSuccJson(gin.H{"trade_id": tradeId, "status": order.Status}))
+}
+
 var AssetVer = app.Version
 
 func checkoutCounter(ctx *gin.Context) {
@@ -199,6 +318,13 @@ func checkoutCounter(ctx *gin.Context) {
 		return
 	}
 
+	// 尚未选择网络，展示网络选择页
+	if order.TradeType == model.OrderTradeTypeAny {
+		renderNetworkPicker(ctx, order, "")
+
+		return
+	}
+
 	// 获取支付配置
 	paymentConfig := GetPaymentConfig(order.TradeType)
 
@@ -206,6 +332,8 @@ func checkoutCounter(ctx *gin.Context) {
 	templateData := gin.H{
 		"http_host":  uri.Host,
 		"amount":     order.Amount,
+		"money":      order.Money,
+		"currency":   order.Currency,
 		"address":    order.Address,
 		"expire":     int64(time.Until(order.ExpiredAt).Seconds()),
 		"return_url": order.ReturnUrl,
@@ -213,6 +341,8 @@ func checkoutCounter(ctx *gin.Context) {
 		"trade_id":   tradeId,
 		"order_id":   order.OrderId,
 		"trade_type": order.TradeType,
+		"selectable": order.Selectable && order.Status == model.OrderStatusWaiting,
+		"open":       order.OpenAmount && order.Status == model.OrderStatusWaiting,
 		"pay": gin.H{ // 支付配置
 			"coin":              paymentConfig.Coin,
 			"network":           paymentConfig.Network,
@@ -228,6 +358,117 @@ func checkoutCounter(ctx *gin.Context) {
 	ctx.HTML(200, "payment.html", templateData)
 }
 
+// chooseNetworkPage 收银台切换网络
+func chooseNetworkPage(ctx *gin.Context) {
+	order, ok := model.GetTradeOrder(ctx.Param("trade_id"))
+	if !ok {
+		ctx.String(200, "订单不存在")
+
+		return
+	}
+
+	if !order.Selectable {
+		ctx.Redirect(302, "/pay/checkout-counter/"+order.TradeId)
+
+		return
+	}
+
+	renderNetworkPicker(ctx, order, "")
+}
+
+// chooseNetwork 用户在收银台选择网络，按所选交易类型分配收款地址及数额，交易ID保持不变
+func chooseNetwork(ctx *gin.Context) {
+	order, ok := model.GetTradeOrder(ctx.Param("trade_id"))
+	if !ok {
+		ctx.String(200, "订单不存在")
+
+		return
+	}
+
+	if !order.Selectable || order.Status != model.OrderStatusWaiting {
+		renderNetworkPicker(ctx, order, "当前订单状态不允许选择网络")
+
+		return
+	}
+
+	tradeType := ctx.PostForm("trade_type")
+	if !help.InStrings(tradeType, getSelectableTradeTypes(order.MerchantId)) {
+		renderNetworkPicker(ctx, order, fmt.Sprintf("网络(%s)不可用", tradeType))
+
+		return
+	}
+
+	params := orderParams{
+		Money:       order.Money,
+		Currency:    order.Currency,
+		ApiType:     order.ApiType,
+		OrderId:     order.OrderId,
+		TradeType:   tradeType,
+		RedirectUrl: order.ReturnUrl,
+		NotifyUrl:   order.NotifyUrl,
+		Name:        order.Name,
+		MerchantId:  order.MerchantId,
+		Selectable:  true,
+		OpenAmount:  order.OpenAmount,
+	}
+	if _, err := rebuildOrder(order, params, model.ActorCheckout, "选择网络："+tradeType); err != nil {
+		log.Warn(fmt.Sprintf("订单(%s)选择网络失败：%s", order.TradeId, err.Error()))
+		renderNetworkPicker(ctx, order, "网络选择失败，请稍后重试或更换其它网络")
+
+		return
+	}
+
+	ctx.Redirect(303, "/pay/checkout-counter/"+order.TradeId)
+}
+
+func renderNetworkPicker(ctx *gin.Context, order model.TradeOrders, errMsg string) {
+	var networks = make([]RespNetwork, 0)
+	for _, tradeType := range getSelectableTradeTypes(order.MerchantId) {
+		c := GetPaymentConfig(tradeType)
+		networks = append(networks, RespNetwork{
+			Value: tradeType,
+			Label: fmt.Sprintf("%s · %s", c.Coin, c.Network),
+		})
+	}
+
+	ctx.HTML(200, "network.html", gin.H{
+		"money":     order.Money,
+		"currency":  order.Currency,
+		"order_id":  order.OrderId,
+		"trade_id":  order.TradeId,
+		"networks":  networks,
+		"open":      order.OpenAmount,
+		"error":     errMsg,
+		"home_url":  conf.GetConfig().HomeURL,
+		"app_name":  conf.GetConfig().AppName,
+		"asset_ver": AssetVer,
+	})
+}
+
+// getSelectableTradeTypes 收银台可供选择的交易类型，即已启用钱包地址且商户允许的交易类型
+func getSelectableTradeTypes(merchantId int64) []string {
+	available, err := model.GetAvailableTradeType()
+	if err != nil {
+		log.Warn("可用交易类型查询失败：", err.Error())
+
+		return nil
+	}
+
+	var merchant = model.GetMerchant(merchantId)
+	var result = make([]string, 0)
+	for _, networks := range available {
+		for _, tradeType := range networks {
+			if help.InStrings(tradeType, model.SupportTradeTypes) && merchant.AllowTradeType(tradeType) {
+				result = append(result, tradeType)
+			}
+		}
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
 func checkStatus(ctx *gin.Context) {
 	tradeId := ctx.Param("trade_id")
 	order, ok := model.GetTradeOrder(tradeId)
@@ -274,7 +515,7 @@ func queryTransaction(ctx *gin.Context) {
 		}
 	}
 
-	order, ok := model.GetTradeOrder(tradeId)
+	order, ok := getMerchantOrder(ctx, tradeId)
 	if !ok {
 		ctx.JSON(200, respFailJson("订单不存在"))
 
@@ -283,13 +524,17 @@ func queryTransaction(ctx *gin.Context) {
 	tokenType, _ := model.GetTokenType(order.TradeType)
 	// 返回响应数据
 	ctx.JSON(200, respSuccJson(gin.H{
-		"trade_id":     order.TradeId,
-		"trade_hash":   order.TradeHash,
-		"status":       order.Status,
-		"currency":     "CNY",
-		"amount":       order.Money,
-		"token_type":   tokenType,
-		"token_amount": help.Atof(order.Amount),
+		"trade_id":      order.TradeId,
+		"trade_hash":    order.TradeHash,
+		"status":        order.Status,
+		"currency":      order.Currency,
+		"amount":        order.Money,
+		"token_type":    tokenType,
+		"token_amount":  help.Atof(order.Amount),
+		"open_amount":   order.OpenAmount,
+		"trade_rate":    order.TradeRate,
+		"rate_snapshot": order.ParseRateSnapshot(),
+		"events":        model.GetOrderEvents(order.TradeId),
 	}))
 }
 
@@ -311,10 +556,15 @@ func queryNetworks(ctx *gin.Context) {
 
 		return
 	}
+	merchant := getMerchant(ctx)
 	result := map[string][]RespNetwork{}
 	for tokenType, networks := range tradeTypes {
-		result[tokenType] = make([]RespNetwork, len(networks))
-		for index, tradeType := range networks {
+		for _, tradeType := range networks {
+			if !merchant.AllowTradeType(tradeType) {
+
+				continue
+			}
+
 			v := RespNetwork{
 				Value: tradeType,
 				Label: model.GetTradeTypeLabel(tradeType),
@@ -322,9 +572,25 @@ func queryNetworks(ctx *gin.Context) {
 			if len(v.Label) == 0 {
 				v.Label = v.Value
 			}
-			result[tokenType][index] = v
+			result[tokenType] = append(result[tokenType], v)
 		}
 	}
 	// 返回响应数据
 	ctx.JSON(200, respSuccJson(result))
 }
+
+// parseCurrency 订单法币，默认 CNY，其它法币需在配置 currencies 中启用
+func parseCurrency(data map[string]any) (string, error) {
+	currency := strings.ToUpper(cast.ToString(data["currency"]))
+	if currency == "" {
+
+		return rate.FiatCNY, nil
+	}
+
+	if currency != rate.FiatCNY && !help.InStrings(currency, conf.GetCurrencies()) {
+
+		return "", fmt.Errorf("法币(%s)不支持", currency)
+	}
+
+	return currency, nil
+}
@@ -0,0 +1,89 @@
+package web
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"github.com/v03413/bepusdt/app/model"
+)
+
+var ledgerExportMax = 50000 // 单次导出最大条数
+
+func parseLedgerQuery(data map[string]any) model.LedgerQuery {
+	var q = model.LedgerQuery{
+		Address: cast.ToString(data["address"]),
+		Network: cast.ToString(data["network"]),
+		Token:   cast.ToString(data["token"]),
+		Page:    max(cast.ToInt(data["page"]), 1),
+		Size:    cast.ToInt(data["size"]),
+	}
+	if v := cast.ToInt64(data["start_time"]); v > 0 {
+		q.Start = time.Unix(v, 0)
+	}
+	if v := cast.ToInt64(data["end_time"]); v > 0 {
+		q.End = time.Unix(v, 0)
+	}
+	if q.Size <= 0 || q.Size > 100 {
+		q.Size = 20
+	}
+
+	return q
+}
+
+// ledgerList 收款钱包流水分页查询
+func ledgerList(ctx *gin.Context) {
+	rows, total := model.QueryLedgers(parseLedgerQuery(ctx.GetStringMap("data")))
+
+	ctx.JSON(200, respSuccJson(gin.H{"total": total, "list": rows}))
+}
+
+// ledgerExport 收款钱包流水导出 CSV，筛选条件同 ledgerList，忽略分页参数
+func ledgerExport(ctx *gin.Context) {
+	var q = parseLedgerQuery(ctx.GetStringMap("data"))
+
+	ctx.Header("Content-Type", "text/csv; charset=utf-8")
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=ledger-%s.csv", time.Now().Format("20060102150405")))
+	ctx.Status(200)
+
+	w := csv.NewWriter(ctx.Writer)
+	_, _ = ctx.Writer.Write([]byte("\xEF\xBB\xBF")) // UTF-8 BOM，兼容 Excel 打开
+	_ = w.Write([]string{"id", "network", "type", "tx_hash", "log_index", "from_address", "recv_address", "token", "amount", "block_num", "timestamp", "trade_id"})
+
+	var count = 0
+	q.Size = 1000
+	for q.Page = 1; count < ledgerExportMax; q.Page++ {
+		rows, _ := model.QueryLedgers(q)
+		if len(rows) > ledgerExportMax-count {
+			rows = rows[:ledgerExportMax-count]
+		}
+
+		count += len(rows)
+		for _, r := range rows {
+			_ = w.Write([]string{
+				strconv.FormatInt(r.ID, 10),
+				r.Network,
+				r.Type,
+				r.TxHash,
+				strconv.FormatInt(r.LogIndex, 10),
+				r.FromAddress,
+				r.RecvAddress,
+				r.Token,
+				r.Amount,
+				strconv.FormatInt(r.BlockNum, 10),
+				r.Timestamp.Format(time.DateTime),
+				r.TradeId,
+			})
+		}
+
+		if len(rows) < q.Size {
+
+			break
+		}
+	}
+
+	w.Flush()
+}
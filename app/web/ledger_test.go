@@ -0,0 +1,64 @@
+package web
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v03413/bepusdt/app/model"
+)
+
+func TestLedgerExport(t *testing.T) {
+	var cases = []struct {
+		name  string
+		max   int
+		count int
+		data  map[string]any
+		want  int
+	}{
+		{"all rows", 50000, 1200, map[string]any{}, 1200},
+		{"capped", 1100, 1200, map[string]any{}, 1100},
+		{"capped within first page", 5, 1200, map[string]any{}, 5},
+		{"evm address filter", 50000, 1200, map[string]any{"address": "0xABCDEF0123456789ABCDEF0123456789ABCDEF01"}, 600},
+	}
+
+	defer func(v int) { ledgerExportMax = v }(ledgerExportMax)
+	gin.SetMode(gin.TestMode)
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setupTestDB(t)
+			ledgerExportMax = c.max
+
+			var rows = make([]model.Ledger, 0, c.count)
+			for i := 0; i < c.count; i++ {
+				var recv = "0xabcdef0123456789abcdef0123456789abcdef01"
+				if i%2 == 1 {
+					recv = "0xother"
+				}
+
+				rows = append(rows, model.Ledger{Network: "ethereum", TxHash: fmt.Sprintf("0x%d", i), FromAddress: "0xfrom", RecvAddress: recv, Token: model.OrderTradeTypeUsdtErc20, Amount: "1", Timestamp: time.Now()})
+			}
+			if err := model.DB.CreateInBatches(rows, 500).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			var w = httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Set("data", c.data)
+			ledgerExport(ctx)
+
+			records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(w.Body.String(), "\xEF\xBB\xBF"))).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := len(records) - 1; n != c.want {
+				t.Fatalf("exported %d rows, want %d", n, c.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package notify
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,25 +11,27 @@ import (
 	"time"
 
 	"github.com/v03413/bepusdt/app"
-	"github.com/v03413/bepusdt/app/bot"
-	"github.com/v03413/bepusdt/app/conf"
 	"github.com/v03413/bepusdt/app/help"
 	"github.com/v03413/bepusdt/app/log"
 	"github.com/v03413/bepusdt/app/model"
 	e "github.com/v03413/bepusdt/app/web/epay"
 	"github.com/v03413/go-cache"
+	"gorm.io/gorm"
 )
 
 type EpNotify struct {
-	TradeId            string  `json:"trade_id"`             //  本地订单号
-	OrderId            string  `json:"order_id"`             //  客户交易id
-	Amount             float64 `json:"amount"`               //  订单金额 CNY
-	TokenAmount        float64 `json:"token_amount"`         //  USDT 交易数额
-	Token              string  `json:"token"`                //  收款钱包地址
-	BlockTransactionId string  `json:"block_transaction_id"` // 区块id
-	Signature          string  `json:"signature"`            // 签名
-	Status             int     `json:"status"`               //  1：等待支付，2：支付成功，3：订单超时
-	Nonce              string  `json:"nonce,omitempty"`      // 一次性随机字符串
+	TradeId            string  `json:"trade_id"`              //  本地订单号
+	OrderId            string  `json:"order_id"`              //  客户交易id
+	Amount             float64 `json:"amount"`                //  订单金额，以 Currency 计价
+	Currency           string  `json:"currency"`              //  订单法币
+	TokenAmount        float64 `json:"token_amount"`          //  USDT 交易数额
+	ActualAmount       float64 `json:"actual_amount"`         //  实际收款数额
+	Token              string  `json:"token"`                 //  收款钱包地址
+	BlockTransactionId string  `json:"block_transaction_id"`  // 区块id
+	Signature          string  `json:"signature"`             // 签名
+	Status             int     `json:"status"`                //  1：等待支付，2：支付成功，3：订单超时
+	OpenAmount         bool    `json:"open_amount,omitempty"` // 不定额订单，amount 按收款时汇率折算
+	Nonce              string  `json:"nonce,omitempty"`       // 一次性随机字符串
 }
 
 func (e *EpNotify) ToMap() map[string]interface{} {
@@ -36,7 +39,9 @@ func (e *EpNotify) ToMap() map[string]interface{} {
 		"trade_id":             e.TradeId,
 		"order_id":             e.OrderId,
 		"amount":               e.Amount,
+		"currency":             e.Currency,
 		"token_amount":         e.TokenAmount,
+		"actual_amount":        e.ActualAmount,
 		"token":                e.Token,
 		"block_transaction_id": e.BlockTransactionId,
 		"signature":            e.Signature,
@@ -45,28 +50,31 @@ func (e *EpNotify) ToMap() map[string]interface{} {
 	if len(e.Nonce) > 0 {
 		v["nonce"] = e.Nonce
 	}
+	if e.OpenAmount {
+		v["open_amount"] = e.OpenAmount
+	}
 	return v
 }
 
-func Handle(order model.TradeOrders) {
+// Handle 回调商户收款成功，回调失败返回错误，由发件箱按退避时间重试
+func Handle(order model.TradeOrders) error {
 	if order.Status != model.OrderStatusSuccess {
 
-		return
+		return nil
 	}
 
 	var ctx, cancel = context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
 
 	if order.ApiType == model.OrderApiTypeEpay {
-		epay(ctx, order)
 
-		return
+		return epay(ctx, order)
 	}
 
-	epusdt(ctx, order)
+	return epusdt(ctx, order)
 }
 
-func epay(ctx context.Context, order model.TradeOrders) {
+func epay(ctx context.Context, order model.TradeOrders) error {
 	var client = http.Client{Timeout: time.Second * 5}
 	var notifyUrl = fmt.Sprintf("%s?%s", order.NotifyUrl, e.BuildNotifyParams(order))
 
@@ -74,35 +82,32 @@ func epay(ctx context.Context, order model.TradeOrders) {
 	if err2 != nil {
 		log.Error("Notify NewRequest Error: ", err2)
 
-		return
+		return err2
 	}
 
 	resp, err := client.Do(postReq)
 	if err != nil {
 		log.Error("Notify Handle Error: ", err)
 
-		return
+		return err
 	}
 
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		markNotifyFail(order, "resp.StatusCode != 200")
 
-		return
+		return markNotifyFail(order, "resp.StatusCode != 200")
 	}
 
 	all, err := io.ReadAll(resp.Body)
 	if err != nil {
-		markNotifyFail(order, fmt.Sprintf("io.ReadAll(resp.Body) Error: %v", err))
 
-		return
+		return markNotifyFail(order, fmt.Sprintf("io.ReadAll(resp.Body) Error: %v", err))
 	}
 
 	// 判断是否包含 success
 	if !strings.Contains(strings.ToLower(string(all)), "success") {
-		markNotifyFail(order, fmt.Sprintf("body not contains success (%s)", string(all)))
 
-		return
+		return markNotifyFail(order, fmt.Sprintf("body not contains success (%s)", string(all)))
 	}
 
 	err = order.SetNotifyState(model.OrderNotifyStateSucc)
@@ -111,36 +116,39 @@ func epay(ctx context.Context, order model.TradeOrders) {
 	} else {
 		log.Info("订单通知成功：", order.OrderId)
 	}
+
+	return nil
 }
 
-func epusdt(ctx context.Context, order model.TradeOrders) {
+func epusdt(ctx context.Context, order model.TradeOrders) error {
 	var req = EpNotify{
 		TradeId:            order.TradeId,
 		OrderId:            order.OrderId,
 		Amount:             order.Money,
+		Currency:           order.Currency,
 		TokenAmount:        help.Atof(order.Amount),
+		ActualAmount:       help.Atof(order.ActualAmount),
 		Token:              order.Address,
 		BlockTransactionId: order.TradeHash,
 		Status:             order.Status,
+		OpenAmount:         order.OpenAmount,
 	}
 	req.Nonce, _ = help.GenerateNonce()
 	data := req.ToMap()
 	// 签名
-	req.Signature = help.EpusdtSign(data, conf.GetAuthToken())
+	req.Signature = help.EpusdtSign(data, model.GetMerchant(order.MerchantId).Secret)
 
 	// 再次序列化
 	jsonBody, err := json.Marshal(req)
 	if err != nil {
-		markNotifyFail(order, err.Error())
 
-		return
+		return markNotifyFail(order, err.Error())
 	}
 	var client = http.Client{Timeout: time.Second * 5}
 	postReq, err := http.NewRequestWithContext(ctx, "POST", order.NotifyUrl, strings.NewReader(string(jsonBody)))
 	if err != nil {
-		markNotifyFail(order, err.Error())
 
-		return
+		return markNotifyFail(order, err.Error())
 	}
 
 	postReq.Header.Set("Content-Type", "application/json")
@@ -148,29 +156,25 @@ func epusdt(ctx context.Context, order model.TradeOrders) {
 	postReq.Header.Set("User-Agent", "BEpusdt/"+app.Version)
 	resp, err := client.Do(postReq)
 	if err != nil {
-		markNotifyFail(order, err.Error())
 
-		return
+		return markNotifyFail(order, err.Error())
 	}
 
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		markNotifyFail(order, "resp.StatusCode != 200")
 
-		return
+		return markNotifyFail(order, "resp.StatusCode != 200")
 	}
 
 	all, err := io.ReadAll(resp.Body)
 	if err != nil {
-		markNotifyFail(order, fmt.Sprintf("io.ReadAll(resp.Body) Error: %v", err))
 
-		return
+		return markNotifyFail(order, fmt.Sprintf("io.ReadAll(resp.Body) Error: %v", err))
 	}
 
 	if string(all) != "ok" {
-		markNotifyFail(order, fmt.Sprintf("body != ok (%s)", string(all)))
 
-		return
+		return markNotifyFail(order, fmt.Sprintf("body != ok (%s)", string(all)))
 	}
 
 	err = order.SetNotifyState(model.OrderNotifyStateSucc)
@@ -179,95 +183,108 @@ func epusdt(ctx context.Context, order model.TradeOrders) {
 	} else {
 		log.Info("订单通知成功：", order.OrderId)
 	}
+
+	return nil
 }
 
+// Bepusdt 异步通知订单状态变更，同一状态一分钟内只通知一次
 func Bepusdt(order model.TradeOrders) {
+	go func() {
+		if err := BepusdtSync(order); err != nil {
+			log.Warn("notify BEpusdt Error:", err.Error())
+		}
+	}()
+}
+
+// BepusdtSync 通知订单状态变更并返回结果，订单状态已再次变化时不再通知
+func BepusdtSync(order model.TradeOrders) error {
 	if order.ApiType != model.OrderApiTypeEpusdt {
 
-		return
+		return nil
 	}
 
-	var todo = func() error {
-		var o model.TradeOrders
-		var db = model.DB.Begin()
-		if err := db.Where("trade_id = ? and status = ?", order.TradeId, order.Status).First(&o).Error; err != nil {
-			db.Rollback()
-
-			return err
-		}
-
-		var key = fmt.Sprintf("bepusdt_notify_%d_%s", o.Status, o.TradeId)
-		if _, ok := cache.Get(key); ok {
-			db.Rollback()
+	var o model.TradeOrders
+	var db = model.DB.Begin()
+	if err := db.Where("trade_id = ? and status = ?", order.TradeId, order.Status).First(&o).Error; err != nil {
+		db.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
 
 			return nil
 		}
 
-		cache.Set(key, true, time.Minute)
+		return err
+	}
 
-		var body = EpNotify{
-			TradeId:            o.TradeId,
-			OrderId:            o.OrderId,
-			Amount:             o.Money,
-			TokenAmount:        help.Atof(o.Amount),
-			Token:              o.Address,
-			BlockTransactionId: o.TradeHash,
-			Status:             o.Status,
-		}
-		body.Nonce, _ = help.GenerateNonce()
-		data := body.ToMap()
-		// 签名
-		body.Signature = help.EpusdtSign(data, conf.GetAuthToken())
+	var key = fmt.Sprintf("bepusdt_notify_%d_%s", o.Status, o.TradeId)
+	if _, ok := cache.Get(key); ok {
+		db.Rollback()
 
-		// 再次序列化
-		jsonBody, err := json.Marshal(body)
-		if err != nil {
-			db.Rollback()
+		return nil
+	}
 
-			return err
-		}
-		var client = http.Client{Timeout: time.Second * 5}
-		req, err := http.NewRequest("POST", o.NotifyUrl, strings.NewReader(string(jsonBody)))
-		if err != nil {
-			db.Rollback()
+	cache.Set(key, true, time.Minute)
+
+	var body = EpNotify{
+		TradeId:            o.TradeId,
+		OrderId:            o.OrderId,
+		Amount:             o.Money,
+		Currency:           o.Currency,
+		TokenAmount:        help.Atof(o.Amount),
+		Token:              o.Address,
+		BlockTransactionId: o.TradeHash,
+		Status:             o.Status,
+		OpenAmount:         o.OpenAmount,
+	}
+	body.Nonce, _ = help.GenerateNonce()
+	data := body.ToMap()
+	// 签名
+	body.Signature = help.EpusdtSign(data, model.GetMerchant(o.MerchantId).Secret)
 
-			return err
-		}
+	var fail = func(err error) error {
+		db.Rollback()
+		cache.Delete(key)
 
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Powered-By", "https://github.com/v03413/BEpusdt")
-		resp, err := client.Do(req)
-		if err != nil {
-			db.Rollback()
+		return err
+	}
 
-			return err
-		}
+	// 再次序列化
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
 
-		defer resp.Body.Close()
-		if resp.StatusCode != 200 {
-			db.Rollback()
+		return fail(err)
+	}
+	var client = http.Client{Timeout: time.Second * 5}
+	req, err := http.NewRequest("POST", o.NotifyUrl, strings.NewReader(string(jsonBody)))
+	if err != nil {
 
-			return fmt.Errorf("resp.StatusCode != 200")
-		}
+		return fail(err)
+	}
 
-		all, _ := io.ReadAll(resp.Body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Powered-By", "https://github.com/v03413/BEpusdt")
+	resp, err := client.Do(req)
+	if err != nil {
 
-		log.Infof("订单回调成功[%d]：%s %s", order.Status, o.TradeId, string(all))
+		return fail(err)
+	}
 
-		db.Commit()
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
 
-		return nil
+		return fail(fmt.Errorf("resp.StatusCode != 200"))
 	}
-	go func() {
-		if err := todo(); err != nil {
-			log.Warn("notify BEpusdt Error:", err.Error())
-		}
-	}()
+
+	all, _ := io.ReadAll(resp.Body)
+
+	log.Infof("订单回调成功[%d]：%s %s", order.Status, o.TradeId, string(all))
+
+	db.Commit()
+
+	return nil
 }
 
-func markNotifyFail(order model.TradeOrders, reason string) {
-	log.Warnf("订单回调失败(%v)：%s %v", order.TradeId, reason, order.SetNotifyState(model.OrderNotifyStateFail))
-	go func() {
-		bot.SendNotifyFailed(order, reason)
-	}()
+func markNotifyFail(order model.TradeOrders, reason string) error {
+	log.Warnf("订单回调失败(%v)：%s %v", order.TradeId, reason, order.SetNotifyFail(reason))
+
+	return errors.New(reason)
 }
@@ -4,14 +4,17 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/v03413/bepusdt/app/conf"
 	"github.com/v03413/bepusdt/app/help"
 	"github.com/v03413/bepusdt/app/log"
 	"github.com/v03413/bepusdt/app/model"
 )
 
 type orderParams struct {
-	Money       float64 `json:"money"`        // 交易金额 CNY
+	Money       float64 `json:"money"`        // 交易金额，以 Currency 计价
+	Currency    string  `json:"currency"`     // 订单法币，如 CNY USD
 	ApiType     string  `json:"api_type"`     // 支付API类型
 	PayAddress  string  `json:"pay_address"`  // 收款地址
 	OrderId     string  `json:"order_id"`     // 商户订单ID
@@ -21,6 +24,11 @@ type orderParams struct {
 	Name        string  `json:"name"`         // 商品名称
 	Timeout     uint64  `json:"timeout"`      // 订单超时时间（秒）
 	Rate        string  `json:"rate"`         // 强制指定汇率
+	MerchantId  int64   `json:"merchant_id"`  // 所属商户
+	QuoteId     string  `json:"quote_id"`     // 锁定汇率的报价ID
+	TokenAmount float64 `json:"token_amount"` // 以代币计价的订单数额，指定时不做法币换算
+	Selectable  bool    `json:"selectable"`   // 由用户在收银台选择网络
+	OpenAmount  bool    `json:"open_amount"`  // 不定额订单，按实际收款数额完成
 }
 
 var lock sync.Mutex
@@ -28,20 +36,68 @@ var lock sync.Mutex
 type trade struct {
 	TokenType model.TokenType
 	Rate      float64
+	Snapshot  model.RateSnapshot
 	Address   model.WalletAddress
 	Amount    string
 }
 
+// rateText 选择网络前的订单不记录汇率
+func (t trade) rateText() string {
+	if t.Rate == 0 {
+
+		return ""
+	}
+
+	return fmt.Sprintf("%v", t.Rate)
+}
+
+func (t trade) snapshotText() string {
+	if t.Rate == 0 {
+
+		return ""
+	}
+
+	return t.Snapshot.String()
+}
+
 func buildOrder(p orderParams) (model.TradeOrders, error) {
 	var order model.TradeOrders
 
-	model.DB.Where("order_id = ?", p.OrderId).Find(&order)
-	if order.Status == model.OrderStatusSuccess {
+	if p.TradeType == model.OrderTradeTypeAny {
+		if p.TokenAmount > 0 {
+			return order, fmt.Errorf("代币计价订单需指定交易类型")
+		}
+
+		p.Selectable = true
+	}
+
+	// 不定额订单，金额在收款时按实际数额折算
+	if p.OpenAmount {
+		if p.TokenAmount > 0 {
+			return order, fmt.Errorf("不定额订单无需指定代币数额")
+		}
+
+		p.Money = 0
+	}
+
+	// 代币计价订单，订单金额即代币数额，法币记为代币本身
+	if p.TokenAmount > 0 {
+		tokenType, err := model.GetTokenType(p.TradeType)
+		if err != nil {
+			return order, fmt.Errorf("类型(%s)不支持：%v", p.TradeType, err)
+		}
+
+		p.Money = p.TokenAmount
+		p.Currency = string(tokenType)
+	}
+
+	model.DB.Where("order_id = ? and merchant_id = ?", p.OrderId, p.MerchantId).Find(&order)
+	if order.Status == model.OrderStatusSuccess || order.Status == model.OrderStatusPartial || order.Status == model.OrderStatusLatePaid {
 		return order, nil
 	}
 
 	if order.Status == model.OrderStatusWaiting {
-		return rebuildOrder(order, p)
+		return rebuildOrder(order, p, model.ActorApi, "订单重建")
 	}
 
 	lock.Lock()
@@ -55,8 +111,13 @@ func buildOrder(p orderParams) (model.TradeOrders, error) {
 	return newOrder(p, data)
 }
 
-func rebuildOrder(t model.TradeOrders, p orderParams) (model.TradeOrders, error) {
-	if p.OrderId == t.OrderId && p.TradeType == t.TradeType && p.Money == t.Money {
+func rebuildOrder(t model.TradeOrders, p orderParams, actor, reason string) (model.TradeOrders, error) {
+	if p.OrderId == t.OrderId && p.TradeType == t.TradeType && p.Money == t.Money && p.Currency == t.Currency && p.OpenAmount == t.OpenAmount {
+		return t, nil
+	}
+
+	// 用户已在收银台选择网络，商户重复提交相同订单时保持用户的选择
+	if p.TradeType == model.OrderTradeTypeAny && t.Selectable && p.Money == t.Money && p.Currency == t.Currency && p.OpenAmount == t.OpenAmount {
 		return t, nil
 	}
 
@@ -69,10 +130,26 @@ func rebuildOrder(t model.TradeOrders, p orderParams) (model.TradeOrders, error)
 	}
 
 	t.Amount = data.Amount
+	t.TradeRate = data.rateText()
+	t.RateSnapshot = data.snapshotText()
+	t.Money = p.Money
+	t.Currency = p.Currency
 	t.TradeType = p.TradeType
 	t.Address = data.Address.Address
+	t.Selectable = p.Selectable
+	t.OpenAmount = p.OpenAmount
 
-	return t, model.DB.Save(&t).Error
+	return t, waitOrder(&t, p, actor, reason)
+}
+
+// waitOrder 订单写入等待支付状态，凭报价下单时报价在同一事务中绑定，订单写入失败时报价不会被占用
+func waitOrder(o *model.TradeOrders, p orderParams, actor, reason string, effects ...model.Effect) error {
+	if p.QuoteId != "" {
+
+		return o.TransitionWithQuote(p.QuoteId, model.OrderStatusWaiting, actor, reason, effects...)
+	}
+
+	return o.Transition(model.OrderStatusWaiting, actor, reason, effects...)
 }
 
 func newOrder(p orderParams, data trade) (model.TradeOrders, error) {
@@ -82,48 +159,77 @@ func newOrder(p orderParams, data trade) (model.TradeOrders, error) {
 	}
 
 	tradeOrder := model.TradeOrders{
-		OrderId:     p.OrderId,
-		TradeId:     tradeId,
-		TradeHash:   tradeId,
-		TradeType:   p.TradeType,
-		TradeRate:   fmt.Sprintf("%v", data.Rate),
-		Amount:      data.Amount,
-		Money:       p.Money,
-		Address:     data.Address.Address,
-		Status:      model.OrderStatusWaiting,
-		Name:        p.Name,
-		ApiType:     p.ApiType,
-		ReturnUrl:   p.RedirectUrl,
-		NotifyUrl:   p.NotifyUrl,
-		NotifyNum:   0,
-		NotifyState: model.OrderNotifyStateFail,
-		ExpiredAt:   model.CalcTradeExpiredAt(p.Timeout),
-	}
-
-	if err = model.DB.Create(&tradeOrder).Error; err != nil {
+		OrderId:      p.OrderId,
+		TradeId:      tradeId,
+		TradeHash:    tradeId,
+		TradeType:    p.TradeType,
+		TradeRate:    data.rateText(),
+		RateSnapshot: data.snapshotText(),
+		Amount:       data.Amount,
+		Money:        p.Money,
+		Currency:     p.Currency,
+		Address:      data.Address.Address,
+		Name:         p.Name,
+		ApiType:      p.ApiType,
+		ReturnUrl:    p.RedirectUrl,
+		NotifyUrl:    p.NotifyUrl,
+		NotifyNum:    0,
+		NotifyState:  model.OrderNotifyStateFail,
+		ExpiredAt:    model.CalcTradeExpiredAt(p.Timeout),
+		MerchantId:   p.MerchantId,
+		Selectable:   p.Selectable,
+		OpenAmount:   p.OpenAmount,
+	}
+
+	if err = waitOrder(&tradeOrder, p, model.ActorApi, "创建订单", model.WebhookEffect(model.WebhookEventOrderCreate)); err != nil {
 		log.Error("订单创建失败：", err.Error())
 		return model.TradeOrders{}, err
 	}
 
-	model.PushWebhookEvent(model.WebhookEventOrderCreate, tradeOrder)
 	return tradeOrder, nil
 }
 
 func buildTrade(p orderParams) (trade, error) {
+	// 选择网络前不分配收款地址及数额
+	if p.TradeType == model.OrderTradeTypeAny {
+		return trade{Amount: "0"}, nil
+	}
+
 	// 获取代币类型
 	tokenType, err := model.GetTokenType(p.TradeType)
 	if err != nil {
 		return trade{}, fmt.Errorf("类型(%s)不支持：%v", p.TradeType, err)
 	}
 
-	// 获取交易汇率
-	rate, err := model.GetTradeRate(tokenType, strings.TrimSpace(p.Rate))
+	if p.OpenAmount {
+
+		return buildOpenTrade(tokenType, p)
+	}
+
+	// 获取交易汇率，代币计价订单汇率固定为 1
+	rate, snapshot, err := getTradeRate(tokenType, p)
 	if err != nil {
 		return trade{}, err
 	}
 
-	// 可用钱包地址
-	wallet := model.GetAvailableAddress(p.PayAddress, p.TradeType)
+	// 配置了扩展公钥，每个订单派生独立收款地址，按地址匹配支付，无需递增金额
+	if p.PayAddress == "" && model.IsDeriveEnabled(p.TradeType) {
+		address, amount, err := model.DeriveTradeAmount(rate, p.Money, p.TradeType)
+		if err != nil {
+			return trade{}, err
+		}
+
+		return trade{
+			TokenType: tokenType,
+			Rate:      rate,
+			Snapshot:  snapshot,
+			Address:   address,
+			Amount:    amount,
+		}, nil
+	}
+
+	// 可用钱包地址，被不定额订单独占的地址除外
+	wallet := model.ExcludeOpenAmountAddress(model.GetAvailableAddress(p.PayAddress, p.TradeType, p.MerchantId), p.TradeType)
 	if len(wallet) == 0 {
 		return trade{}, fmt.Errorf("类型(%s)未检测到可用钱包地址", p.TradeType)
 	}
@@ -137,7 +243,50 @@ func buildTrade(p orderParams) (trade, error) {
 	return trade{
 		TokenType: tokenType,
 		Rate:      rate,
+		Snapshot:  snapshot,
 		Address:   address,
 		Amount:    amount,
 	}, nil
 }
+
+// buildOpenTrade 不定额订单独占收款地址，转入该地址的任意数额均完成订单，汇率在收款时确定
+func buildOpenTrade(tokenType model.TokenType, p orderParams) (trade, error) {
+	if p.PayAddress == "" && model.IsDeriveEnabled(p.TradeType) {
+		address, err := model.DeriveWalletAddress(p.TradeType)
+		if err != nil {
+			return trade{}, err
+		}
+
+		return trade{TokenType: tokenType, Address: address, Amount: "0"}, nil
+	}
+
+	wallet := model.GetAvailableAddress(p.PayAddress, p.TradeType, p.MerchantId)
+	if len(wallet) == 0 {
+		return trade{}, fmt.Errorf("类型(%s)未检测到可用钱包地址", p.TradeType)
+	}
+
+	address, err := model.GetOpenAmountAddress(wallet, p.TradeType)
+	if err != nil {
+		return trade{}, err
+	}
+
+	return trade{TokenType: tokenType, Address: address, Amount: "0"}, nil
+}
+
+// getTradeRate 交易汇率及汇率快照，汇率过期时按配置拒绝下单或标记
+func getTradeRate(tokenType model.TokenType, p orderParams) (float64, model.RateSnapshot, error) {
+	if p.TokenAmount > 0 {
+
+		return 1, model.RateSnapshot{Syntax: "1", Sources: []string{conf.RateSourceFixed}, UpdatedAt: time.Now()}, nil
+	}
+
+	snapshot, err := model.GetRateSnapshot(tokenType, p.Currency, strings.TrimSpace(p.Rate))
+	if err != nil {
+		return 0, snapshot, err
+	}
+	snapshot.QuoteId = p.QuoteId
+
+	rate, err := model.GetTradeRate(tokenType, p.Currency, strings.TrimSpace(p.Rate))
+
+	return rate, snapshot, err
+}
@@ -0,0 +1,174 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/v03413/bepusdt/app/model"
+	"github.com/v03413/bepusdt/app/model/modeltest"
+	"github.com/v03413/bepusdt/app/task/rate"
+)
+
+func setupTestDB(t *testing.T, wallets ...string) {
+	t.Helper()
+	modeltest.Setup(t)
+
+	for _, address := range wallets {
+		model.DB.Create(&model.WalletAddress{TradeType: model.OrderTradeTypeUsdtTrc20, Address: address, Status: model.StatusEnable})
+	}
+}
+
+func TestBuildTokenOrder(t *testing.T) {
+	var cases = []struct {
+		name     string
+		p        orderParams
+		amount   string
+		currency string
+		err      bool
+	}{
+		{"token amount", orderParams{OrderId: "o1", TradeType: model.OrderTradeTypeUsdtTrc20, TokenAmount: 12.5, Currency: "CNY"}, "12.5", "USDT", false},
+		{"token amount ignores rate", orderParams{OrderId: "o1", TradeType: model.OrderTradeTypeUsdtTrc20, TokenAmount: 3, Rate: "~2"}, "3", "USDT", false},
+		{"token amount requires trade type", orderParams{OrderId: "o1", TradeType: model.OrderTradeTypeAny, TokenAmount: 3}, "", "", true},
+		{"unsupported trade type", orderParams{OrderId: "o1", TradeType: "unknown", TokenAmount: 3}, "", "", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setupTestDB(t, "TAddr")
+
+			order, err := buildOrder(c.p)
+			if c.err {
+				if err == nil {
+					t.Fatalf("expected error, got order %+v", order)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if order.Amount != c.amount || order.Currency != c.currency || order.TradeRate != "1" || order.Money != c.p.TokenAmount {
+				t.Fatalf("unexpected order amount %s %v %s rate %s", order.Amount, order.Money, order.Currency, order.TradeRate)
+			}
+			if order.Address != "TAddr" || order.Status != model.OrderStatusWaiting {
+				t.Fatalf("unexpected order address %s status %s", order.Address, model.OrderStatusName(order.Status))
+			}
+		})
+	}
+}
+
+// 同一钱包上相同代币数额的订单按原子精度递增，避免无法区分支付归属
+func TestBuildTokenOrderIncrement(t *testing.T) {
+	setupTestDB(t, "TAddr")
+
+	var amounts = []string{"10", "10.01"}
+	for i, want := range amounts {
+		order, err := buildOrder(orderParams{OrderId: "o" + want, TradeType: model.OrderTradeTypeUsdtTrc20, TokenAmount: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if order.Amount != want {
+			t.Fatalf("order %d amount = %s, want %s", i, order.Amount, want)
+		}
+	}
+}
+
+// setupTestRate usdt/cny 汇率固定为 7，1 USDT 折合 7 CNY
+func setupTestRate(t *testing.T) {
+	t.Helper()
+
+	rate.SetUsdtCnyRate("", 7)
+	if err := rate.Accept("USDT", rate.FiatCNY, 7, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuildAnyOrder(t *testing.T) {
+	var cases = []struct {
+		name      string
+		resubmit  orderParams
+		tradeType string
+		amount    string
+	}{
+		{"resubmit keeps customer choice", orderParams{OrderId: "o1", TradeType: model.OrderTradeTypeAny, Money: 70, Currency: "CNY"}, model.OrderTradeTypeUsdtTrc20, "10"},
+		{"resubmit with new money resets choice", orderParams{OrderId: "o1", TradeType: model.OrderTradeTypeAny, Money: 140, Currency: "CNY"}, model.OrderTradeTypeAny, "0"},
+		{"resubmit with fixed network", orderParams{OrderId: "o1", TradeType: model.OrderTradeTypeUsdtTrc20, Money: 70, Currency: "CNY"}, model.OrderTradeTypeUsdtTrc20, "10"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setupTestDB(t, "TAddr")
+			setupTestRate(t)
+
+			order, err := buildOrder(orderParams{OrderId: "o1", TradeType: model.OrderTradeTypeAny, Money: 70, Currency: "CNY"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !order.Selectable || order.Address != "" || order.Amount != "0" || order.TradeRate != "" {
+				t.Fatalf("any order assigned before choosing network: %+v", order)
+			}
+
+			// 用户在收银台选择网络，交易ID不变
+			var chosen = orderParams{OrderId: "o1", TradeType: model.OrderTradeTypeUsdtTrc20, Money: 70, Currency: "CNY", Selectable: true}
+			selected, err := rebuildOrder(order, chosen, model.ActorCheckout, "选择网络")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if selected.TradeId != order.TradeId || selected.Address != "TAddr" || selected.Amount != "10" {
+				t.Fatalf("unexpected selected order %+v", selected)
+			}
+
+			resubmitted, err := buildOrder(c.resubmit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resubmitted.TradeId != order.TradeId || resubmitted.TradeType != c.tradeType || resubmitted.Amount != c.amount {
+				t.Fatalf("unexpected resubmitted order %s %s %s", resubmitted.TradeId, resubmitted.TradeType, resubmitted.Amount)
+			}
+		})
+	}
+}
+
+func TestBuildOpenAmountOrder(t *testing.T) {
+	var cases = []struct {
+		name    string
+		wallets []string
+		next    orderParams
+		address string
+		err     bool
+	}{
+		{"address reserved for open order", []string{"TAddr1"}, orderParams{OrderId: "o2", TradeType: model.OrderTradeTypeUsdtTrc20, OpenAmount: true}, "", true},
+		{"fixed order avoids reserved address", []string{"TAddr1", "TAddr2"}, orderParams{OrderId: "o2", TradeType: model.OrderTradeTypeUsdtTrc20, TokenAmount: 10}, "TAddr2", false},
+		{"second open order takes free address", []string{"TAddr1", "TAddr2"}, orderParams{OrderId: "o2", TradeType: model.OrderTradeTypeUsdtTrc20, OpenAmount: true}, "TAddr2", false},
+		{"open order rejects token amount", []string{"TAddr1", "TAddr2"}, orderParams{OrderId: "o2", TradeType: model.OrderTradeTypeUsdtTrc20, OpenAmount: true, TokenAmount: 10}, "", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setupTestDB(t, c.wallets...)
+
+			order, err := buildOrder(orderParams{OrderId: "o1", TradeType: model.OrderTradeTypeUsdtTrc20, Money: 100, Currency: "CNY", OpenAmount: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !order.OpenAmount || order.Address != "TAddr1" || order.Amount != "0" || order.Money != 0 {
+				t.Fatalf("unexpected open order %s %s %v", order.Address, order.Amount, order.Money)
+			}
+
+			next, err := buildOrder(c.next)
+			if c.err {
+				if err == nil {
+					t.Fatalf("expected error, got order on %s", next.Address)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if next.Address != c.address {
+				t.Fatalf("order address = %s, want %s", next.Address, c.address)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package web
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"github.com/v03413/bepusdt/app/log"
+	"github.com/v03413/bepusdt/app/model"
+)
+
+// unmatchedList 未匹配订单的收款列表
+func unmatchedList(ctx *gin.Context) {
+	data := ctx.GetStringMap("data")
+	page := max(cast.ToInt(data["page"]), 1)
+	size := cast.ToInt(data["size"])
+	if size <= 0 || size > 100 {
+		size = 20
+	}
+
+	rows, total := model.GetUnmatchedPayments(page, size)
+
+	ctx.JSON(200, respSuccJson(gin.H{"total": total, "list": rows}))
+}
+
+// attachOrder 将未匹配收款关联到订单，订单按正常流程完成支付并回调
+func attachOrder(ctx *gin.Context) {
+	data := ctx.GetStringMap("data")
+	tradeId, ok := data["trade_id"].(string)
+	if !ok {
+		ctx.JSON(200, respFailJson("参数 trade_id 不存在"))
+
+		return
+	}
+
+	p, ok := model.GetUnmatchedPayment(cast.ToInt64(data["id"]))
+	if !ok {
+		ctx.JSON(200, respFailJson("收款记录不存在"))
+
+		return
+	}
+
+	order, err := p.Attach(tradeId, model.ActorApi)
+	if err != nil {
+		ctx.JSON(200, respFailJson(fmt.Sprintf("收款关联订单失败：%s", err.Error())))
+
+		return
+	}
+
+	log.Info(fmt.Sprintf("收款 %s 已手动关联订单 %s", p.TxHash, order.TradeId))
+
+	ctx.JSON(200, respSuccJson(gin.H{"id": p.ID, "trade_id": order.TradeId, "trade_hash": order.TradeHash}))
+}
@@ -3,6 +3,7 @@ package web
 import (
 	"strings"
 
+	"github.com/v03413/bepusdt/app/conf"
 	"github.com/v03413/bepusdt/app/model"
 )
 
@@ -54,6 +55,12 @@ var networkConfigs = map[string]PaymentConfig{
 		Network:     "Aptos",
 		WarningCoin: "APT",
 	},
+	"usdt.ton": {
+		Coin:            "USDT",
+		Network:         "TON",
+		NetworkFullName: "TON (The Open Network)",
+		WarningCoin:     "TON",
+	},
 	"usdt.xlayer": {
 		Coin:         "USDT",
 		Network:      "X Layer",
@@ -110,6 +117,62 @@ var networkConfigs = map[string]PaymentConfig{
 		Network:     "Base",
 		WarningCoin: "ETH",
 	},
+	"eth.erc20": {
+		Coin:            "ETH",
+		Network:         "ERC20",
+		NetworkFullName: "以太坊 (Ethereum)",
+		WarningCoin:     "ETH",
+	},
+	"eth.arbitrum": {
+		Coin:            "ETH",
+		Network:         "Arbitrum",
+		NetworkFullName: "Arbitrum One",
+		WarningCoin:     "ETH",
+	},
+	"eth.base": {
+		Coin:        "ETH",
+		Network:     "Base",
+		WarningCoin: "ETH",
+	},
+	"bnb.bep20": {
+		Coin:            "BNB",
+		Network:         "BEP20",
+		NetworkFullName: "币安智能链 (BSC)",
+		WarningCoin:     "BNB",
+	},
+	"pol.polygon": {
+		Coin:        "POL",
+		Network:     "Polygon",
+		WarningCoin: "POL",
+	},
+	"okb.xlayer": {
+		Coin:         "OKB",
+		Network:      "X Layer",
+		NetworkTitle: "OKX (X Layer)",
+		WarningCoin:  "OKB",
+	},
+	"sol.solana": {
+		Coin:        "SOL",
+		Network:     "Solana",
+		WarningCoin: "SOL",
+	},
+	"apt.aptos": {
+		Coin:        "APT",
+		Network:     "Aptos",
+		WarningCoin: "APT",
+	},
+	"btc.bitcoin": {
+		Coin:            "BTC",
+		Network:         "Bitcoin",
+		NetworkFullName: "比特币 (Bitcoin)",
+		WarningCoin:     "BTC",
+	},
+	"ltc.litecoin": {
+		Coin:            "LTC",
+		Network:         "Litecoin",
+		NetworkFullName: "莱特币 (Litecoin)",
+		WarningCoin:     "LTC",
+	},
 	"tron.trx": {
 		Coin:            "TRX",
 		Network:         "TRON",
@@ -145,6 +208,16 @@ func GetPaymentConfig(tradeType string) PaymentConfig {
 		return config
 	}
 
+	// 配置文件声明的通用 EVM 网络
+	if c, t, ok := conf.GetChainToken(tradeType); ok {
+		return PaymentConfig{
+			Coin:            t.Symbol,
+			Network:         strings.ToUpper(parts[1]),
+			NetworkFullName: c.Name,
+			WarningCoin:     c.NativeCoin,
+		}
+	}
+
 	// 如果没找到，尝试动态构建
 	coin := strings.ToUpper(parts[0])
 	network := strings.ToUpper(parts[1])
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"github.com/v03413/bepusdt/app/help"
+	"github.com/v03413/bepusdt/app/model"
+)
+
+// orderQuote 报价，返回各交易类型的汇率及支付数额，不占用收款数额；有效期内凭 quote_id 下单锁定报价汇率
+func orderQuote(ctx *gin.Context) {
+	data := ctx.GetStringMap("data")
+	money := cast.ToFloat64(data["amount"])
+	if money <= 0 {
+		ctx.JSON(200, respFailJson("参数 amount 不合法"))
+
+		return
+	}
+
+	currency, err := parseCurrency(data)
+	if err != nil {
+		ctx.JSON(200, respFailJson(err.Error()))
+
+		return
+	}
+
+	merchant := getMerchant(ctx)
+	tradeTypes := make([]string, 0)
+	if v := cast.ToString(data["trade_type"]); v != "" {
+		tradeTypes = append(tradeTypes, v)
+	} else {
+		available, err := model.GetAvailableTradeType()
+		if err != nil {
+			ctx.JSON(200, respFailJson(err.Error()))
+
+			return
+		}
+
+		for _, networks := range available {
+			tradeTypes = append(tradeTypes, networks...)
+		}
+	}
+
+	allowed := make([]string, 0, len(tradeTypes))
+	for _, tradeType := range tradeTypes {
+		if help.InStrings(tradeType, model.SupportTradeTypes) && merchant.AllowTradeType(tradeType) {
+			allowed = append(allowed, tradeType)
+		}
+	}
+
+	quote, items, err := model.CreateQuote(merchant.ID, money, currency, allowed)
+	if err != nil {
+		ctx.JSON(200, respFailJson(fmt.Sprintf("报价失败：%s", err.Error())))
+
+		return
+	}
+
+	ctx.JSON(200, respSuccJson(gin.H{
+		"quote_id":        quote.QuoteId,
+		"amount":          quote.Money,
+		"currency":        quote.Currency,
+		"expiration_time": uint64(time.Until(quote.ExpiredAt).Seconds()),
+		"list":            items,
+	}))
+}
+
+// getQuoteRate 报价锁定的汇率，报价须在有效期内且金额、法币及交易类型与下单参数一致；报价只能用于一个商户订单，在订单写入时绑定
+func getQuoteRate(quoteId string, p orderParams) (string, error) {
+	quote, ok := model.GetQuote(quoteId, p.MerchantId)
+	if !ok {
+
+		return "", fmt.Errorf("报价(%s)不存在或已过期", quoteId)
+	}
+
+	if !quote.IsSameMoney(p.Money) || quote.Currency != p.Currency {
+
+		return "", fmt.Errorf("订单金额或法币与报价(%s)不一致", quoteId)
+	}
+
+	r, ok := quote.GetRate(p.TradeType)
+	if !ok {
+
+		return "", fmt.Errorf("报价(%s)不包含交易类型(%s)", quoteId, p.TradeType)
+	}
+
+	if quote.OrderId != "" && quote.OrderId != p.OrderId {
+
+		return "", fmt.Errorf("报价(%s)已被其它订单使用", quoteId)
+	}
+
+	return strconv.FormatFloat(r, 'f', -1, 64), nil
+}
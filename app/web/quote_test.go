@@ -0,0 +1,66 @@
+package web
+
+import (
+	"testing"
+	"time"
+
+	"github.com/v03413/bepusdt/app/model"
+	"github.com/v03413/bepusdt/app/task/rate"
+)
+
+// 报价在订单写入的同一事务中绑定，锁定的汇率不依赖实时汇率
+func TestBuildQuoteOrder(t *testing.T) {
+	var cases = []struct {
+		name    string
+		wallets []string
+		orders  []string
+		err     bool
+		bound   string
+	}{
+		{"order binds quote", []string{"TAddr"}, []string{"o1"}, false, "o1"},
+		{"same order resubmitted", []string{"TAddr"}, []string{"o1", "o1"}, false, "o1"},
+		{"other order rejected", []string{"TAddr"}, []string{"o1", "o2"}, true, "o1"},
+		{"failed order leaves quote unused", nil, []string{"o1"}, true, ""},
+	}
+
+	// 实时汇率不可用
+	rate.SetUsdtCnyRate("", 0)
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setupTestDB(t, c.wallets...)
+			model.DB.Create(&model.Quote{QuoteId: "q1", Money: 75, Currency: "CNY", Rates: `{"usdt.trc20":7.5}`, ExpiredAt: time.Now().Add(time.Minute)})
+
+			// 并发请求各自通过报价校验后再写入订单
+			var params = make([]orderParams, 0, len(c.orders))
+			for _, orderId := range c.orders {
+				var p = orderParams{OrderId: orderId, TradeType: model.OrderTradeTypeUsdtTrc20, Money: 75, Currency: "CNY", QuoteId: "q1"}
+				var err error
+				if p.Rate, err = getQuoteRate("q1", p); err != nil {
+					t.Fatal(err)
+				}
+
+				params = append(params, p)
+			}
+
+			var err error
+			for _, p := range params {
+				var order model.TradeOrders
+				if order, err = buildOrder(p); err != nil {
+
+					break
+				}
+				if order.Amount != "10" || order.TradeRate != "7.5" {
+					t.Fatalf("unexpected order amount %s rate %s", order.Amount, order.TradeRate)
+				}
+			}
+
+			if (err != nil) != c.err {
+				t.Fatalf("err = %v", err)
+			}
+			if q, _ := model.GetQuote("q1", 0); q.OrderId != c.bound {
+				t.Fatalf("quote bound to %q, want %q", q.OrderId, c.bound)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package web
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"github.com/v03413/bepusdt/app/model"
+)
+
+// rateHistory 汇率记录分页查询，可按代币、法币、来源及时间筛选
+func rateHistory(ctx *gin.Context) {
+	var data = ctx.GetStringMap("data")
+	var q = model.RateHistoryQuery{
+		Token:  cast.ToString(data["token"]),
+		Fiat:   cast.ToString(data["fiat"]),
+		Source: cast.ToString(data["source"]),
+		Page:   max(cast.ToInt(data["page"]), 1),
+		Size:   cast.ToInt(data["size"]),
+	}
+	if v := cast.ToInt64(data["start_time"]); v > 0 {
+		q.Start = time.Unix(v, 0)
+	}
+	if v := cast.ToInt64(data["end_time"]); v > 0 {
+		q.End = time.Unix(v, 0)
+	}
+	if q.Size <= 0 || q.Size > 100 {
+		q.Size = 20
+	}
+
+	rows, total := model.QueryRateHistory(q)
+
+	ctx.JSON(200, respSuccJson(gin.H{"total": total, "list": rows}))
+}
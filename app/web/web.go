@@ -31,6 +31,8 @@ func Start(ctx context.Context) {
 	{
 		payGrp.GET("/checkout-counter/:trade_id", checkoutCounter)
 		payGrp.GET("/check-status/:trade_id", checkStatus)
+		payGrp.GET("/choose-network/:trade_id", chooseNetworkPage)
+		payGrp.POST("/choose-network/:trade_id", chooseNetwork)
 	}
 
 	orderGrp := engine.Group("/api/v1/order")
@@ -40,6 +42,28 @@ func Start(ctx context.Context) {
 		orderGrp.POST("/cancel-transaction", cancelTransaction)
 		orderGrp.POST("/query-transaction", queryTransaction)
 		orderGrp.POST("/query-networks", queryNetworks)
+		orderGrp.POST("/quote", orderQuote)
+		orderGrp.POST("/accept-late-payment", acceptLatePayment)
+	}
+
+	paymentGrp := engine.Group("/api/v1/payment")
+	{
+		paymentGrp.Use(signVerify, adminVerify)
+		paymentGrp.POST("/unmatched-list", unmatchedList)
+		paymentGrp.POST("/attach-order", attachOrder)
+	}
+
+	ledgerGrp := engine.Group("/api/v1/ledger")
+	{
+		ledgerGrp.Use(signVerify, adminVerify)
+		ledgerGrp.POST("/list", ledgerList)
+		ledgerGrp.POST("/export", ledgerExport)
+	}
+
+	rateGrp := engine.Group("/api/v1/rate")
+	{
+		rateGrp.Use(signVerify)
+		rateGrp.POST("/history", rateHistory)
 	}
 
 	// 易支付兼容
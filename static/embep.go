@@ -13,5 +13,5 @@ var Img embed.FS
 //go:embed js/*
 var Js embed.FS
 
-//go:embed views/index.html views/payment.html
+//go:embed views/index.html views/payment.html views/network.html
 var Views embed.FS